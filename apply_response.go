@@ -0,0 +1,123 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// insertionPointMarker is the comment protoc searches for in a generated file
+// when resolving insertion points, formatted with the name of the point.
+const insertionPointMarker = "@@protoc_insertion_point(%s)"
+
+// ApplyResponse applies the files in resp as protoc would, returning the
+// content of each file created or modified by it, keyed by name. The existing
+// map provides the content of files already on disk, which may be the target
+// of an insertion point but are otherwise unaffected; it is not modified.
+//
+// Files without a name are appended to the preceding entry, whether that is a
+// complete file or content for an insertion point. Content for an insertion
+// point is inserted immediately before the line containing the
+// "@@protoc_insertion_point(NAME)" marker in a file generated earlier in the
+// response or present in existing. Each non-empty line inserted is indented
+// with the whitespace preceding the marker on its line.
+//
+// An error is returned if resp reports one, a file name is not a relative path
+// within the output directory, a file is generated more than once, or an
+// insertion point cannot be resolved.
+func ApplyResponse(resp *plugin_go.CodeGeneratorResponse, existing map[string]string) (map[string]string, error) {
+	if resp.Error != nil {
+		return nil, errors.New(resp.GetError())
+	}
+
+	if fs := resp.GetFile(); len(fs) > 0 && fs[0].Name == nil {
+		return nil, errors.New("first file in response has no name")
+	}
+
+	out := make(map[string]string)
+	generated := make(map[string]struct{})
+
+	files := mergeResponseFiles(resp.GetFile())
+	for _, f := range files {
+		if err := validateResponseFileName(f.GetName()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range files {
+		name := f.GetName()
+
+		if f.InsertionPoint == nil {
+			if _, ok := generated[name]; ok {
+				return nil, fmt.Errorf("%s: generated more than once", name)
+			}
+			generated[name] = struct{}{}
+			out[name] = f.GetContent()
+			continue
+		}
+
+		content, ok := out[name]
+		if !ok {
+			if content, ok = existing[name]; !ok {
+				return nil, fmt.Errorf("%s: cannot insert at %q, file does not exist", name, f.GetInsertionPoint())
+			}
+		}
+
+		content, err := insertAt(content, f.GetInsertionPoint(), f.GetContent())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		out[name] = content
+	}
+
+	return out, nil
+}
+
+// validateResponseFileName returns an error if name, as emitted by a plugin,
+// would be written outside of the output directory. As with protoc, names must
+// be relative, slash-separated paths without ".." components.
+func validateResponseFileName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("file name is empty")
+	case strings.HasPrefix(name, "/") || strings.Contains(name, "\\") || !filepath.IsLocal(filepath.FromSlash(name)):
+		return fmt.Errorf("%s: file name must be a relative path", name)
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return fmt.Errorf("%s: file name must not contain \"..\"", name)
+		}
+	}
+
+	return nil
+}
+
+// insertAt adds text to content immediately before the line containing the
+// named insertion point marker, indenting each non-empty line of text to
+// match the marker.
+func insertAt(content, point, text string) (string, error) {
+	idx := strings.Index(content, fmt.Sprintf(insertionPointMarker, point))
+	if idx < 0 {
+		return "", fmt.Errorf("insertion point %q not found", point)
+	}
+
+	start := strings.LastIndex(content[:idx], "\n") + 1
+	prefix := content[start:idx]
+	indent := prefix[:len(prefix)-len(strings.TrimLeft(prefix, " \t"))]
+
+	b := &strings.Builder{}
+	b.WriteString(content[:start])
+	for _, line := range splitLines(text) {
+		if line != "\n" {
+			b.WriteString(indent)
+		}
+		b.WriteString(line)
+	}
+	b.WriteString(content[start:])
+
+	return b.String(), nil
+}
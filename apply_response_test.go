@@ -0,0 +1,155 @@
+package pgs
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyResponse(t *testing.T) {
+	t.Parallel()
+
+	file := func(name, point, content string) *plugin_go.CodeGeneratorResponse_File {
+		f := &plugin_go.CodeGeneratorResponse_File{Content: proto.String(content)}
+		if name != "" {
+			f.Name = proto.String(name)
+		}
+		if point != "" {
+			f.InsertionPoint = proto.String(point)
+		}
+		return f
+	}
+
+	t.Run("files and appends", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := ApplyResponse(&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+			file("foo.txt", "", "foo"),
+			file("", "", "bar"),
+			file("", "", "baz"),
+			file("qux.txt", "", "qux"),
+		}}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"foo.txt": "foobarbaz",
+			"qux.txt": "qux",
+		}, out)
+	})
+
+	t.Run("insertion points", func(t *testing.T) {
+		t.Parallel()
+
+		existing := map[string]string{
+			"disk.go":  "package disk\n\n  // @@protoc_insertion_point(imports)\n",
+			"other.go": "package other\n",
+		}
+
+		out, err := ApplyResponse(&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+			file("gen.go", "", "package gen\n\nfunc f() {\n\t// @@protoc_insertion_point(body)\n}\n"),
+			file("gen.go", "body", "a()\n\nb()"),
+			file("", "", "\nc()\n"),
+			file("gen.go", "body", "d()\n"),
+			file("disk.go", "imports", "import \"fmt\"\n"),
+		}}, existing)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{
+			"gen.go":  "package gen\n\nfunc f() {\n\ta()\n\n\tb()\n\tc()\n\td()\n\t// @@protoc_insertion_point(body)\n}\n",
+			"disk.go": "package disk\n\n  import \"fmt\"\n  // @@protoc_insertion_point(imports)\n",
+		}, out)
+		assert.Equal(t, "package other\n", existing["other.go"])
+	})
+
+	t.Run("generated over existing", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := ApplyResponse(&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+			file("foo.go", "", "new // @@protoc_insertion_point(x)\n"),
+			file("foo.go", "x", "y\n"),
+		}}, map[string]string{"foo.go": "old // @@protoc_insertion_point(x)\n"})
+		require.NoError(t, err)
+		assert.Equal(t, "y\nnew // @@protoc_insertion_point(x)\n", out["foo.go"])
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name string
+			resp *plugin_go.CodeGeneratorResponse
+		}{
+			{
+				"response error",
+				&plugin_go.CodeGeneratorResponse{Error: proto.String("foo")},
+			},
+			{
+				"leading append",
+				&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+					file("", "", "foo"),
+				}},
+			},
+			{
+				"duplicate file",
+				&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+					file("foo.txt", "", "foo"),
+					file("foo.txt", "", "bar"),
+				}},
+			},
+			{
+				"missing file",
+				&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+					file("foo.go", "bar", "baz"),
+				}},
+			},
+			{
+				"missing insertion point",
+				&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+					file("foo.go", "", "package foo\n"),
+					file("foo.go", "bar", "baz"),
+				}},
+			},
+			{
+				"absolute name",
+				&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+					file("/etc/foo.txt", "", "foo"),
+				}},
+			},
+			{
+				"parent directory",
+				&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+					file("../foo.txt", "", "foo"),
+				}},
+			},
+			{
+				"nested parent directory",
+				&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+					file("foo/../../bar.txt", "", "foo"),
+				}},
+			},
+		}
+
+		for _, tc := range tests {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+				_, err := ApplyResponse(tc.resp, nil)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestValidateResponseFileName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"foo.txt", "foo/bar.txt", "./foo.txt", "foo..bar.txt"} {
+		assert.NoError(t, validateResponseFileName(name), name)
+	}
+
+	for _, name := range []string{"", "/foo.txt", "..", "../foo.txt", "foo/../bar.txt", `foo\bar.txt`} {
+		assert.Error(t, validateResponseFileName(name), name)
+	}
+}
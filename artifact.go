@@ -25,8 +25,9 @@ type Template interface {
 }
 
 // GeneratorArtifact describes an Artifact that uses protoc for code generation.
-// GeneratorArtifacts must be valid UTF8. To create binary files, use one of
-// the "custom" Artifact types.
+// GeneratorArtifacts must be valid UTF8, with the exception of
+// GeneratorBinaryFile and GeneratorBinaryTemplateFile, which may contain
+// arbitrary bytes.
 type GeneratorArtifact interface {
 	Artifact
 
@@ -48,13 +49,18 @@ type TemplateArtifact struct {
 }
 
 func (ta TemplateArtifact) render() (string, error) {
+	b, err := ta.renderBytes()
+	return string(b), err
+}
+
+func (ta TemplateArtifact) renderBytes() ([]byte, error) {
 	buf := &bytes.Buffer{}
 
 	if err := ta.Template.Execute(buf, ta.Data); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return buf.String(), nil
+	return buf.Bytes(), nil
 }
 
 // A GeneratorFile Artifact describes a file to be generated using protoc.
@@ -123,6 +129,74 @@ func (f GeneratorTemplateFile) ProtoFile() (*plugin_go.CodeGeneratorResponse_Fil
 	}, nil
 }
 
+// A GeneratorBinaryFile Artifact describes a file containing arbitrary bytes
+// (eg, a serialized descriptor set or an image) to be generated using protoc.
+type GeneratorBinaryFile struct {
+	GeneratorArtifact
+
+	// Name of the file to generate, relative to the protoc-plugin's generation
+	// output directory.
+	Name string
+
+	// Contents are the body of the file.
+	Contents []byte
+
+	// Overwrite specifies whether or not this file should replace another file
+	// with the same name if a prior Plugin or Module has created one.
+	Overwrite bool
+}
+
+// ProtoFile satisfies the GeneratorArtifact interface. An error is returned if
+// the name field is not a path relative to and within the protoc-plugin's
+// generation output directory.
+func (f GeneratorBinaryFile) ProtoFile() (*plugin_go.CodeGeneratorResponse_File, error) {
+	name, err := cleanGeneratorFileName(f.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin_go.CodeGeneratorResponse_File{
+		Name:    proto.String(name),
+		Content: proto.String(string(f.Contents)),
+	}, nil
+}
+
+// A GeneratorBinaryTemplateFile describes a file containing arbitrary bytes to
+// be generated using protoc from a Template. Unlike GeneratorTemplateFile, the
+// rendered output is not required to be valid UTF8.
+type GeneratorBinaryTemplateFile struct {
+	GeneratorArtifact
+	TemplateArtifact
+
+	// Name of the file to generate, relative to the protoc-plugin's generation
+	// output directory.
+	Name string
+
+	// Overwrite specifies whether or not this file should replace another file
+	// with the same name if a prior Plugin or Module has created one.
+	Overwrite bool
+}
+
+// ProtoFile satisfies the GeneratorArtifact interface. An error is returned if
+// the name field is not a path relative to and within the protoc-plugin's
+// generation output directory or if there is an error executing the Template.
+func (f GeneratorBinaryTemplateFile) ProtoFile() (*plugin_go.CodeGeneratorResponse_File, error) {
+	name, err := cleanGeneratorFileName(f.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := f.renderBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin_go.CodeGeneratorResponse_File{
+		Name:    proto.String(name),
+		Content: proto.String(string(content)),
+	}, nil
+}
+
 // A GeneratorAppend Artifact appends content to the end of the specified protoc
 // generated file. This Artifact can only be used if another Module generates a
 // file with the same name.
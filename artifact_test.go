@@ -5,6 +5,8 @@ import (
 
 	"text/template"
 
+	"github.com/golang/protobuf/proto"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,6 +61,59 @@ func TestGeneratorTemplateFile_ProtoFile(t *testing.T) {
 	assert.Equal(t, "bar", pb.GetContent())
 }
 
+func TestGeneratorBinaryFile_ProtoFile(t *testing.T) {
+	t.Parallel()
+
+	f := GeneratorBinaryFile{
+		Name:     "..",
+		Contents: []byte{0xff, 0x00, 0xfe},
+	}
+
+	pb, err := f.ProtoFile()
+	assert.Error(t, err)
+	assert.Nil(t, pb)
+
+	f.Name = "foo.bin"
+	pb, err = f.ProtoFile()
+	assert.NoError(t, err)
+	assert.Equal(t, f.Name, pb.GetName())
+	assert.Equal(t, f.Contents, []byte(pb.GetContent()))
+
+	b, err := proto.Marshal(&plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{pb}})
+	assert.NoError(t, err)
+
+	resp := new(plugin_go.CodeGeneratorResponse)
+	assert.NoError(t, proto.Unmarshal(b, resp))
+	assert.Equal(t, f.Contents, []byte(resp.File[0].GetContent()))
+}
+
+func TestGeneratorBinaryTemplateFile_ProtoFile(t *testing.T) {
+	t.Parallel()
+
+	f := GeneratorBinaryTemplateFile{
+		Name: ".",
+		TemplateArtifact: TemplateArtifact{
+			Template: badArtifactTpl,
+			Data:     "\xff\x00",
+		},
+	}
+
+	pb, err := f.ProtoFile()
+	assert.Error(t, err)
+	assert.Nil(t, pb)
+
+	f.Name = "foo"
+	pb, err = f.ProtoFile()
+	assert.Error(t, err)
+	assert.Nil(t, pb)
+
+	f.Template = artifactTpl
+	pb, err = f.ProtoFile()
+	assert.NoError(t, err)
+	assert.Equal(t, f.Name, pb.GetName())
+	assert.Equal(t, []byte{0xff, 0x00}, []byte(pb.GetContent()))
+}
+
 func TestGeneratorAppend_ProtoFile(t *testing.T) {
 	t.Parallel()
 
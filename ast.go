@@ -1,8 +1,12 @@
 package pgs
 
 import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 // AST encapsulates the entirety of the input CodeGeneratorRequest from protoc,
@@ -21,15 +25,44 @@ type AST interface {
 	// (FQN). The FQN uses dot notation of the form ".{package}.{entity}", or the
 	// input path for Files.
 	Lookup(name string) (Entity, bool)
+
+	// ToFileDescriptorSet returns the descriptors of every File in the AST,
+	// including any changes applied via a Mutator. Files are ordered as in the
+	// input, with dependencies preceding the files that import them. The
+	// descriptors are copies and may be modified freely.
+	ToFileDescriptorSet() *descriptor.FileDescriptorSet
+
+	// ToCodeGeneratorRequest returns a CodeGeneratorRequest equivalent to the
+	// one the AST was built from, with its ProtoFile replaced by the
+	// descriptors from ToFileDescriptorSet. SourceFileDescriptors are omitted,
+	// as they would not reflect any changes to the AST. The request may be
+	// marshaled and passed to another protoc plugin, allowing PG* to act as a
+	// preprocessor.
+	ToCodeGeneratorRequest() *plugin_go.CodeGeneratorRequest
+
+	// ReflectFiles returns the protoreflect descriptors of every File in the
+	// AST, resolved via protodesc. These back the ReflectDescriptor methods of
+	// its entities, which return nil if an error is returned here. The
+	// descriptors are built for the entire input on first use (including by
+	// any ReflectDescriptor call), and rebuilt after changes from a Mutator.
+	ReflectFiles() (*protoregistry.Files, error)
 }
 
 type graph struct {
 	d Debugger
 
+	req        *plugin_go.CodeGeneratorRequest
+	files      []File
 	targets    map[string]File
 	packages   map[string]Package
 	entities   map[string]Entity
 	extensions []Extension
+	reg        *registry
+
+	// lazy indexes the files not yet hydrated if the graph is built lazily.
+	// Hydration is guarded by mu.
+	lazy *lazyIndex
+	mu   sync.Mutex
 }
 
 func (g *graph) Targets() map[string]File { return g.targets }
@@ -37,10 +70,60 @@ func (g *graph) Targets() map[string]File { return g.targets }
 func (g *graph) Packages() map[string]Package { return g.packages }
 
 func (g *graph) Lookup(name string) (Entity, bool) {
-	e, ok := g.entities[name]
-	return e, ok
+	if g.lazy == nil {
+		e, ok := g.entities[name]
+		return e, ok
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	e := g.lookupLocked(name)
+	return e, e != nil
+}
+
+func (g *graph) ToFileDescriptorSet() *descriptor.FileDescriptorSet {
+	if g.lazy != nil {
+		// the input descriptors are shared with the hydrated files
+		fdset := &descriptor.FileDescriptorSet{
+			File: make([]*descriptor.FileDescriptorProto, len(g.req.GetProtoFile())),
+		}
+		for i, fd := range g.req.GetProtoFile() {
+			fdset.File[i] = proto.Clone(fd).(*descriptor.FileDescriptorProto)
+		}
+		return fdset
+	}
+
+	fdset := &descriptor.FileDescriptorSet{
+		File: make([]*descriptor.FileDescriptorProto, len(g.files)),
+	}
+
+	for i, f := range g.files {
+		fdset.File[i] = proto.Clone(f.Descriptor()).(*descriptor.FileDescriptorProto)
+	}
+
+	return fdset
 }
 
+func (g *graph) ToCodeGeneratorRequest() *plugin_go.CodeGeneratorRequest {
+	req := &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: append([]string(nil), g.req.GetFileToGenerate()...),
+		ProtoFile:      g.ToFileDescriptorSet().File,
+	}
+
+	if p := g.req.GetParameter(); p != "" {
+		req.Parameter = proto.String(p)
+	}
+
+	if v := g.req.GetCompilerVersion(); v != nil {
+		req.CompilerVersion = proto.Clone(v).(*plugin_go.Version)
+	}
+
+	return req
+}
+
+func (g *graph) ReflectFiles() (*protoregistry.Files, error) { return g.reg.Files() }
+
 // ProcessDescriptors is deprecated; use ProcessCodeGeneratorRequest instead
 func ProcessDescriptors(debug Debugger, req *plugin_go.CodeGeneratorRequest) AST {
 	return ProcessCodeGeneratorRequest(debug, req)
@@ -51,10 +134,12 @@ func ProcessDescriptors(debug Debugger, req *plugin_go.CodeGeneratorRequest) AST
 func ProcessCodeGeneratorRequest(debug Debugger, req *plugin_go.CodeGeneratorRequest) AST {
 	g := &graph{
 		d:          debug,
+		req:        req,
 		targets:    make(map[string]File, len(req.GetFileToGenerate())),
 		packages:   make(map[string]Package),
 		entities:   make(map[string]Entity),
 		extensions: []Extension{},
+		reg:        newRegistry(debug, req.GetProtoFile()),
 	}
 
 	for _, f := range req.GetFileToGenerate() {
@@ -63,7 +148,9 @@ func ProcessCodeGeneratorRequest(debug Debugger, req *plugin_go.CodeGeneratorReq
 
 	for _, f := range req.GetProtoFile() {
 		pkg := g.hydratePackage(f)
-		pkg.addFile(g.hydrateFile(pkg, f))
+		fl := g.hydrateFile(pkg, f)
+		pkg.addFile(fl)
+		g.files = append(g.files, fl)
 	}
 
 	for _, e := range g.extensions {
@@ -136,6 +223,7 @@ func (g *graph) hydrateFile(pkg Package, f *descriptor.FileDescriptorProto) File
 	fl := &file{
 		pkg:  pkg,
 		desc: f,
+		reg:  g.reg,
 	}
 	if pkg := f.GetPackage(); pkg != "" {
 		fl.fqn = "." + pkg
@@ -270,6 +358,14 @@ func (g *graph) hydrateMethod(s Service, md *descriptor.MethodDescriptorProto) M
 	m.fqn = fullyQualifiedName(s, m)
 	g.add(m)
 
+	if g.lazy != nil {
+		m.resolveIO = &lazyRef{fn: func() {
+			m.in = g.mustSeen(md.GetInputType()).(Message)
+			m.out = g.mustSeen(md.GetOutputType()).(Message)
+		}}
+		return m
+	}
+
 	m.in = g.mustSeen(md.GetInputType()).(Message)
 	m.out = g.mustSeen(md.GetOutputType()).(Message)
 
@@ -431,7 +527,17 @@ func (g *graph) hydrateMapFieldType(r *repT, m Message) FieldType {
 }
 
 func (g *graph) mustSeen(fqn string) Entity {
-	if existing, seen := g.entities[fqn]; seen {
+	if g.lazy != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
+
+	return g.mustSeenLocked(fqn)
+}
+
+// mustSeenLocked is mustSeen for callers already holding g.mu.
+func (g *graph) mustSeenLocked(fqn string) Entity {
+	if existing := g.lookupLocked(fqn); existing != nil {
 		return existing
 	}
 
@@ -1,16 +1,20 @@
 package pgs
 
 import (
+	"context"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
 
+	"github.com/bufbuild/protocompile"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 
 	"github.com/golang/protobuf/proto"
 	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 func readCodeGenReq(t *testing.T, dir string) *plugin_go.CodeGeneratorRequest {
@@ -37,6 +41,44 @@ func readFileDescSet(t *testing.T, filename string) *descriptor.FileDescriptorSe
 	return fdset
 }
 
+// compileRequest compiles in-memory proto sources into a CodeGeneratorRequest
+// targeting the provided files, including all of their dependencies in
+// topological order.
+func compileRequest(t *testing.T, sources map[string]string, targets ...string) *plugin_go.CodeGeneratorRequest {
+	c := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+
+	files, err := c.Compile(context.Background(), targets...)
+	require.NoError(t, err)
+
+	req := &plugin_go.CodeGeneratorRequest{FileToGenerate: targets}
+	seen := make(map[string]struct{})
+
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if _, ok := seen[fd.Path()]; ok {
+			return
+		}
+		seen[fd.Path()] = struct{}{}
+
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(fd))
+	}
+
+	for _, f := range files {
+		add(f)
+	}
+
+	return req
+}
+
 func buildGraph(t *testing.T, dir string) AST {
 	d := InitMockDebugger()
 	ast := ProcessCodeGeneratorRequest(d, readCodeGenReq(t, dir))
@@ -144,6 +186,89 @@ func TestGraph_Messages(t *testing.T) {
 		assert.Equal(t, int32(2), oo.Fields()[0].Descriptor().GetNumber())
 		assert.Equal(t, oneofFlds, oo.Fields())
 	})
+
+	t.Run("proto3 optional", func(t *testing.T) {
+		t.Parallel()
+
+		ent, ok := g.Lookup(".graph.messages.Optional")
+		require.True(t, ok)
+		msg, ok := ent.(Message)
+		require.True(t, ok)
+
+		assert.Len(t, msg.OneOfs(), 3)
+		require.Len(t, msg.RealOneOfs(), 1)
+		assert.Equal(t, "real", msg.RealOneOfs()[0].Name().String())
+		assert.Len(t, msg.SyntheticOneOfFields(), 2)
+
+		tests := []struct {
+			name                                  string
+			inRealOneOf, hasPresence, hasOptional bool
+		}{
+			{"before", false, false, false},
+			{"scalar", false, true, true},
+			{"msg", false, true, true},
+			{"inside", true, true, false},
+		}
+
+		for _, tc := range tests {
+			ent, ok := g.Lookup(".graph.messages.Optional." + tc.name)
+			require.True(t, ok)
+			fld, ok := ent.(Field)
+			require.True(t, ok)
+
+			assert.Equal(t, tc.inRealOneOf, fld.InRealOneOf(), tc.name)
+			assert.Equal(t, tc.hasPresence, fld.HasPresence(), tc.name)
+			assert.Equal(t, tc.hasOptional, fld.HasOptionalKeyword(), tc.name)
+			assert.Equal(t, tc.hasOptional, fld.Type().IsOptional(), tc.name)
+		}
+	})
+}
+
+func TestGraph_Editions(t *testing.T) {
+	t.Parallel()
+
+	g := buildGraph(t, "editions")
+
+	ent, ok := g.Lookup("editions/editions.proto")
+	require.True(t, ok)
+	f, ok := ent.(File)
+	require.True(t, ok)
+	assert.Equal(t, Editions, f.Syntax())
+	assert.Equal(t, Edition2023, f.Edition())
+
+	tests := []struct {
+		name                                   string
+		hasPresence, required, packed, utf8Enf bool
+	}{
+		{"explicit", true, false, false, false},
+		{"implicit", false, false, false, false},
+		{"required", true, true, false, false},
+		{"packed", false, false, true, false},
+		{"expanded", false, false, false, false},
+		{"str", true, false, false, false},
+		{"Verified.str", true, false, false, true},
+	}
+
+	for _, tc := range tests {
+		ent, ok := g.Lookup(".graph.editions.Editions." + tc.name)
+		require.True(t, ok, tc.name)
+		fld, ok := ent.(Field)
+		require.True(t, ok, tc.name)
+
+		assert.Equal(t, tc.hasPresence, fld.HasPresence(), tc.name)
+		assert.Equal(t, tc.required, fld.Required(), tc.name)
+		assert.Equal(t, tc.packed, fld.IsPacked(), tc.name)
+		assert.Equal(t, tc.utf8Enf, fld.EnforceUTF8(), tc.name)
+		assert.False(t, fld.HasOptionalKeyword(), tc.name)
+	}
+
+	open, ok := g.Lookup(".graph.editions.Open")
+	require.True(t, ok)
+	assert.False(t, open.(Enum).IsClosed())
+
+	closed, ok := g.Lookup(".graph.editions.Closed")
+	require.True(t, ok)
+	assert.True(t, closed.(Enum).IsClosed())
 }
 
 func TestGraph_Services(t *testing.T) {
@@ -487,3 +612,69 @@ func TestGraph_Bidirectional_Recursive(t *testing.T) {
 		})
 	}
 }
+
+func TestAST_ToFileDescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	files := append(printerDeps(), proto3PrinterFile())
+	ast := printerAST(t, files...)
+
+	fdset := ast.ToFileDescriptorSet()
+	require.Len(t, fdset.GetFile(), 3)
+	for i, f := range files {
+		assert.True(t, proto.Equal(f, fdset.GetFile()[i]), f.GetName())
+	}
+
+	fdset.File[2].Name = proto.String("changed.proto")
+	assert.Equal(t, "foo/foo.proto", ast.Targets()["foo/foo.proto"].Descriptor().GetName())
+
+	mu, err := NewMutator(ast)
+	require.NoError(t, err)
+	_, err = mu.AddMessage(ast.Targets()["foo/foo.proto"], &descriptor.DescriptorProto{Name: proto.String("Added")})
+	require.NoError(t, err)
+
+	msgs := ast.ToFileDescriptorSet().GetFile()[2].GetMessageType()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "Added", msgs[1].GetName())
+
+	rebuilt := ProcessFileDescriptorSet(InitMockDebugger(), ast.ToFileDescriptorSet())
+	_, ok := rebuilt.Lookup(".foo.Added")
+	assert.True(t, ok)
+}
+
+func TestAST_ToCodeGeneratorRequest(t *testing.T) {
+	t.Parallel()
+
+	files := append(printerDeps(), proto3PrinterFile())
+	req := &plugin_go.CodeGeneratorRequest{
+		FileToGenerate:        []string{"foo/foo.proto"},
+		Parameter:             proto.String("foo=bar"),
+		ProtoFile:             files,
+		SourceFileDescriptors: files[2:],
+		CompilerVersion:       &plugin_go.Version{Major: proto.Int32(3)},
+	}
+
+	ast := ProcessCodeGeneratorRequest(InitMockDebugger(), req)
+	out := ast.ToCodeGeneratorRequest()
+
+	assert.Equal(t, req.GetFileToGenerate(), out.GetFileToGenerate())
+	assert.Equal(t, "foo=bar", out.GetParameter())
+	assert.Equal(t, int32(3), out.GetCompilerVersion().GetMajor())
+	assert.Empty(t, out.GetSourceFileDescriptors())
+	require.Len(t, out.GetProtoFile(), len(files))
+	for i, f := range files {
+		assert.True(t, proto.Equal(f, out.GetProtoFile()[i]), f.GetName())
+	}
+
+	b, err := proto.Marshal(out)
+	require.NoError(t, err)
+
+	decoded := &plugin_go.CodeGeneratorRequest{}
+	require.NoError(t, proto.Unmarshal(b, decoded))
+	rebuilt := ProcessCodeGeneratorRequest(InitMockDebugger(), decoded)
+	assert.Contains(t, rebuilt.Targets(), "foo/foo.proto")
+
+	empty := (&graph{}).ToCodeGeneratorRequest()
+	assert.Empty(t, empty.GetProtoFile())
+	assert.Nil(t, empty.Parameter)
+}
@@ -1,6 +1,9 @@
 package pgs
 
-import "path/filepath"
+import (
+	"fmt"
+	"path/filepath"
+)
 
 // BuildContext tracks code generation relative to an output path. By default,
 // BuildContext's path is relative to the output location specified when
@@ -40,6 +43,17 @@ type BuildContext interface {
 	// Parameters returns the command line parameters passed in from protoc,
 	// mutated with any provided ParamMutators via InitOptions.
 	Parameters() Parameters
+
+	// FailAt behaves like Fail, but prefixes the message with the position of
+	// the entity e in its source proto file (eg, `foo.proto:12:3: message`).
+	// The position precedes any prefixes pushed onto the context (eg,
+	// `foo.proto:12:3: [mod] message`).
+	FailAt(e Entity, v ...interface{})
+
+	// WarnAt logs a warning prefixed with the position of the entity e in its
+	// source proto file (eg, `foo.proto:12:3: warning: [mod] message`). Unlike
+	// FailAt, execution continues.
+	WarnAt(e Entity, v ...interface{})
 }
 
 // Context creates a new BuildContext with the provided debugger and initial
@@ -72,6 +86,14 @@ func (c prefixContext) CheckErr(err error, v ...interface{})   { c.d.CheckErr(er
 func (c prefixContext) Assert(expr bool, v ...interface{})     { c.d.Assert(expr, v...) }
 func (c prefixContext) Exit(code int)                          { c.d.Exit(code) }
 
+func (c prefixContext) FailAt(e Entity, v ...interface{}) {
+	failAt(c.d, PositionOf(e).String(), fmt.Sprint(v...))
+}
+
+func (c prefixContext) WarnAt(e Entity, v ...interface{}) {
+	logAt(c.d, PositionOf(e).String()+": warning", fmt.Sprint(v...))
+}
+
 func (c prefixContext) Parameters() Parameters          { return c.parent.Parameters() }
 func (c prefixContext) OutputPath() string              { return c.parent.OutputPath() }
 func (c prefixContext) JoinPath(name ...string) string  { return c.parent.JoinPath(name...) }
@@ -2,8 +2,10 @@ package pgs
 
 import (
 	"errors"
+	"io/ioutil"
 	"testing"
 
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -67,6 +69,45 @@ func TestPrefixContext_Failf(t *testing.T) {
 	assert.True(t, d.Failed())
 }
 
+func TestPrefixContext_FailAt(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	c := initPrefixContext(nil, d, "foo")
+
+	m := dummyMsg()
+	m.addSourceCodeInfo(sci{&descriptor.SourceCodeInfo_Location{Span: []int32{11, 2, 20}}})
+
+	c.FailAt(m, "bar ", "baz")
+	assert.True(t, d.Failed())
+
+	b, _ := ioutil.ReadAll(d.Output())
+	assert.Equal(t, "file.proto:12:3: [foo] bar baz\n", string(b))
+}
+
+func TestPrefixContext_FailAt_Collected(t *testing.T) {
+	t.Parallel()
+
+	diags := &diagnostics{}
+	c := initPrefixContext(nil, initCollectingDebugger(InitMockDebugger(), diags), "foo").Push("bar")
+
+	m := dummyMsg()
+	m.addSourceCodeInfo(sci{&descriptor.SourceCodeInfo_Location{Span: []int32{11, 2, 20}}})
+
+	c.FailAt(m, "baz")
+	assert.Equal(t, []Diagnostic{{Message: "file.proto:12:3: [foo][bar] baz"}}, diags.all())
+}
+
+func TestPrefixContext_WarnAt(t *testing.T) {
+	t.Parallel()
+
+	l := newMockLogger()
+	c := initPrefixContext(nil, &rootDebugger{l: l}, "foo")
+
+	c.WarnAt(dummyMsg(), "bar")
+	assert.Equal(t, "file.proto: warning: [foo] bar\n", l.buf.String())
+}
+
 func TestPrefixContext_CheckErr(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,302 @@
+package pgs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"google.golang.org/protobuf/proto"
+)
+
+// cacheVersion is mixed into every cache key, invalidating existing entries
+// whenever the format of the cached Artifacts changes.
+const cacheVersion = "pgs-cache-v1"
+
+// cacheMaxAge is the duration after which an unused cache entry is removed.
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// Cache enables reusing the Artifacts of each PerFileModule across runs,
+// storing them in dir. Artifacts are keyed by the Module's name and version,
+// the Generator's parameters, and the descriptors of the target File and its
+// TransitiveImports. The version is provided by a VersionedModule, otherwise a
+// hash of the plugin executable is used, so rebuilding the plugin invalidates
+// its entries. The cache is stored on the file system provided via the
+// FileSystem option, if any.
+//
+// Entries that have not been used for 30 days are removed at the end of each
+// run, bounding the growth of dir.
+//
+// Template Artifacts are rendered before they are cached and are reused as
+// their non-template equivalents (eg, a GeneratorTemplateFile is reused as a
+// GeneratorFile). Output of a Module that reports a failure, or that returns
+// Artifacts of an unknown type, is never cached. Data published by a cached
+// Module is not available to its dependents when its Execute method is
+// skipped.
+func Cache(dir string) InitOption { return func(g *Generator) { g.cacheDir = dir } }
+
+// artifactCache loads and stores the Artifacts of PerFileModules.
+type artifactCache struct {
+	Debugger
+
+	fs     afero.Fs
+	dir    string
+	params Parameters
+
+	// exe lazily hashes the plugin executable, versioning Modules that do not
+	// implement VersionedModule
+	exeOnce sync.Once
+	exeHash string
+	exeErr  error
+}
+
+// key computes the cache key for the output of m against f.
+func (c *artifactCache) key(m Module, f File) (string, error) {
+	h := sha256.New()
+	writeCacheField(h, []byte(cacheVersion))
+	writeCacheField(h, []byte(m.Name()))
+	writeCacheField(h, []byte(c.params.String()))
+
+	if vm, ok := m.(VersionedModule); ok {
+		writeCacheField(h, []byte("module:"+vm.Version()))
+	} else {
+		exe, err := c.executableHash()
+		if err != nil {
+			return "", err
+		}
+		writeCacheField(h, []byte("executable:"+exe))
+	}
+
+	imports := append([]File(nil), f.TransitiveImports()...)
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Name().String() < imports[j].Name().String() })
+
+	opts := proto.MarshalOptions{Deterministic: true}
+	for _, fl := range append([]File{f}, imports...) {
+		b, err := opts.Marshal(fl.Descriptor())
+		if err != nil {
+			return "", err
+		}
+		writeCacheField(h, []byte(fl.Name().String()))
+		writeCacheField(h, b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// executableHash returns the hash of the running plugin executable, computing
+// it on first use.
+func (c *artifactCache) executableHash() (string, error) {
+	c.exeOnce.Do(func() { c.exeHash, c.exeErr = hashExecutable() })
+	return c.exeHash, c.exeErr
+}
+
+// hashExecutable returns the SHA-256 hash of the running executable.
+func hashExecutable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeCacheField writes b to h, prefixed by its length so that adjacent
+// fields cannot collide.
+func writeCacheField(h hash.Hash, b []byte) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+	h.Write(n[:])
+	h.Write(b)
+}
+
+func (c *artifactCache) path(key string) string { return filepath.Join(c.dir, key+".json") }
+
+// load returns the Artifacts cached under key, if any.
+func (c *artifactCache) load(key string) ([]Artifact, bool) {
+	b, err := afero.ReadFile(c.fs, c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	// refresh the entry's modification time, so that it is not pruned while
+	// still in use
+	now := time.Now()
+	if err = c.fs.Chtimes(c.path(key), now, now); err != nil {
+		c.Debug("unable to touch cache entry: ", err)
+	}
+
+	var entries []cachedArtifact
+	if err = json.Unmarshal(b, &entries); err != nil {
+		c.Debug("ignoring malformed cache entry: ", c.path(key))
+		return nil, false
+	}
+
+	arts := make([]Artifact, 0, len(entries))
+	for _, e := range entries {
+		a, err := e.artifact()
+		if err != nil {
+			c.Debug("ignoring malformed cache entry: ", c.path(key))
+			return nil, false
+		}
+		arts = append(arts, a)
+	}
+
+	return arts, true
+}
+
+// store caches arts under key. Failures are logged, as the Artifacts are still
+// persisted.
+func (c *artifactCache) store(key string, arts []Artifact) {
+	entries := make([]cachedArtifact, 0, len(arts))
+	for _, a := range arts {
+		e, err := newCachedArtifact(a)
+		if err != nil {
+			c.Debug("not caching artifacts: ", err)
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		c.Debug("not caching artifacts: ", err)
+		return
+	}
+
+	if err = c.fs.MkdirAll(c.dir, 0755); err != nil {
+		c.Debug("unable to create cache directory: ", err)
+		return
+	}
+
+	if err = afero.WriteFile(c.fs, c.path(key), b, 0644); err != nil {
+		c.Debug("unable to write cache entry: ", err)
+	}
+}
+
+// prune removes the entries of the cache that have not been stored or loaded
+// within cacheMaxAge.
+func (c *artifactCache) prune() {
+	infos, err := afero.ReadDir(c.fs, c.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.Debug("unable to read cache directory: ", err)
+		}
+		return
+	}
+
+	cutoff := time.Now().Add(-cacheMaxAge)
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".json") || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		name := filepath.Join(c.dir, info.Name())
+		c.Debug("removing unused cache entry: ", name)
+		if err = c.fs.Remove(name); err != nil {
+			c.Debug("unable to remove cache entry: ", err)
+		}
+	}
+}
+
+// cachedArtifact is the serialized form of a cached Artifact.
+type cachedArtifact struct {
+	Type           string      `json:"type"`
+	Name           string      `json:"name,omitempty"`
+	InsertionPoint string      `json:"insertion_point,omitempty"`
+	Contents       []byte      `json:"contents,omitempty"`
+	Perms          os.FileMode `json:"perms,omitempty"`
+	Overwrite      bool        `json:"overwrite,omitempty"`
+}
+
+const (
+	cachedGeneratorFile       = "generator_file"
+	cachedGeneratorBinaryFile = "generator_binary_file"
+	cachedGeneratorAppend     = "generator_append"
+	cachedGeneratorInjection  = "generator_injection"
+	cachedCustomFile          = "custom_file"
+	cachedGeneratorError      = "generator_error"
+)
+
+// newCachedArtifact converts a to its serialized form, rendering it if it is
+// a template Artifact.
+func newCachedArtifact(a Artifact) (cachedArtifact, error) {
+	var (
+		e   cachedArtifact
+		err error
+	)
+
+	switch a := a.(type) {
+	case GeneratorFile:
+		e = cachedArtifact{Type: cachedGeneratorFile, Name: a.Name, Contents: []byte(a.Contents), Overwrite: a.Overwrite}
+	case GeneratorTemplateFile:
+		e = cachedArtifact{Type: cachedGeneratorFile, Name: a.Name, Overwrite: a.Overwrite}
+		e.Contents, err = a.renderBytes()
+	case GeneratorBinaryFile:
+		e = cachedArtifact{Type: cachedGeneratorBinaryFile, Name: a.Name, Contents: a.Contents, Overwrite: a.Overwrite}
+	case GeneratorBinaryTemplateFile:
+		e = cachedArtifact{Type: cachedGeneratorBinaryFile, Name: a.Name, Overwrite: a.Overwrite}
+		e.Contents, err = a.renderBytes()
+	case GeneratorAppend:
+		e = cachedArtifact{Type: cachedGeneratorAppend, Name: a.FileName, Contents: []byte(a.Contents)}
+	case GeneratorTemplateAppend:
+		e = cachedArtifact{Type: cachedGeneratorAppend, Name: a.FileName}
+		e.Contents, err = a.renderBytes()
+	case GeneratorInjection:
+		e = cachedArtifact{Type: cachedGeneratorInjection, Name: a.FileName, InsertionPoint: a.InsertionPoint, Contents: []byte(a.Contents)}
+	case GeneratorTemplateInjection:
+		e = cachedArtifact{Type: cachedGeneratorInjection, Name: a.FileName, InsertionPoint: a.InsertionPoint}
+		e.Contents, err = a.renderBytes()
+	case CustomFile:
+		e = cachedArtifact{Type: cachedCustomFile, Name: a.Name, Contents: []byte(a.Contents), Perms: a.Perms, Overwrite: a.Overwrite}
+	case CustomTemplateFile:
+		e = cachedArtifact{Type: cachedCustomFile, Name: a.Name, Perms: a.Perms, Overwrite: a.Overwrite}
+		e.Contents, err = a.renderBytes()
+	case GeneratorError:
+		e = cachedArtifact{Type: cachedGeneratorError, Contents: []byte(a.Message)}
+	default:
+		err = fmt.Errorf("unsupported artifact type: %T", a)
+	}
+
+	return e, err
+}
+
+// artifact converts e back to an Artifact.
+func (e cachedArtifact) artifact() (Artifact, error) {
+	switch e.Type {
+	case cachedGeneratorFile:
+		return GeneratorFile{Name: e.Name, Contents: string(e.Contents), Overwrite: e.Overwrite}, nil
+	case cachedGeneratorBinaryFile:
+		return GeneratorBinaryFile{Name: e.Name, Contents: e.Contents, Overwrite: e.Overwrite}, nil
+	case cachedGeneratorAppend:
+		return GeneratorAppend{FileName: e.Name, Contents: string(e.Contents)}, nil
+	case cachedGeneratorInjection:
+		return GeneratorInjection{FileName: e.Name, InsertionPoint: e.InsertionPoint, Contents: string(e.Contents)}, nil
+	case cachedCustomFile:
+		return CustomFile{Name: e.Name, Contents: string(e.Contents), Perms: e.Perms, Overwrite: e.Overwrite}, nil
+	case cachedGeneratorError:
+		return GeneratorError{Message: string(e.Contents)}, nil
+	default:
+		return nil, fmt.Errorf("unknown cached artifact type: %q", e.Type)
+	}
+}
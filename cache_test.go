@@ -0,0 +1,251 @@
+package pgs
+
+import (
+	"errors"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type perFileModule struct {
+	*ModuleBase
+	perFile  bool
+	executed []string
+}
+
+func (m *perFileModule) Name() string  { return "per_file" }
+func (m *perFileModule) PerFile() bool { return m.perFile }
+
+func (m *perFileModule) Execute(targets map[string]File, pkgs map[string]Package) []Artifact {
+	tpl := template.Must(template.New("").Parse("{{ .Name }}"))
+	for n, f := range targets {
+		m.executed = append(m.executed, n)
+		for _, msg := range f.Messages() {
+			m.AddGeneratorTemplateFile(msg.Name().String()+".txt", tpl, msg)
+		}
+	}
+	return m.Artifacts()
+}
+
+func cacheAST(t *testing.T, bName string) AST {
+	files := []*descriptor.FileDescriptorProto{
+		{
+			Name:        proto.String("dep.proto"),
+			Package:     proto.String("dep"),
+			MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Dep")}},
+		},
+		{
+			Name:        proto.String("a.proto"),
+			Package:     proto.String("a"),
+			Dependency:  []string{"dep.proto"},
+			MessageType: []*descriptor.DescriptorProto{{Name: proto.String("A")}},
+		},
+		{
+			Name:        proto.String("b.proto"),
+			Package:     proto.String("b"),
+			MessageType: []*descriptor.DescriptorProto{{Name: proto.String(bName)}},
+		},
+	}
+
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"a.proto", "b.proto"},
+		ProtoFile:      files,
+	})
+	require.False(t, d.Failed())
+	return ast
+}
+
+func TestStandardWorkflow_Run_Cache(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+
+	run := func(ast AST, params Parameters, perFile bool) (*perFileModule, []Artifact) {
+		g := Init(FileSystem(fs), Cache("cache"))
+		g.workflow = &standardWorkflow{Generator: g}
+		g.params = params
+
+		m := &perFileModule{ModuleBase: &ModuleBase{}, perFile: perFile}
+		g.RegisterModule(m)
+		return m, g.workflow.Run(ast)
+	}
+
+	expected := []Artifact{
+		GeneratorFile{Name: "A.txt", Contents: "A"},
+		GeneratorFile{Name: "B.txt", Contents: "B"},
+	}
+
+	m, arts := run(cacheAST(t, "B"), Parameters{}, true)
+	assert.Equal(t, []string{"a.proto", "b.proto"}, m.executed)
+	assert.Len(t, arts, 2)
+	assert.IsType(t, GeneratorTemplateFile{}, arts[0])
+
+	m, arts = run(cacheAST(t, "B"), Parameters{}, true)
+	assert.Empty(t, m.executed)
+	assert.Equal(t, expected, arts)
+
+	m, arts = run(cacheAST(t, "C"), Parameters{}, true)
+	assert.Equal(t, []string{"b.proto"}, m.executed)
+	assert.Equal(t, GeneratorFile{Name: "A.txt", Contents: "A"}, arts[0])
+	assert.IsType(t, GeneratorTemplateFile{}, arts[1])
+
+	m, _ = run(cacheAST(t, "B"), Parameters{"foo": "bar"}, true)
+	assert.Equal(t, []string{"a.proto", "b.proto"}, m.executed)
+
+	m, _ = run(cacheAST(t, "B"), Parameters{}, false)
+	assert.Len(t, m.executed, 2)
+}
+
+func TestArtifactCache_Key(t *testing.T) {
+	t.Parallel()
+
+	c := &artifactCache{params: Parameters{}}
+	m := &perFileModule{ModuleBase: &ModuleBase{}}
+
+	ast := cacheAST(t, "B")
+	a, err := c.key(m, ast.Targets()["a.proto"])
+	require.NoError(t, err)
+	b, err := c.key(m, ast.Targets()["b.proto"])
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+
+	again, err := c.key(m, cacheAST(t, "C").Targets()["a.proto"])
+	require.NoError(t, err)
+	assert.Equal(t, a, again, "unrelated files do not affect the key")
+
+	dep := cacheAST(t, "B")
+	dep.Targets()["a.proto"].Imports()[0].Descriptor().MessageType[0].Name = proto.String("Other")
+	changed, err := c.key(m, dep.Targets()["a.proto"])
+	require.NoError(t, err)
+	assert.NotEqual(t, a, changed, "imports affect the key")
+}
+
+type versionedModule struct {
+	*perFileModule
+	version string
+}
+
+func (m versionedModule) Version() string { return m.version }
+
+func TestArtifactCache_Key_Version(t *testing.T) {
+	t.Parallel()
+
+	c := &artifactCache{params: Parameters{}}
+	f := cacheAST(t, "B").Targets()["a.proto"]
+	m := &perFileModule{ModuleBase: &ModuleBase{}}
+
+	exe, err := c.key(m, f)
+	require.NoError(t, err)
+
+	v1, err := c.key(versionedModule{m, "v1"}, f)
+	require.NoError(t, err)
+	v2, err := c.key(versionedModule{m, "v2"}, f)
+	require.NoError(t, err)
+	again, err := c.key(versionedModule{m, "v1"}, f)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, exe, v1)
+	assert.NotEqual(t, v1, v2, "the module version affects the key")
+	assert.Equal(t, v1, again)
+
+	unreadable := &artifactCache{params: Parameters{}}
+	unreadable.exeOnce.Do(func() { unreadable.exeErr = errors.New("unreadable") })
+	_, err = unreadable.key(versionedModule{m, "v1"}, f)
+	assert.NoError(t, err, "versioned modules do not hash the executable")
+	_, err = unreadable.key(m, f)
+	assert.EqualError(t, err, "unreadable")
+}
+
+func TestArtifactCache_Prune(t *testing.T) {
+	t.Parallel()
+
+	c := &artifactCache{Debugger: InitMockDebugger(), fs: afero.NewMemMapFs(), dir: "cache"}
+	c.prune()
+
+	c.store("used", nil)
+	c.store("unused", nil)
+	c.store("fresh", nil)
+	require.NoError(t, afero.WriteFile(c.fs, "cache/other.txt", nil, 0644))
+
+	old := time.Now().Add(-cacheMaxAge - time.Hour)
+	for _, key := range []string{"used", "unused"} {
+		require.NoError(t, c.fs.Chtimes(c.path(key), old, old))
+	}
+	require.NoError(t, c.fs.Chtimes("cache/other.txt", old, old))
+
+	_, ok := c.load("used")
+	require.True(t, ok)
+
+	c.prune()
+
+	for name, expected := range map[string]bool{
+		c.path("used"):    true,
+		c.path("fresh"):   true,
+		c.path("unused"):  false,
+		"cache/other.txt": true,
+	} {
+		exists, err := afero.Exists(c.fs, name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, exists, name)
+	}
+}
+
+func TestArtifactCache_LoadStore(t *testing.T) {
+	t.Parallel()
+
+	tpl := template.Must(template.New("").Parse("{{ . }}"))
+	c := &artifactCache{Debugger: InitMockDebugger(), fs: afero.NewMemMapFs(), dir: "cache"}
+
+	c.store("foo", []Artifact{
+		GeneratorFile{Name: "a", Contents: "a", Overwrite: true},
+		GeneratorTemplateFile{Name: "b", TemplateArtifact: TemplateArtifact{Template: tpl, Data: "b"}},
+		GeneratorBinaryFile{Name: "c", Contents: []byte{0xff}},
+		GeneratorBinaryTemplateFile{Name: "d", TemplateArtifact: TemplateArtifact{Template: tpl, Data: "d"}},
+		GeneratorAppend{FileName: "a", Contents: "e"},
+		GeneratorTemplateAppend{FileName: "a", TemplateArtifact: TemplateArtifact{Template: tpl, Data: "f"}},
+		GeneratorInjection{FileName: "a", InsertionPoint: "x", Contents: "g"},
+		GeneratorTemplateInjection{FileName: "a", InsertionPoint: "x", TemplateArtifact: TemplateArtifact{Template: tpl, Data: "h"}},
+		CustomFile{Name: "i", Contents: "i", Perms: 0600},
+		CustomTemplateFile{Name: "j", Perms: 0755, Overwrite: true, TemplateArtifact: TemplateArtifact{Template: tpl, Data: "j"}},
+		GeneratorError{Message: "k"},
+	})
+
+	arts, ok := c.load("foo")
+	require.True(t, ok)
+	assert.Equal(t, []Artifact{
+		GeneratorFile{Name: "a", Contents: "a", Overwrite: true},
+		GeneratorFile{Name: "b", Contents: "b"},
+		GeneratorBinaryFile{Name: "c", Contents: []byte{0xff}},
+		GeneratorBinaryFile{Name: "d", Contents: []byte("d")},
+		GeneratorAppend{FileName: "a", Contents: "e"},
+		GeneratorAppend{FileName: "a", Contents: "f"},
+		GeneratorInjection{FileName: "a", InsertionPoint: "x", Contents: "g"},
+		GeneratorInjection{FileName: "a", InsertionPoint: "x", Contents: "h"},
+		CustomFile{Name: "i", Contents: "i", Perms: 0600},
+		CustomFile{Name: "j", Contents: "j", Perms: 0755, Overwrite: true},
+		GeneratorError{Message: "k"},
+	}, arts)
+
+	_, ok = c.load("bar")
+	assert.False(t, ok)
+
+	c.store("unsupported", []Artifact{GeneratorFile{}, &CustomFile{}})
+	_, ok = c.load("unsupported")
+	assert.False(t, ok)
+
+	require.NoError(t, afero.WriteFile(c.fs, c.path("malformed"), []byte("{"), 0644))
+	_, ok = c.load("malformed")
+	assert.False(t, ok)
+
+	require.NoError(t, afero.WriteFile(c.fs, c.path("unknown"), []byte(`[{"type":"foo"}]`), 0644))
+	_, ok = c.load("unknown")
+	assert.False(t, ok)
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	pgs "github.com/vchitai/protoc-gen-star"
 )
 
@@ -12,21 +13,66 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	g := pgs.Init(pgs.ProtocInput(f))
+	g := pgs.Init(pgs.FromDescriptorSet(f))
+	printer := pgs.ProtoPrinter{
+		FileOptions: []string{
+			"(gogoproto.unmarshaler_all) = true",
+			"(gogoproto.sizer_all) = true",
+			"(gogoproto.equal_all) = true",
+			"(gogoproto.marshaler_all) = true",
+		},
+		FileImports: []string{"github.com/gogo/protobuf/gogoproto/gogo.proto"},
+	}
+
+	mu, err := pgs.NewMutator(g.AST())
+	if err != nil {
+		panic(err)
+	}
+
 	for _, f := range g.AST().Packages() {
 		if f == nil {
 			continue
 		}
 		if f.ProtoName() == "pb" {
 			for _, k := range f.Files() {
-				pgs.ExtensibleFile{File: k}.AddMethod("ProtoNameTitle", pgs.Function{
-					Method: "post",
-					Path:   "/extra",
-					Name:   "Extra",
-					Extra:  `body:"*"`,
-				})
-				fmt.Println(pgs.XFile{k}.DescribeSelf())
+				if len(k.Services()) > 0 {
+					addExtraMethod(mu, g.AST(), k)
+				}
+				if err := printer.Print(os.Stdout, k); err != nil {
+					panic(err)
+				}
 			}
 		}
 	}
 }
+
+// addExtraMethod adds an Extra method to every service in f. Its request and
+// response messages are named after the service, keeping them unique within
+// the package, and are only added if they do not already exist.
+func addExtraMethod(mu *pgs.Mutator, ast pgs.AST, f pgs.File) {
+	for _, s := range f.Services() {
+		req, res := s.Name().String()+"ExtraRequest", s.Name().String()+"ExtraResponse"
+		for _, name := range []string{req, res} {
+			if _, ok := ast.Lookup(f.FullyQualifiedName() + "." + name); ok {
+				continue
+			}
+
+			if _, err := mu.AddMessage(f, &descriptor.DescriptorProto{Name: proto.String(name)}); err != nil {
+				panic(err)
+			}
+		}
+
+		m, err := mu.AddMethod(s, &descriptor.MethodDescriptorProto{
+			Name:       proto.String("Extra"),
+			InputType:  proto.String(req),
+			OutputType: proto.String(res),
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		if err = mu.MergeOptions(m, `[google.api.http]: { post: "/extra" body: "*" }`); err != nil {
+			panic(err)
+		}
+	}
+}
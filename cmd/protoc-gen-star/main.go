@@ -0,0 +1,105 @@
+// protoc-gen-star provides tooling for developing and debugging protoc
+// plugins without protoc installed.
+//
+// The run command executes a plugin binary against a FileDescriptorSet or a
+// CodeGeneratorRequest captured by protoc-gen-debug, applying the resulting
+// CodeGeneratorResponse to an output directory as protoc would:
+//
+//	protoc-gen-star run \
+//	  -plugin ./bin/protoc-gen-example \
+//	  -descriptor_set ./testdata/fdset.bin \
+//	  -param paths=source_relative \
+//	  -out ./out \
+//	  kitchen/kitchen.proto
+//
+// To execute a PG* Generator in-process instead, use the pgsrun package
+// (github.com/vchitai/protoc-gen-star/run), which implements this command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	pgsrun "github.com/vchitai/protoc-gen-star/run"
+)
+
+const usage = `usage: protoc-gen-star <command> [flags]
+
+commands:
+  run    execute a protoc plugin against a descriptor set or captured request
+`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("protoc-gen-star: ")
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "run":
+		if err := run(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protoc-gen-star run [flags] [target.proto ...]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Targets default to every file in the descriptor set, or the files of the captured request.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	plugin := fs.String("plugin", "", "path to the protoc plugin binary to execute (required)")
+	fdsetPath := fs.String("descriptor_set", "", "path to a FileDescriptorSet, as generated by protoc --include_imports -o")
+	reqPath := fs.String("request", "", "path to a CodeGeneratorRequest, as captured by protoc-gen-debug")
+	param := fs.String("param", "", "parameters passed to the plugin, overriding those of a captured request")
+	out := fs.String("out", ".", "directory to write the generated files to")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *plugin == "":
+		return errors.New("-plugin is required")
+	case (*fdsetPath == "") == (*reqPath == ""):
+		return errors.New("exactly one of -descriptor_set or -request is required")
+	}
+
+	in := pgsrun.Input{DescriptorSet: *fdsetPath, Request: *reqPath, Targets: fs.Args()}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "param" {
+			in.Parameter = param
+		}
+	})
+
+	req, err := pgsrun.BuildRequest(in)
+	if err != nil {
+		return err
+	}
+
+	resp, err := pgsrun.Plugin(*plugin, req)
+	if err != nil {
+		return err
+	}
+
+	if err = pgsrun.Apply(*out, resp); err != nil {
+		return fmt.Errorf("%s: %v", filepath.Base(*plugin), err)
+	}
+
+	return nil
+}
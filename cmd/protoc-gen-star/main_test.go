@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pluginEnv, when set, causes the test binary to act as a protoc plugin,
+// emitting a file per target named with the variable's value as a prefix.
+const pluginEnv = "PROTOC_GEN_STAR_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if prefix, ok := os.LookupEnv(pluginEnv); ok {
+		os.Exit(testPlugin(prefix))
+	}
+	os.Exit(m.Run())
+}
+
+func testPlugin(prefix string) int {
+	in, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return 1
+	}
+
+	req := &plugin_go.CodeGeneratorRequest{}
+	if err = proto.Unmarshal(in, req); err != nil {
+		return 1
+	}
+
+	resp := &plugin_go.CodeGeneratorResponse{}
+	for _, f := range req.GetFileToGenerate() {
+		resp.File = append(resp.File, &plugin_go.CodeGeneratorResponse_File{
+			Name:    proto.String(prefix + f + ".txt"),
+			Content: proto.String(req.GetParameter()),
+		})
+	}
+
+	out, err := proto.Marshal(resp)
+	if err != nil {
+		return 1
+	}
+
+	if _, err = os.Stdout.Write(out); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func writeDescriptorSet(t *testing.T, dir string) string {
+	b, err := proto.Marshal(&descriptor.FileDescriptorSet{File: []*descriptor.FileDescriptorProto{
+		{Name: proto.String("a.proto"), Package: proto.String("a"), Syntax: proto.String("proto3")},
+		{Name: proto.String("b.proto"), Package: proto.String("b"), Syntax: proto.String("proto3")},
+	}})
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "fdset.bin")
+	require.NoError(t, ioutil.WriteFile(path, b, 0644))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv(pluginEnv, "gen/")
+
+	dir := t.TempDir()
+	fdset := writeDescriptorSet(t, dir)
+	out := filepath.Join(dir, "out")
+
+	require.NoError(t, run([]string{"-plugin", os.Args[0], "-descriptor_set", fdset, "-param", "foo=bar", "-out", out, "b.proto"}))
+
+	b, err := ioutil.ReadFile(filepath.Join(out, "gen", "b.proto.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "foo=bar", string(b))
+
+	_, err = os.Stat(filepath.Join(out, "gen", "a.proto.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRun_Escape(t *testing.T) {
+	t.Setenv(pluginEnv, "../")
+
+	dir := t.TempDir()
+	fdset := writeDescriptorSet(t, dir)
+	out := filepath.Join(dir, "out")
+
+	assert.Error(t, run([]string{"-plugin", os.Args[0], "-descriptor_set", fdset, "-out", out}))
+
+	_, err := os.Stat(filepath.Join(dir, "a.proto.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRun_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fdset := writeDescriptorSet(t, dir)
+
+	assert.EqualError(t, run([]string{"-descriptor_set", fdset}), "-plugin is required")
+	assert.EqualError(t, run([]string{"-plugin", os.Args[0]}), "exactly one of -descriptor_set or -request is required")
+	assert.EqualError(t, run([]string{"-plugin", os.Args[0], "-descriptor_set", fdset, "-request", fdset}), "exactly one of -descriptor_set or -request is required")
+	assert.Error(t, run([]string{"-plugin", os.Args[0], "-descriptor_set", fdset, "c.proto"}))
+}
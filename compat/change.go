@@ -0,0 +1,148 @@
+package compat
+
+import (
+	"fmt"
+
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+// Severity describes the impact of a Change. Severities are ordered, with
+// WireBreaking being the most severe.
+type Severity int
+
+const (
+	// SourceBreaking changes preserve the binary wire format, but break code
+	// generated from the protos or the JSON and text encodings of messages.
+	SourceBreaking Severity = iota
+
+	// WireBreaking changes alter the binary wire format of messages or the
+	// signatures of RPCs, breaking communication between the two versions.
+	WireBreaking
+)
+
+// String returns a string representation of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SourceBreaking:
+		return "source-breaking"
+	case WireBreaking:
+		return "wire-breaking"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Kind identifies the category of a Change.
+type Kind string
+
+const (
+	// MessageRemoved indicates a message was removed.
+	MessageRemoved Kind = "MESSAGE_REMOVED"
+
+	// FieldRemoved indicates a field was removed. Removals are wire-breaking
+	// unless the field's number was reserved.
+	FieldRemoved Kind = "FIELD_REMOVED"
+
+	// FieldRenumbered indicates a field (or extension) kept its name but
+	// changed its number.
+	FieldRenumbered Kind = "FIELD_RENUMBERED"
+
+	// FieldRenamed indicates a field kept its number but changed its name.
+	FieldRenamed Kind = "FIELD_RENAMED"
+
+	// FieldTypeChanged indicates the type of a field changed. Changes between
+	// wire-compatible types (eg, int32 to int64) are source-breaking.
+	FieldTypeChanged Kind = "FIELD_TYPE_CHANGED"
+
+	// FieldCardinalityChanged indicates a field changed between singular and
+	// repeated, became or stopped being required, or gained or lost explicit
+	// presence.
+	FieldCardinalityChanged Kind = "FIELD_CARDINALITY_CHANGED"
+
+	// FieldOneOfChanged indicates a field moved into, out of, or between
+	// OneOfs.
+	FieldOneOfChanged Kind = "FIELD_ONEOF_CHANGED"
+
+	// OneOfRenamed indicates a OneOf kept its fields but changed its name.
+	OneOfRenamed Kind = "ONEOF_RENAMED"
+
+	// FieldJSONNameChanged indicates the JSON name of a field changed.
+	FieldJSONNameChanged Kind = "FIELD_JSON_NAME_CHANGED"
+
+	// ExtensionRemoved indicates an extension was removed.
+	ExtensionRemoved Kind = "EXTENSION_REMOVED"
+
+	// EnumRemoved indicates an enum was removed.
+	EnumRemoved Kind = "ENUM_REMOVED"
+
+	// EnumValueRemoved indicates an enum value was removed. Removals are
+	// wire-breaking unless the value's number was reserved.
+	EnumValueRemoved Kind = "ENUM_VALUE_REMOVED"
+
+	// EnumValueRenumbered indicates an enum value kept its name but changed its
+	// number.
+	EnumValueRenumbered Kind = "ENUM_VALUE_RENUMBERED"
+
+	// EnumValueRenamed indicates an enum value kept its number but changed its
+	// name.
+	EnumValueRenamed Kind = "ENUM_VALUE_RENAMED"
+
+	// ServiceRemoved indicates a service was removed.
+	ServiceRemoved Kind = "SERVICE_REMOVED"
+
+	// MethodRemoved indicates an RPC was removed from a service.
+	MethodRemoved Kind = "METHOD_REMOVED"
+
+	// MethodSignatureChanged indicates the input or output type of an RPC, or
+	// whether either is streamed, changed.
+	MethodSignatureChanged Kind = "METHOD_SIGNATURE_CHANGED"
+
+	// ReservedNumberUsed indicates a field or enum value uses a number that was
+	// previously reserved.
+	ReservedNumberUsed Kind = "RESERVED_NUMBER_USED"
+
+	// ReservedNameUsed indicates a field or enum value uses a name that was
+	// previously reserved.
+	ReservedNameUsed Kind = "RESERVED_NAME_USED"
+
+	// ReservationRemoved indicates a reserved range or name was removed from a
+	// message or enum, permitting it to be reused.
+	ReservationRemoved Kind = "RESERVATION_REMOVED"
+)
+
+// Change describes a single breaking change between two ASTs.
+type Change struct {
+	// Kind is the category of the change.
+	Kind Kind
+
+	// Severity is the impact of the change.
+	Severity Severity
+
+	// FQN is the fully qualified name of the changed entity. For removals, this
+	// is the name of the entity in the previous AST.
+	FQN string
+
+	// Position locates the change in the next AST. For removals, this is the
+	// position of the entity that contained the removed one or, if it was
+	// removed as well, the removed entity's position in the previous AST.
+	Position pgs.Position
+
+	// Message is a human-readable description of the change.
+	Message string
+}
+
+// String returns the Change formatted as `file:line:col: severity: message`.
+func (c Change) String() string {
+	return fmt.Sprintf("%v: %v: %s", c.Position, c.Severity, c.Message)
+}
+
+// Filter returns the changes with a severity of at least min.
+func Filter(changes []Change, min Severity) []Change {
+	var out []Change
+	for _, c := range changes {
+		if c.Severity >= min {
+			out = append(out, c)
+		}
+	}
+	return out
+}
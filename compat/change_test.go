@@ -0,0 +1,45 @@
+package compat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+func TestSeverity_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "source-breaking", SourceBreaking.String())
+	assert.Equal(t, "wire-breaking", WireBreaking.String())
+	assert.Equal(t, "Severity(5)", Severity(5).String())
+}
+
+func TestChange_String(t *testing.T) {
+	t.Parallel()
+
+	c := Change{
+		Kind:     FieldRemoved,
+		Severity: WireBreaking,
+		FQN:      ".foo.Bar.baz",
+		Position: pgs.Position{Filename: "foo.proto"},
+		Message:  "field baz (2) was removed",
+	}
+	assert.Equal(t, "foo.proto: wire-breaking: field baz (2) was removed", c.String())
+
+	c.Position.Span = pgs.Span{StartLine: 3, StartColumn: 1}
+	assert.Equal(t, "foo.proto:3:1: wire-breaking: field baz (2) was removed", c.String())
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	changes := []Change{
+		{Kind: FieldRenamed, Severity: SourceBreaking},
+		{Kind: FieldRemoved, Severity: WireBreaking},
+	}
+
+	assert.Equal(t, changes, Filter(changes, SourceBreaking))
+	assert.Equal(t, changes[1:], Filter(changes, WireBreaking))
+	assert.Empty(t, Filter(nil, SourceBreaking))
+}
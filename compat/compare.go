@@ -0,0 +1,557 @@
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+// Compare reports the breaking changes between the target files of prev and
+// the entities of next. If prev has no targets, as is the case for ASTs
+// produced by pgs.ProcessFileDescriptorSet, every file in prev is compared.
+//
+// Entities are matched by their fully qualified names, so moving an entity
+// between files is not considered a change. Fields are matched by number and
+// then by name, enum values by name and then by number, and OneOfs by the
+// numbers of their fields. Additions are not reported unless they reuse a
+// reserved number or name. The returned changes are ordered by position.
+func Compare(prev, next pgs.AST) []Change {
+	c := &comparer{next: next}
+
+	targets := prev.Targets()
+	if len(targets) == 0 {
+		targets = make(map[string]pgs.File)
+		for _, pkg := range prev.Packages() {
+			for _, f := range pkg.Files() {
+				targets[f.Name().String()] = f
+			}
+		}
+	}
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c.file(targets[name])
+	}
+
+	sort.SliceStable(c.changes, func(i, j int) bool {
+		a, b := c.changes[i], c.changes[j]
+		switch {
+		case a.Position.Filename != b.Position.Filename:
+			return a.Position.Filename < b.Position.Filename
+		case a.Position.Span.StartLine != b.Position.Span.StartLine:
+			return a.Position.Span.StartLine < b.Position.Span.StartLine
+		case a.Position.Span.StartColumn != b.Position.Span.StartColumn:
+			return a.Position.Span.StartColumn < b.Position.Span.StartColumn
+		default:
+			return a.FQN < b.FQN
+		}
+	})
+
+	return c.changes
+}
+
+type comparer struct {
+	next    pgs.AST
+	changes []Change
+}
+
+// counterpart returns the entity in the next AST with the same name as e, or
+// nil if there is none.
+func (c *comparer) counterpart(e pgs.Entity) pgs.Entity {
+	name := e.FullyQualifiedName()
+	if f, ok := e.(pgs.File); ok {
+		name = f.Name().String()
+	}
+
+	n, _ := c.next.Lookup(name)
+	return n
+}
+
+func (c *comparer) report(kind Kind, sev Severity, e pgs.Entity, format string, args ...interface{}) {
+	c.changes = append(c.changes, Change{
+		Kind:     kind,
+		Severity: sev,
+		FQN:      e.FullyQualifiedName(),
+		Position: pgs.PositionOf(e),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// removed reports the removal of e, positioned at the counterpart of its
+// previous parent if it still exists.
+func (c *comparer) removed(kind Kind, sev Severity, e, parent pgs.Entity, format string, args ...interface{}) {
+	pos := pgs.PositionOf(e)
+	if p := c.counterpart(parent); p != nil {
+		pos = pgs.PositionOf(p)
+	}
+
+	c.changes = append(c.changes, Change{
+		Kind:     kind,
+		Severity: sev,
+		FQN:      e.FullyQualifiedName(),
+		Position: pos,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *comparer) file(f pgs.File) {
+	for _, m := range f.Messages() {
+		c.message(m)
+	}
+
+	for _, e := range f.Enums() {
+		c.enum(e)
+	}
+
+	for _, s := range f.Services() {
+		c.service(s)
+	}
+
+	for _, x := range f.DefinedExtensions() {
+		c.extension(x)
+	}
+}
+
+func (c *comparer) message(pm pgs.Message) {
+	nm, ok := c.counterpart(pm).(pgs.Message)
+	if !ok {
+		c.removed(MessageRemoved, SourceBreaking, pm, pm.Parent(), "message %s was removed", pm.Name())
+		return
+	}
+
+	c.fields(pm, nm)
+
+	for _, sm := range pm.Messages() {
+		c.message(sm)
+	}
+
+	for _, e := range pm.Enums() {
+		c.enum(e)
+	}
+
+	for _, x := range pm.DefinedExtensions() {
+		c.extension(x)
+	}
+}
+
+func (c *comparer) fields(pm, nm pgs.Message) {
+	byNumber := make(map[int32]pgs.Field, len(nm.Fields()))
+	byName := make(map[pgs.Name]pgs.Field, len(nm.Fields()))
+	for _, f := range nm.Fields() {
+		byNumber[f.Descriptor().GetNumber()] = f
+		byName[f.Name()] = f
+	}
+
+	oneOfs := matchOneOfs(pm, nm)
+	for _, po := range pm.RealOneOfs() {
+		if no := oneOfs[po]; no != nil && no.Name() != po.Name() {
+			c.report(OneOfRenamed, SourceBreaking, no, "oneof %s was renamed to %s", po.Name(), no.Name())
+		}
+	}
+
+	prevNumbers := make(map[int32]struct{}, len(pm.Fields()))
+	for _, pf := range pm.Fields() {
+		n := pf.Descriptor().GetNumber()
+		prevNumbers[n] = struct{}{}
+
+		nf, ok := byNumber[n]
+		switch {
+		case ok:
+			if nf.Name() != pf.Name() {
+				c.report(FieldRenamed, SourceBreaking, nf, "field %s (%d) was renamed to %s", pf.Name(), n, nf.Name())
+			}
+			c.field(pf, nf, true)
+			c.oneOf(pf, nf, oneOfs)
+		case byName[pf.Name()] != nil:
+			nf = byName[pf.Name()]
+			c.report(FieldRenumbered, WireBreaking, nf, "field %s changed number from %d to %d", pf.Name(), n, nf.Descriptor().GetNumber())
+		case rangesContain(nm.ReservedRanges(), n):
+			c.removed(FieldRemoved, SourceBreaking, pf, pm, "field %s (%d) was removed", pf.Name(), n)
+		default:
+			c.removed(FieldRemoved, WireBreaking, pf, pm, "field %s (%d) was removed without reserving its number", pf.Name(), n)
+		}
+	}
+
+	for _, nf := range nm.Fields() {
+		n := nf.Descriptor().GetNumber()
+		if _, ok := prevNumbers[n]; ok {
+			continue
+		}
+
+		if rangesContain(pm.ReservedRanges(), n) {
+			c.report(ReservedNumberUsed, WireBreaking, nf, "field %s uses previously reserved number %d", nf.Name(), n)
+		}
+
+		if namesContain(pm.ReservedNames(), nf.Name()) {
+			c.report(ReservedNameUsed, SourceBreaking, nf, "field %s uses a previously reserved name", nf.Name())
+		}
+	}
+
+	c.reservations(nm, pm.ReservedRanges(), nm.ReservedRanges(), pm.ReservedNames(), nm.ReservedNames())
+}
+
+// field compares the matched fields pf and nf. JSON names are not compared if
+// json is false, as is the case for extensions.
+func (c *comparer) field(pf, nf pgs.Field, json bool) {
+	if pt, nt := typeName(pf.Type()), typeName(nf.Type()); pt != nt {
+		sev := WireBreaking
+		if wireClass(pf.Type()) == wireClass(nf.Type()) {
+			sev = SourceBreaking
+		}
+		c.report(FieldTypeChanged, sev, nf, "field %s changed type from %s to %s", nf.Name(), pt, nt)
+	}
+
+	switch pl, nl := isList(pf), isList(nf); {
+	case pl && !nl:
+		c.report(FieldCardinalityChanged, WireBreaking, nf, "field %s changed from repeated to singular", nf.Name())
+	case !pl && nl:
+		c.report(FieldCardinalityChanged, WireBreaking, nf, "field %s changed from singular to repeated", nf.Name())
+	case pf.Required() && !nf.Required():
+		c.report(FieldCardinalityChanged, WireBreaking, nf, "field %s is no longer required", nf.Name())
+	case !pf.Required() && nf.Required():
+		c.report(FieldCardinalityChanged, WireBreaking, nf, "field %s is now required", nf.Name())
+	case pf.InRealOneOf() || nf.InRealOneOf():
+		// presence changes are implied by moving into or out of a oneof
+	case pf.HasPresence() && !nf.HasPresence():
+		c.report(FieldCardinalityChanged, SourceBreaking, nf, "field %s no longer tracks presence", nf.Name())
+	case !pf.HasPresence() && nf.HasPresence():
+		c.report(FieldCardinalityChanged, SourceBreaking, nf, "field %s now tracks presence", nf.Name())
+	}
+
+	if !json {
+		return
+	}
+
+	if pj, nj := jsonName(pf), jsonName(nf); pj != nj {
+		c.report(FieldJSONNameChanged, SourceBreaking, nf, "field %s changed JSON name from %q to %q", nf.Name(), pj, nj)
+	}
+}
+
+// oneOf compares the OneOfs containing the matched fields pf and nf, with
+// oneOfs pairing the OneOfs of their messages as returned by matchOneOfs.
+func (c *comparer) oneOf(pf, nf pgs.Field, oneOfs map[pgs.OneOf]pgs.OneOf) {
+	var po, no pgs.OneOf
+	if pf.InRealOneOf() {
+		po = pf.OneOf()
+	}
+	if nf.InRealOneOf() {
+		no = nf.OneOf()
+	}
+
+	switch {
+	case po == nil && no == nil, po != nil && no != nil && oneOfs[po] == no:
+	case po == nil:
+		c.report(FieldOneOfChanged, WireBreaking, nf, "field %s moved into oneof %s", nf.Name(), no.Name())
+	case no == nil:
+		c.report(FieldOneOfChanged, WireBreaking, nf, "field %s moved out of oneof %s", nf.Name(), po.Name())
+	default:
+		c.report(FieldOneOfChanged, WireBreaking, nf, "field %s moved from oneof %s to %s", nf.Name(), po.Name(), no.Name())
+	}
+}
+
+func (c *comparer) extension(px pgs.Extension) {
+	nx, ok := c.counterpart(px).(pgs.Extension)
+	if !ok {
+		c.removed(ExtensionRemoved, SourceBreaking, px, px.DefinedIn(), "extension %s was removed", px.Name())
+		return
+	}
+
+	if pn, nn := px.Descriptor().GetNumber(), nx.Descriptor().GetNumber(); pn != nn {
+		c.report(FieldRenumbered, WireBreaking, nx, "extension %s changed number from %d to %d", nx.Name(), pn, nn)
+	}
+
+	c.field(px, nx, false)
+}
+
+func (c *comparer) enum(pe pgs.Enum) {
+	ne, ok := c.counterpart(pe).(pgs.Enum)
+	if !ok {
+		c.removed(EnumRemoved, SourceBreaking, pe, pe.Parent(), "enum %s was removed", pe.Name())
+		return
+	}
+
+	byName := make(map[pgs.Name]pgs.EnumValue, len(ne.Values()))
+	for _, v := range ne.Values() {
+		byName[v.Name()] = v
+	}
+
+	prevNumbers := make(map[int32]struct{}, len(pe.Values()))
+	prevNames := make(map[pgs.Name]struct{}, len(pe.Values()))
+	for _, pv := range pe.Values() {
+		n := pv.Value()
+		prevNumbers[n] = struct{}{}
+		prevNames[pv.Name()] = struct{}{}
+
+		if nv, ok := byName[pv.Name()]; ok {
+			if nv.Value() != n {
+				c.report(EnumValueRenumbered, WireBreaking, nv, "enum value %s changed number from %d to %d", pv.Name(), n, nv.Value())
+			}
+			continue
+		}
+
+		switch nv := ne.ValueByNumber(n); {
+		case nv != nil:
+			c.report(EnumValueRenamed, SourceBreaking, nv, "enum value %s (%d) was renamed to %s", pv.Name(), n, nv.Name())
+		case rangesContain(ne.ReservedRanges(), n):
+			c.removed(EnumValueRemoved, SourceBreaking, pv, pe, "enum value %s (%d) was removed", pv.Name(), n)
+		default:
+			c.removed(EnumValueRemoved, WireBreaking, pv, pe, "enum value %s (%d) was removed without reserving its number", pv.Name(), n)
+		}
+	}
+
+	for _, nv := range ne.Values() {
+		if _, ok := prevNumbers[nv.Value()]; !ok && rangesContain(pe.ReservedRanges(), nv.Value()) {
+			c.report(ReservedNumberUsed, WireBreaking, nv, "enum value %s uses previously reserved number %d", nv.Name(), nv.Value())
+		}
+
+		if _, ok := prevNames[nv.Name()]; !ok && namesContain(pe.ReservedNames(), nv.Name()) {
+			c.report(ReservedNameUsed, SourceBreaking, nv, "enum value %s uses a previously reserved name", nv.Name())
+		}
+	}
+
+	c.reservations(ne, pe.ReservedRanges(), ne.ReservedRanges(), pe.ReservedNames(), ne.ReservedNames())
+}
+
+// reservations reports reserved ranges and names of the previous message or
+// enum that are no longer reserved by its counterpart, e.
+func (c *comparer) reservations(e pgs.Entity, prevRanges, nextRanges []pgs.Range, prevNames, nextNames []pgs.Name) {
+	for _, r := range prevRanges {
+		if !rangeCovered(r, nextRanges) {
+			c.report(ReservationRemoved, WireBreaking, e, "reserved range %s was removed from %s", rangeString(r), e.Name())
+		}
+	}
+
+	for _, n := range prevNames {
+		if !namesContain(nextNames, n) {
+			c.report(ReservationRemoved, SourceBreaking, e, "reserved name %q was removed from %s", n, e.Name())
+		}
+	}
+}
+
+func (c *comparer) service(ps pgs.Service) {
+	if _, ok := c.counterpart(ps).(pgs.Service); !ok {
+		c.removed(ServiceRemoved, WireBreaking, ps, ps.File(), "service %s was removed", ps.Name())
+		return
+	}
+
+	for _, pm := range ps.Methods() {
+		nm, ok := c.counterpart(pm).(pgs.Method)
+		if !ok {
+			c.removed(MethodRemoved, WireBreaking, pm, ps, "method %s was removed from %s", pm.Name(), ps.Name())
+			continue
+		}
+
+		if pi, ni := methodType(pm.Input(), pm.ClientStreaming()), methodType(nm.Input(), nm.ClientStreaming()); pi != ni {
+			c.report(MethodSignatureChanged, WireBreaking, nm, "method %s changed input from %s to %s", nm.Name(), pi, ni)
+		}
+
+		if po, no := methodType(pm.Output(), pm.ServerStreaming()), methodType(nm.Output(), nm.ServerStreaming()); po != no {
+			c.report(MethodSignatureChanged, WireBreaking, nm, "method %s changed output from %s to %s", nm.Name(), po, no)
+		}
+	}
+}
+
+func methodType(m pgs.Message, stream bool) string {
+	if stream {
+		return "stream " + m.FullyQualifiedName()
+	}
+	return m.FullyQualifiedName()
+}
+
+func isList(f pgs.Field) bool {
+	return f.Type().IsRepeated() || f.Type().IsMap()
+}
+
+// matchOneOfs pairs each real OneOf of pm with the real OneOf of nm sharing
+// the most field numbers, preferring the same name on ties. OneOfs are matched
+// by their fields as their names never appear on the wire. Each OneOf of nm is
+// paired at most once, and OneOfs without any fields in common are unpaired.
+func matchOneOfs(pm, nm pgs.Message) map[pgs.OneOf]pgs.OneOf {
+	type pair struct {
+		prev, next pgs.OneOf
+		shared     int
+	}
+
+	var pairs []pair
+	for _, po := range pm.RealOneOfs() {
+		numbers := make(map[int32]struct{}, len(po.Fields()))
+		for _, f := range po.Fields() {
+			numbers[f.Descriptor().GetNumber()] = struct{}{}
+		}
+
+		for _, no := range nm.RealOneOfs() {
+			p := pair{prev: po, next: no}
+			for _, f := range no.Fields() {
+				if _, ok := numbers[f.Descriptor().GetNumber()]; ok {
+					p.shared++
+				}
+			}
+			if p.shared > 0 {
+				pairs = append(pairs, p)
+			}
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if a.shared != b.shared {
+			return a.shared > b.shared
+		}
+		return a.prev.Name() == a.next.Name() && b.prev.Name() != b.next.Name()
+	})
+
+	matched := make(map[pgs.OneOf]pgs.OneOf, len(pairs))
+	paired := make(map[pgs.OneOf]bool, len(pairs))
+	for _, p := range pairs {
+		if matched[p.prev] == nil && !paired[p.next] {
+			matched[p.prev] = p.next
+			paired[p.next] = true
+		}
+	}
+
+	return matched
+}
+
+// jsonName returns the JSON name of f, computing it as protoc does if it is
+// not present on the descriptor.
+func jsonName(f pgs.Field) string {
+	if fd := f.Descriptor(); fd.JsonName != nil {
+		return fd.GetJsonName()
+	}
+
+	var sb strings.Builder
+	upper := false
+	for _, r := range f.Name().String() {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			sb.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// typeName describes the type of a field, independent of its cardinality.
+func typeName(ft pgs.FieldType) string {
+	switch {
+	case ft.IsMap():
+		return "map<" + elemName(ft.Key()) + ", " + elemName(ft.Element()) + ">"
+	case ft.IsRepeated():
+		return elemName(ft.Element())
+	case ft.IsEmbed():
+		return ft.Embed().FullyQualifiedName()
+	case ft.IsEnum():
+		return ft.Enum().FullyQualifiedName()
+	default:
+		return scalarName(ft.ProtoType())
+	}
+}
+
+func elemName(el pgs.FieldTypeElem) string {
+	switch {
+	case el.IsEmbed():
+		return el.Embed().FullyQualifiedName()
+	case el.IsEnum():
+		return el.Enum().FullyQualifiedName()
+	default:
+		return scalarName(el.ProtoType())
+	}
+}
+
+func scalarName(pt pgs.ProtoType) string {
+	return strings.ToLower(strings.TrimPrefix(pt.String(), "TYPE_"))
+}
+
+// wireClass groups field types that share a binary encoding and may be
+// changed between without breaking the wire format.
+func wireClass(ft pgs.FieldType) string {
+	switch {
+	case ft.IsMap():
+		return "map<" + elemClass(ft.Key()) + ", " + elemClass(ft.Element()) + ">"
+	case ft.IsRepeated():
+		return elemClass(ft.Element())
+	case ft.IsEmbed():
+		return ft.Embed().FullyQualifiedName()
+	default:
+		return protoTypeClass(ft.ProtoType())
+	}
+}
+
+func elemClass(el pgs.FieldTypeElem) string {
+	if el.IsEmbed() {
+		return el.Embed().FullyQualifiedName()
+	}
+	return protoTypeClass(el.ProtoType())
+}
+
+func protoTypeClass(pt pgs.ProtoType) string {
+	switch pt {
+	case pgs.Int32T, pgs.Int64T, pgs.UInt32T, pgs.UInt64T, pgs.BoolT, pgs.EnumT:
+		return "varint"
+	case pgs.SInt32, pgs.SInt64:
+		return "zigzag"
+	case pgs.Fixed32T, pgs.SFixed32:
+		return "fixed32"
+	case pgs.Fixed64T, pgs.SFixed64:
+		return "fixed64"
+	case pgs.StringT, pgs.BytesT:
+		return "bytes"
+	default:
+		return scalarName(pt)
+	}
+}
+
+func rangesContain(rs []pgs.Range, n int32) bool {
+	for _, r := range rs {
+		if r.Contains(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeCovered returns true if every number in r is contained by rs.
+func rangeCovered(r pgs.Range, rs []pgs.Range) bool {
+	sorted := append([]pgs.Range(nil), rs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	next := r.Start
+	for _, s := range sorted {
+		if !s.Contains(next) {
+			continue
+		}
+		if s.End >= r.End {
+			return true
+		}
+		next = s.End + 1
+	}
+
+	return false
+}
+
+func rangeString(r pgs.Range) string {
+	if r.Start == r.End {
+		return fmt.Sprint(r.Start)
+	}
+	return fmt.Sprintf("%d to %d", r.Start, r.End)
+}
+
+func namesContain(ns []pgs.Name, n pgs.Name) bool {
+	for _, name := range ns {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package compat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgs "github.com/vchitai/protoc-gen-star"
+	"github.com/vchitai/protoc-gen-star/testutils"
+)
+
+func compile(t *testing.T, src string) pgs.AST {
+	return testutils.Loader{}.CompileSources(t, map[string]string{"foo.proto": src})
+}
+
+type expected struct {
+	kind Kind
+	sev  Severity
+	fqn  string
+}
+
+func summarize(changes []Change) []expected {
+	out := make([]expected, len(changes))
+	for i, c := range changes {
+		out[i] = expected{c.Kind, c.Severity, c.FQN}
+	}
+	return out
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		prev, next string
+		expected   []expected
+	}{
+		{
+			name: "identical",
+			prev: `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+			next: `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+		},
+		{
+			name: "additions",
+			prev: `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+			next: `syntax = "proto3"; package foo; message Foo { string bar = 1; int32 baz = 2; } message Bar {}`,
+		},
+		{
+			name: "field removed",
+			prev: `syntax = "proto3"; package foo; message Foo { string bar = 1; string baz = 2; }`,
+			next: `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+			expected: []expected{
+				{FieldRemoved, WireBreaking, ".foo.Foo.baz"},
+			},
+		},
+		{
+			name: "field removed and reserved",
+			prev: `syntax = "proto3"; package foo; message Foo { string bar = 1; string baz = 2; }`,
+			next: `syntax = "proto3"; package foo; message Foo { reserved 2; string bar = 1; }`,
+			expected: []expected{
+				{FieldRemoved, SourceBreaking, ".foo.Foo.baz"},
+			},
+		},
+		{
+			name: "field renumbered",
+			prev: `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+			next: `syntax = "proto3"; package foo; message Foo { string bar = 2; }`,
+			expected: []expected{
+				{FieldRenumbered, WireBreaking, ".foo.Foo.bar"},
+			},
+		},
+		{
+			name: "field renamed",
+			prev: `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+			next: `syntax = "proto3"; package foo; message Foo { string baz = 1 [json_name = "bar"]; }`,
+			expected: []expected{
+				{FieldRenamed, SourceBreaking, ".foo.Foo.baz"},
+			},
+		},
+		{
+			name: "json name changed",
+			prev: `syntax = "proto3"; package foo; message Foo { string bar_baz = 1; }`,
+			next: `syntax = "proto3"; package foo; message Foo { string bar_baz = 1 [json_name = "bb"]; }`,
+			expected: []expected{
+				{FieldJSONNameChanged, SourceBreaking, ".foo.Foo.bar_baz"},
+			},
+		},
+		{
+			name: "compatible type change",
+			prev: `syntax = "proto3"; package foo; message Foo { int32 a = 1; string b = 2; fixed32 c = 3; }`,
+			next: `syntax = "proto3"; package foo; message Foo { int64 a = 1; bytes b = 2; sfixed32 c = 3; }`,
+			expected: []expected{
+				{FieldTypeChanged, SourceBreaking, ".foo.Foo.a"},
+				{FieldTypeChanged, SourceBreaking, ".foo.Foo.b"},
+				{FieldTypeChanged, SourceBreaking, ".foo.Foo.c"},
+			},
+		},
+		{
+			name: "incompatible type change",
+			prev: `syntax = "proto3"; package foo; message Foo { int32 a = 1; Foo b = 2; map<string, int32> c = 3; }`,
+			next: `syntax = "proto3"; package foo; message Foo { sint32 a = 1; Bar b = 2; map<string, string> c = 3; } message Bar {}`,
+			expected: []expected{
+				{FieldTypeChanged, WireBreaking, ".foo.Foo.a"},
+				{FieldTypeChanged, WireBreaking, ".foo.Foo.b"},
+				{FieldTypeChanged, WireBreaking, ".foo.Foo.c"},
+			},
+		},
+		{
+			name: "cardinality changed",
+			prev: `syntax = "proto2"; package foo; message Foo { optional int32 a = 1; repeated int32 b = 2; optional int32 c = 3; }`,
+			next: `syntax = "proto2"; package foo; message Foo { repeated int32 a = 1; optional int32 b = 2; required int32 c = 3; }`,
+			expected: []expected{
+				{FieldCardinalityChanged, WireBreaking, ".foo.Foo.a"},
+				{FieldCardinalityChanged, WireBreaking, ".foo.Foo.b"},
+				{FieldCardinalityChanged, WireBreaking, ".foo.Foo.c"},
+			},
+		},
+		{
+			name: "presence changed",
+			prev: `syntax = "proto3"; package foo; message Foo { int32 a = 1; }`,
+			next: `syntax = "proto3"; package foo; message Foo { optional int32 a = 1; }`,
+			expected: []expected{
+				{FieldCardinalityChanged, SourceBreaking, ".foo.Foo.a"},
+			},
+		},
+		{
+			name: "oneof changed",
+			prev: `syntax = "proto3"; package foo; message Foo { int32 a = 1; oneof o { int32 b = 2; } }`,
+			next: `syntax = "proto3"; package foo; message Foo { int32 b = 2; oneof o { int32 a = 1; } }`,
+			expected: []expected{
+				{FieldOneOfChanged, WireBreaking, ".foo.Foo.b"},
+				{FieldOneOfChanged, WireBreaking, ".foo.Foo.a"},
+			},
+		},
+		{
+			name: "oneof renamed",
+			prev: `syntax = "proto3"; package foo; message Foo { oneof o { int32 a = 1; string b = 2; } }`,
+			next: `syntax = "proto3"; package foo; message Foo { oneof p { int32 a = 1; string b = 2; } }`,
+			expected: []expected{
+				{OneOfRenamed, SourceBreaking, ".foo.Foo.p"},
+			},
+		},
+		{
+			name: "oneof fields added and moved",
+			prev: `syntax = "proto3"; package foo; message Foo { oneof o { int32 a = 1; int32 b = 2; int32 c = 3; } oneof p { int32 d = 4; } }`,
+			next: `syntax = "proto3"; package foo; message Foo { oneof x { int32 a = 1; int32 b = 2; int32 e = 5; } oneof p { int32 c = 3; int32 d = 4; } }`,
+			expected: []expected{
+				{OneOfRenamed, SourceBreaking, ".foo.Foo.x"},
+				{FieldOneOfChanged, WireBreaking, ".foo.Foo.c"},
+			},
+		},
+		{
+			name: "reserved number used",
+			prev: `syntax = "proto3"; package foo; message Foo { reserved 2 to 5; reserved "baz"; }`,
+			next: `syntax = "proto3"; package foo; message Foo { string baz = 3; }`,
+			expected: []expected{
+				{ReservationRemoved, WireBreaking, ".foo.Foo"},
+				{ReservationRemoved, SourceBreaking, ".foo.Foo"},
+				{ReservedNumberUsed, WireBreaking, ".foo.Foo.baz"},
+				{ReservedNameUsed, SourceBreaking, ".foo.Foo.baz"},
+			},
+		},
+		{
+			name: "reservations split",
+			prev: `syntax = "proto3"; package foo; message Foo { reserved 2 to 5; }`,
+			next: `syntax = "proto3"; package foo; message Foo { reserved 2, 3 to 4, 5 to 10; }`,
+		},
+		{
+			name: "message removed",
+			prev: `syntax = "proto3"; package foo; message Foo { message Bar { int32 a = 1; } }`,
+			next: `syntax = "proto3"; package foo; message Foo {}`,
+			expected: []expected{
+				{MessageRemoved, SourceBreaking, ".foo.Foo.Bar"},
+			},
+		},
+		{
+			name: "enum values",
+			prev: `syntax = "proto3"; package foo; enum E { A = 0; B = 1; C = 2; D = 3; E_E = 4; }`,
+			next: `syntax = "proto3"; package foo; enum E { reserved 3; A = 0; BB = 1; C = 5; }`,
+			expected: []expected{
+				{EnumValueRenamed, SourceBreaking, ".foo.E.BB"},
+				{EnumValueRenumbered, WireBreaking, ".foo.E.C"},
+				{EnumValueRemoved, SourceBreaking, ".foo.E.D"},
+				{EnumValueRemoved, WireBreaking, ".foo.E.E_E"},
+			},
+		},
+		{
+			name: "enum reservations",
+			prev: `syntax = "proto3"; package foo; enum E { reserved 1 to max; reserved "B"; A = 0; }`,
+			next: `syntax = "proto3"; package foo; enum E { reserved 2 to max; A = 0; B = 1; }`,
+			expected: []expected{
+				{ReservationRemoved, WireBreaking, ".foo.E"},
+				{ReservationRemoved, SourceBreaking, ".foo.E"},
+				{ReservedNumberUsed, WireBreaking, ".foo.E.B"},
+				{ReservedNameUsed, SourceBreaking, ".foo.E.B"},
+			},
+		},
+		{
+			name: "enum removed",
+			prev: `syntax = "proto3"; package foo; message Foo { enum E { A = 0; } }`,
+			next: `syntax = "proto3"; package foo; message Foo {}`,
+			expected: []expected{
+				{EnumRemoved, SourceBreaking, ".foo.Foo.E"},
+			},
+		},
+		{
+			name: "services",
+			prev: `syntax = "proto3"; package foo; message Foo {} message Bar {}
+				service S { rpc A(Foo) returns (Foo); rpc B(Foo) returns (Foo); rpc C(Foo) returns (Foo); }
+				service T {}`,
+			next: `syntax = "proto3"; package foo; message Foo {} message Bar {}
+				service S { rpc A(Bar) returns (Foo); rpc B(Foo) returns (stream Foo); }`,
+			expected: []expected{
+				{ServiceRemoved, WireBreaking, ".foo.T"},
+				{MethodRemoved, WireBreaking, ".foo.S.C"},
+				{MethodSignatureChanged, WireBreaking, ".foo.S.A"},
+				{MethodSignatureChanged, WireBreaking, ".foo.S.B"},
+			},
+		},
+		{
+			name: "extensions",
+			prev: `syntax = "proto2"; package foo; message Foo { extensions 100 to 200; }
+				extend Foo { optional int32 a = 100; optional int32 b = 101; }`,
+			next: `syntax = "proto2"; package foo; message Foo { extensions 100 to 200; }
+				extend Foo { optional string a = 102; }`,
+			expected: []expected{
+				{FieldRenumbered, WireBreaking, ".foo.a"},
+				{FieldTypeChanged, WireBreaking, ".foo.a"},
+				{ExtensionRemoved, SourceBreaking, ".foo.b"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			changes := Compare(compile(t, tc.prev), compile(t, tc.next))
+			assert.ElementsMatch(t, tc.expected, summarize(changes), "%v", changes)
+		})
+	}
+}
+
+func TestCompare_Position(t *testing.T) {
+	t.Parallel()
+
+	prev := compile(t, `syntax = "proto3";
+package foo;
+
+message Foo {
+  int32 bar = 1;
+  int32 baz = 2;
+}
+`)
+
+	next := compile(t, `syntax = "proto3";
+package foo;
+
+message Foo {
+  int64 bar = 1;
+}
+`)
+
+	changes := Compare(prev, next)
+	require.Len(t, changes, 2)
+
+	// the removal is positioned at the message that contained the field
+	assert.Equal(t, FieldRemoved, changes[0].Kind)
+	assert.Equal(t, "foo.proto:4:1", changes[0].Position.String())
+	assert.Equal(t, ".foo.Foo.baz", changes[0].FQN)
+
+	assert.Equal(t, FieldTypeChanged, changes[1].Kind)
+	assert.Equal(t, "foo.proto:5:3", changes[1].Position.String())
+	assert.Equal(t, "foo.proto:5:3: source-breaking: field bar changed type from int32 to int64", changes[1].String())
+}
+
+func TestCompare_MovedBetweenFiles(t *testing.T) {
+	t.Parallel()
+
+	prev := testutils.Loader{}.CompileSources(t, map[string]string{
+		"a.proto": `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+	})
+
+	next := testutils.Loader{}.CompileSources(t, map[string]string{
+		"a.proto": `syntax = "proto3"; package foo; import "b.proto"; message Bar { Foo foo = 1; }`,
+		"b.proto": `syntax = "proto3"; package foo; message Foo { string bar = 1; }`,
+	})
+
+	assert.Empty(t, Compare(prev, next))
+
+	changes := Compare(next, prev)
+	require.Len(t, changes, 1)
+	assert.Equal(t, MessageRemoved, changes[0].Kind)
+	assert.Equal(t, "a.proto", changes[0].Position.Filename)
+}
+
+func TestCompare_FileDescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	load := func(src string) pgs.AST {
+		fdset := compile(t, src).ToFileDescriptorSet()
+		return pgs.ProcessFileDescriptorSet(pgs.InitMockDebugger(), fdset)
+	}
+
+	prev := load(`syntax = "proto3"; package foo; message Foo { string bar = 1; string baz = 2; }`)
+	next := load(`syntax = "proto3"; package foo; message Foo { string bar = 1; }`)
+	require.Empty(t, prev.Targets())
+
+	changes := Compare(prev, next)
+	assert.Equal(t, []expected{{FieldRemoved, WireBreaking, ".foo.Foo.baz"}}, summarize(changes))
+}
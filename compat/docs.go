@@ -0,0 +1,3 @@
+// Package compat reports breaking changes between two versions of a set of
+// proto files, represented as PG* ASTs.
+package compat
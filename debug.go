@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 )
 
 // DebuggerCommon contains shared features of Debugger and Debugger-like types
@@ -52,6 +53,7 @@ type DebuggerCommon interface {
 // A Debugger provides utility methods to provide context-aware logging,
 // error-checking, and assertions. The Debugger is used extensively within the
 // protoc-gen-star generator, and is provided in a module's build context.
+// Debuggers are safe for concurrent use by multiple goroutines.
 type Debugger interface {
 	DebuggerCommon
 
@@ -75,6 +77,33 @@ type failFunc func(msgs ...interface{})
 
 type exitFunc func(code int)
 
+// positionalDebugger is implemented by the Debuggers of this package to report
+// a message about a position in a proto file. The position is written ahead of
+// any prefixes pushed onto the Debugger (eg, `foo.proto:12:3: [mod] message`),
+// so that editors and CI matchers can locate it.
+type positionalDebugger interface {
+	failAt(pos, msg string)
+	logAt(pos, msg string)
+}
+
+// failAt fails d with msg about the position pos.
+func failAt(d Debugger, pos, msg string) {
+	if pd, ok := d.(positionalDebugger); ok {
+		pd.failAt(pos, msg)
+		return
+	}
+	d.Fail(pos + ": " + msg)
+}
+
+// logAt logs msg about the position pos to d.
+func logAt(d Debugger, pos, msg string) {
+	if pd, ok := d.(positionalDebugger); ok {
+		pd.logAt(pos, msg)
+		return
+	}
+	d.Log(pos + ": " + msg)
+}
+
 type rootDebugger struct {
 	err       errFunc
 	fail      failFunc
@@ -113,6 +142,8 @@ func (d rootDebugger) Logf(format string, v ...interface{})  { d.l.Printf(format
 func (d rootDebugger) Fail(v ...interface{})                 { d.fail(fmt.Sprint(v...)) }
 func (d rootDebugger) Failf(format string, v ...interface{}) { d.fail(fmt.Sprintf(format, v...)) }
 func (d rootDebugger) Exit(code int)                         { d.exit(code) }
+func (d rootDebugger) failAt(pos, msg string)                { d.Fail(pos + ": " + msg) }
+func (d rootDebugger) logAt(pos, msg string)                 { d.Log(pos + ": " + msg) }
 
 func (d rootDebugger) Debug(v ...interface{}) {
 	if d.logDebugs {
@@ -201,6 +232,14 @@ func (d prefixedDebugger) Assert(expr bool, v ...interface{}) {
 
 func (d prefixedDebugger) Exit(code int) { d.parent.Exit(code) }
 
+func (d prefixedDebugger) failAt(pos, msg string) {
+	failAt(d.parent, pos, d.prependFormat(msg))
+}
+
+func (d prefixedDebugger) logAt(pos, msg string) {
+	logAt(d.parent, pos, d.prependFormat(msg))
+}
+
 func (d prefixedDebugger) Push(prefix string) Debugger {
 	return prefixedDebugger{
 		parent: d,
@@ -212,6 +251,109 @@ func (d prefixedDebugger) Pop() Debugger {
 	return d.parent
 }
 
+// A Diagnostic describes a failure reported to a Debugger while errors are
+// being collected instead of terminating the process. See the CollectErrors
+// InitOption.
+type Diagnostic struct {
+	// Message describes the failure, including any prefixes applied to the
+	// Debugger (typically the Module name and any context it pushed).
+	Message string
+
+	// Err is the error passed to CheckErr, if any.
+	Err error
+}
+
+// String returns a human-readable representation of the Diagnostic.
+func (d Diagnostic) String() string {
+	if d.Err == nil {
+		return d.Message
+	}
+
+	if d.Message == "" {
+		return d.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %v", d.Message, d.Err)
+}
+
+type diagnostics struct {
+	mtx  sync.Mutex
+	list []Diagnostic
+}
+
+func (ds *diagnostics) add(d ...Diagnostic) {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	ds.list = append(ds.list, d...)
+}
+
+func (ds *diagnostics) len() int {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	return len(ds.list)
+}
+
+func (ds *diagnostics) all() []Diagnostic {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	out := make([]Diagnostic, len(ds.list))
+	copy(out, ds.list)
+	return out
+}
+
+func (ds *diagnostics) flush() []Diagnostic {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	out := ds.list
+	ds.list = nil
+	return out
+}
+
+// collectingDebugger records failures as Diagnostics instead of terminating
+// the process. All other behavior is delegated to the wrapped Debugger.
+type collectingDebugger struct {
+	Debugger
+	diags *diagnostics
+}
+
+func initCollectingDebugger(d Debugger, diags *diagnostics) Debugger {
+	return collectingDebugger{Debugger: d, diags: diags}
+}
+
+func (d collectingDebugger) Fail(v ...interface{}) { d.collect(nil, fmt.Sprint(v...)) }
+
+func (d collectingDebugger) Failf(format string, v ...interface{}) {
+	d.collect(nil, fmt.Sprintf(format, v...))
+}
+
+func (d collectingDebugger) CheckErr(err error, v ...interface{}) {
+	if err != nil {
+		d.collect(err, fmt.Sprint(v...))
+	}
+}
+
+func (d collectingDebugger) Assert(expr bool, v ...interface{}) {
+	if !expr {
+		d.collect(nil, fmt.Sprint(v...))
+	}
+}
+
+func (d collectingDebugger) failAt(pos, msg string) { d.collect(nil, pos+": "+msg) }
+func (d collectingDebugger) logAt(pos, msg string)  { logAt(d.Debugger, pos, msg) }
+
+func (d collectingDebugger) Push(prefix string) Debugger {
+	return prefixedDebugger{
+		parent: d,
+		prefix: "[" + prefix + "]",
+	}
+}
+
+func (d collectingDebugger) collect(err error, msg string) {
+	diag := Diagnostic{Message: msg, Err: err}
+	d.Debug("[collected]", diag.String())
+	d.diags.add(diag)
+}
+
 // MockDebugger serves as a root Debugger instance for usage in tests. Unlike
 // an actual Debugger, MockDebugger will not exit the program, but will track
 // failures, checked errors, and exit codes.
@@ -240,6 +382,7 @@ type MockDebugger interface {
 type mockDebugger struct {
 	Debugger
 
+	mtx    sync.Mutex
 	buf    bytes.Buffer
 	failed bool
 	err    error
@@ -250,21 +393,27 @@ type mockDebugger struct {
 // InitMockDebugger creates a new MockDebugger for usage in tests.
 func InitMockDebugger() MockDebugger {
 	md := &mockDebugger{}
-	d := initDebugger(true, log.New(&md.buf, "", 0)).(rootDebugger)
+	d := initDebugger(true, log.New(lockedWriter{&md.mtx, &md.buf}, "", 0)).(rootDebugger)
 
 	d.fail = func(msgs ...interface{}) {
+		md.mtx.Lock()
 		md.failed = true
+		md.mtx.Unlock()
 		d.defaultFail(msgs...)
 	}
 
 	d.err = func(err error, msgs ...interface{}) {
 		if err != nil {
+			md.mtx.Lock()
 			md.err = err
+			md.mtx.Unlock()
 		}
 		d.defaultErr(err, msgs...)
 	}
 
 	d.exit = func(code int) {
+		md.mtx.Lock()
+		defer md.mtx.Unlock()
 		md.exited = true
 		md.code = code
 	}
@@ -273,14 +422,56 @@ func InitMockDebugger() MockDebugger {
 	return md
 }
 
-func (d *mockDebugger) Output() io.Reader { return &d.buf }
-func (d *mockDebugger) Failed() bool      { return d.failed }
-func (d *mockDebugger) Err() error        { return d.err }
-func (d *mockDebugger) Exited() bool      { return d.exited }
-func (d *mockDebugger) ExitCode() int     { return d.code }
+func (d *mockDebugger) failAt(pos, msg string) { failAt(d.Debugger, pos, msg) }
+func (d *mockDebugger) logAt(pos, msg string)  { logAt(d.Debugger, pos, msg) }
+
+func (d *mockDebugger) Output() io.Reader {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	out := new(bytes.Buffer)
+	_, _ = d.buf.WriteTo(out)
+	return out
+}
+
+func (d *mockDebugger) Failed() bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.failed
+}
+
+func (d *mockDebugger) Err() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.err
+}
+
+func (d *mockDebugger) Exited() bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.exited
+}
+
+func (d *mockDebugger) ExitCode() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.code
+}
+
+// lockedWriter serializes writes to w with mtx.
+type lockedWriter struct {
+	mtx *sync.Mutex
+	w   io.Writer
+}
+
+func (lw lockedWriter) Write(p []byte) (int, error) {
+	lw.mtx.Lock()
+	defer lw.mtx.Unlock()
+	return lw.w.Write(p)
+}
 
 var (
 	_ Debugger     = rootDebugger{}
 	_ Debugger     = prefixedDebugger{}
+	_ Debugger     = collectingDebugger{}
 	_ MockDebugger = &mockDebugger{}
 )
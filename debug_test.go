@@ -387,6 +387,49 @@ func TestInitDebugger(t *testing.T) {
 	assert.NotNil(t, d)
 }
 
+func TestDiagnostic_String(t *testing.T) {
+	t.Parallel()
+
+	err := errors.New("bar")
+
+	assert.Equal(t, "foo", Diagnostic{Message: "foo"}.String())
+	assert.Equal(t, "bar", Diagnostic{Err: err}.String())
+	assert.Equal(t, "foo: bar", Diagnostic{Message: "foo", Err: err}.String())
+}
+
+func TestCollectingDebugger(t *testing.T) {
+	t.Parallel()
+
+	md := InitMockDebugger()
+	diags := &diagnostics{}
+	d := initCollectingDebugger(md, diags)
+
+	d.Fail("foo", "bar")
+	d.Failf("foo%s", "bar")
+	d.CheckErr(nil, "no error")
+	d.CheckErr(errors.New("baz"), "checked")
+	d.Assert(true, "no failure")
+	d.Assert(false, "asserted")
+	d.Push("fizz").Push("buzz").Fail("pushed")
+
+	assert.False(t, md.Failed())
+	assert.False(t, md.Exited())
+
+	assert.Equal(t, []Diagnostic{
+		{Message: "foobar"},
+		{Message: "foobar"},
+		{Message: "checked", Err: errors.New("baz")},
+		{Message: "asserted"},
+		{Message: "[fizz][buzz]pushed"},
+	}, diags.all())
+
+	assert.Len(t, diags.flush(), 5)
+	assert.Zero(t, diags.len())
+
+	d.Push("fizz").Pop().Fail("popped")
+	assert.Equal(t, []Diagnostic{{Message: "popped"}}, diags.all())
+}
+
 func TestMockDebugger_Output(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,298 @@
+package pgs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+)
+
+// DryRunFormat describes the report produced by the DryRun InitOption.
+type DryRunFormat int
+
+const (
+	// DryRunManifest reports a JSON manifest describing each file the
+	// Generator would create, overwrite, or skip.
+	DryRunManifest DryRunFormat = iota
+
+	// DryRunDiff reports a unified diff between the files on disk and the
+	// content the Generator would write.
+	DryRunDiff
+)
+
+// DryRunAction describes what the Generator would do with a file.
+type DryRunAction string
+
+const (
+	// DryRunCreate indicates the file does not yet exist.
+	DryRunCreate DryRunAction = "create"
+
+	// DryRunOverwrite indicates the file exists and its content would change.
+	DryRunOverwrite DryRunAction = "overwrite"
+
+	// DryRunUnchanged indicates the file exists with identical content.
+	DryRunUnchanged DryRunAction = "unchanged"
+
+	// DryRunSkip indicates the file exists and would not be overwritten, as
+	// the CustomFile or CustomTemplateFile did not set Overwrite.
+	DryRunSkip DryRunAction = "skip"
+
+	// DryRunInsert indicates the content would be injected into another
+	// plugin's file at an insertion point.
+	DryRunInsert DryRunAction = "insert"
+
+	// DryRunDelete indicates the file would be deleted as it is stale. See
+	// the Manifest InitOption.
+	DryRunDelete DryRunAction = "delete"
+)
+
+// DryRunEntry describes a single file in a DryRunManifest report.
+type DryRunEntry struct {
+	// Name is the path of the file.
+	Name string `json:"name"`
+
+	// Action is what the Generator would do with the file.
+	Action DryRunAction `json:"action"`
+
+	// Custom is true if the file would be written directly by the persister
+	// instead of emitted to protoc.
+	Custom bool `json:"custom"`
+
+	// InsertionPoint is the insertion point targeted by DryRunInsert entries.
+	InsertionPoint string `json:"insertion_point,omitempty"`
+}
+
+// DryRun prevents the Generator from writing any files to disk or emitting
+// files to protoc. All Modules and PostProcessors are still executed, and a
+// report of the changes that would have been made is written to w in the
+// specified format instead. If w is nil, os.Stderr is used.
+//
+// Files emitted to protoc are compared against the file system relative to
+// the current working directory, so protoc should be executed from the
+// directory it outputs to. Stale files that would be deleted via the Manifest
+// InitOption are also reported.
+func DryRun(w io.Writer, format DryRunFormat) InitOption {
+	return func(g *Generator) {
+		if w == nil {
+			w = os.Stderr
+		}
+		g.dryRun = &dryRunConfig{out: w, format: format}
+	}
+}
+
+type dryRunConfig struct {
+	out    io.Writer
+	format DryRunFormat
+}
+
+// dryRunPersister wraps a Persister, redirecting its writes to an in-memory
+// layer over the real file system and reporting the differences.
+type dryRunPersister struct {
+	Persister
+	Debugger
+
+	out    io.Writer
+	format DryRunFormat
+
+	base    afero.Fs
+	layer   afero.Fs
+	removed []string
+}
+
+func newDryRunPersister(p Persister, w io.Writer, format DryRunFormat) *dryRunPersister {
+	dp := &dryRunPersister{Persister: p, out: w, format: format}
+	dp.SetFS(afero.NewOsFs())
+	return dp
+}
+
+func (p *dryRunPersister) SetDebugger(d Debugger) {
+	p.Debugger = d
+	p.Persister.SetDebugger(d)
+}
+
+func (p *dryRunPersister) SetFS(fs afero.Fs) {
+	p.base = fs
+	p.layer = afero.NewMemMapFs()
+	p.Persister.SetFS(dryRunFs{
+		Fs: afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(fs), p.layer),
+		p:  p,
+	})
+}
+
+func (p *dryRunPersister) Persist(arts ...Artifact) *plugin_go.CodeGeneratorResponse {
+	resp := p.Persister.Persist(arts...)
+
+	var entries []DryRunEntry
+	var diffs []string
+
+	add := func(e DryRunEntry, content string) {
+		if e.Action == DryRunCreate || e.Action == DryRunOverwrite {
+			old, _ := afero.ReadFile(p.base, e.Name)
+			diffs = append(diffs, p.diff(e, string(old), content))
+		}
+		entries = append(entries, e)
+	}
+
+	for _, f := range mergeResponseFiles(resp.GetFile()) {
+		if f.InsertionPoint != nil {
+			entries = append(entries, DryRunEntry{
+				Name:           f.GetName(),
+				Action:         DryRunInsert,
+				InsertionPoint: f.GetInsertionPoint(),
+			})
+			continue
+		}
+
+		add(DryRunEntry{Name: f.GetName(), Action: p.action(f.GetName(), f.GetContent())}, f.GetContent())
+	}
+
+	for _, a := range arts {
+		var name string
+		var overwrite bool
+
+		switch a := a.(type) {
+		case CustomFile:
+			name, overwrite = a.Name, a.Overwrite
+		case CustomTemplateFile:
+			name, overwrite = a.Name, a.Overwrite
+		default:
+			continue
+		}
+
+		content, err := afero.ReadFile(p.layer, name)
+		switch {
+		case err == nil:
+			add(DryRunEntry{Name: name, Action: p.action(name, string(content)), Custom: true}, string(content))
+		case !overwrite:
+			if exists, _ := afero.Exists(p.base, name); exists {
+				entries = append(entries, DryRunEntry{Name: name, Action: DryRunSkip, Custom: true})
+			}
+		}
+	}
+
+	for _, name := range p.removed {
+		entries = append(entries, DryRunEntry{Name: name, Action: DryRunDelete, Custom: true})
+		old, _ := afero.ReadFile(p.base, name)
+		diffs = append(diffs, p.diff(DryRunEntry{Name: name, Action: DryRunDelete}, string(old), ""))
+	}
+	p.removed = nil
+
+	resp.File = nil
+
+	switch p.format {
+	case DryRunDiff:
+		for _, d := range diffs {
+			_, err := io.WriteString(p.out, d)
+			p.CheckErr(err, "unable to write dry run diff")
+		}
+	default:
+		b, err := json.MarshalIndent(struct {
+			Files []DryRunEntry `json:"files"`
+		}{entries}, "", "  ")
+		p.CheckErr(err, "unable to marshal dry run manifest")
+		_, err = fmt.Fprintln(p.out, string(b))
+		p.CheckErr(err, "unable to write dry run manifest")
+	}
+
+	return resp
+}
+
+func (p *dryRunPersister) action(name, content string) DryRunAction {
+	old, err := afero.ReadFile(p.base, name)
+	switch {
+	case err != nil:
+		return DryRunCreate
+	case string(old) == content:
+		return DryRunUnchanged
+	default:
+		return DryRunOverwrite
+	}
+}
+
+func (p *dryRunPersister) diff(e DryRunEntry, old, content string) string {
+	from, to := "a/"+e.Name, "b/"+e.Name
+	switch e.Action {
+	case DryRunCreate:
+		from = "/dev/null"
+	case DryRunDelete:
+		to = "/dev/null"
+	}
+
+	d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(old),
+		B:        splitLines(content),
+		FromFile: from,
+		ToFile:   to,
+		Context:  3,
+	})
+	p.CheckErr(err, "unable to diff ", e.Name)
+
+	return d
+}
+
+// dryRunFs records files removed by the wrapped Persister instead of removing
+// them, as the underlying copy-on-write file system cannot remove files from
+// its read-only base.
+type dryRunFs struct {
+	afero.Fs
+	p *dryRunPersister
+}
+
+func (fs dryRunFs) Remove(name string) error {
+	if exists, _ := afero.Exists(fs.p.layer, name); exists {
+		if err := fs.p.layer.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	if exists, _ := afero.Exists(fs.p.base, name); exists {
+		fs.p.removed = append(fs.p.removed, name)
+	}
+
+	return nil
+}
+
+// splitLines splits s into lines, each retaining its trailing newline. A
+// newline is added to the final line if it is missing.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+
+	return lines
+}
+
+// mergeResponseFiles combines each file in files with any content appended to
+// it (entries without a name), returning the complete files in order.
+func mergeResponseFiles(files []*plugin_go.CodeGeneratorResponse_File) []*plugin_go.CodeGeneratorResponse_File {
+	var out []*plugin_go.CodeGeneratorResponse_File
+	for _, f := range files {
+		if f.Name == nil && len(out) > 0 {
+			last := out[len(out)-1]
+			last.Content = proto.String(last.GetContent() + f.GetContent())
+			continue
+		}
+
+		out = append(out, &plugin_go.CodeGeneratorResponse_File{
+			Name:           f.Name,
+			InsertionPoint: f.InsertionPoint,
+			Content:        f.Content,
+		})
+	}
+	return out
+}
+
+var _ Persister = (*dryRunPersister)(nil)
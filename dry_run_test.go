@@ -0,0 +1,144 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dummyDryRunPersister(format DryRunFormat) (*dryRunPersister, afero.Fs, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	fs := afero.NewMemMapFs()
+
+	p := newDryRunPersister(dummyPersister(InitMockDebugger()), out, format)
+	p.SetDebugger(InitMockDebugger())
+	p.SetFS(fs)
+
+	return p, fs, out
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	DryRun(nil, DryRunDiff)(g)
+
+	require.NotNil(t, g.dryRun)
+	assert.Equal(t, DryRunDiff, g.dryRun.format)
+	assert.NotNil(t, g.dryRun.out)
+
+	fs := afero.NewMemMapFs()
+	g = Init(FileSystem(fs), DryRun(nil, DryRunManifest))
+
+	require.IsType(t, &dryRunPersister{}, g.persister)
+	p := g.persister.(*dryRunPersister)
+	assert.IsType(t, &stdPersister{}, p.Persister)
+	assert.Equal(t, fs, p.base)
+}
+
+func TestDryRunPersister_Manifest(t *testing.T) {
+	t.Parallel()
+
+	p, fs, out := dummyDryRunPersister(DryRunManifest)
+
+	require.NoError(t, afero.WriteFile(fs, "same.txt", []byte("same"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "changed.txt", []byte("old"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "skipped.txt", []byte("old"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "gen.pb.go", []byte("old"), 0644))
+
+	resp := p.Persist(
+		GeneratorFile{Name: "gen.pb.go", Contents: "new"},
+		GeneratorAppend{FileName: "gen.pb.go", Contents: " appended"},
+		GeneratorFile{Name: "new.pb.go", Contents: "new"},
+		GeneratorInjection{FileName: "other.pb.go", InsertionPoint: "imports", Contents: "foo"},
+		CustomFile{Name: "same.txt", Contents: "same", Overwrite: true},
+		CustomFile{Name: "changed.txt", Contents: "new", Overwrite: true},
+		CustomFile{Name: "skipped.txt", Contents: "new"},
+		CustomFile{Name: "dir/created.txt", Contents: "new"},
+	)
+
+	assert.Empty(t, resp.File)
+
+	var manifest struct {
+		Files []DryRunEntry `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &manifest))
+
+	assert.Equal(t, []DryRunEntry{
+		{Name: "gen.pb.go", Action: DryRunOverwrite},
+		{Name: "new.pb.go", Action: DryRunCreate},
+		{Name: "other.pb.go", Action: DryRunInsert, InsertionPoint: "imports"},
+		{Name: "same.txt", Action: DryRunUnchanged, Custom: true},
+		{Name: "changed.txt", Action: DryRunOverwrite, Custom: true},
+		{Name: "skipped.txt", Action: DryRunSkip, Custom: true},
+		{Name: "dir/created.txt", Action: DryRunCreate, Custom: true},
+	}, manifest.Files)
+
+	for name, content := range map[string]string{
+		"changed.txt":     "old",
+		"skipped.txt":     "old",
+		"gen.pb.go":       "old",
+		"dir/created.txt": "",
+	} {
+		b, _ := afero.ReadFile(fs, name)
+		assert.Equal(t, content, string(b), name)
+	}
+}
+
+func TestDryRunPersister_Diff(t *testing.T) {
+	t.Parallel()
+
+	p, fs, out := dummyDryRunPersister(DryRunDiff)
+	require.NoError(t, afero.WriteFile(fs, "foo.txt", []byte("a\nb\n"), 0644))
+
+	p.Persist(
+		CustomFile{Name: "foo.txt", Contents: "a\nc\n", Overwrite: true},
+		GeneratorFile{Name: "bar.txt", Contents: "bar\n"},
+	)
+
+	assert.Equal(t, `--- /dev/null
++++ b/bar.txt
+@@ -0,0 +1 @@
++bar
+--- a/foo.txt
++++ b/foo.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`, out.String())
+}
+
+func TestDryRunPersister_Delete(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "manifest", []byte("stale.txt\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "stale.txt", []byte("foo\n"), 0644))
+
+	g := Init(
+		Manifest("manifest", DeleteStaleFiles),
+		DryRun(out, DryRunDiff),
+		FileSystem(fs),
+	)
+	g.persister.Persist()
+
+	assert.Equal(t, `--- a/stale.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-foo
+`, out.String())
+
+	exists, err := afero.Exists(fs, "stale.txt")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	b, err := afero.ReadFile(fs, "manifest")
+	assert.NoError(t, err)
+	assert.Equal(t, "stale.txt\n", string(b))
+}
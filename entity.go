@@ -2,6 +2,7 @@ package pgs
 
 import (
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 )
 
 // Entity describes any member of the proto AST that is extensible via
@@ -17,10 +18,17 @@ type Entity interface {
 	// '.helloworld.HelloRequest'.
 	FullyQualifiedName() string
 
-	// Syntax identifies whether this entity is encoded with proto2 or proto3
-	// syntax.
+	// Syntax identifies whether this entity is encoded with proto2, proto3, or
+	// editions syntax.
 	Syntax() Syntax
 
+	// Features returns the resolved FeatureSet for this entity. Features are
+	// inherited from the containing entity (ultimately, the defaults of the
+	// File's Edition) and overridden by the entity's own options. For proto2
+	// and proto3 files, the equivalent legacy behavior is expressed as
+	// features.
+	Features() *descriptor.FeatureSet
+
 	// Package returns the container package for this entity.
 	Package() Package
 
@@ -35,7 +43,8 @@ type Entity interface {
 	// desc and populates the value ext. Ext must be a pointer type. An error
 	// will only be returned if there is a type mismatch between desc and ext.
 	// The ok value will be true if the extension was found. If the extension
-	// is NOT found, ok will be false and err will be nil.
+	// is NOT found, ok will be false and err will be nil. Use OptionValue or
+	// OptionValueByName to read options not compiled into the plugin.
 	Extension(desc *proto.ExtensionDesc, ext interface{}) (ok bool, err error)
 
 	// BuildTarget identifies whether or not generation should be performed on
@@ -1,8 +1,11 @@
 package pgs
 
 import (
+	"sync"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // Enum describes an enumeration type. Its parent can be either a Message or a
@@ -13,6 +16,11 @@ type Enum interface {
 	// Descriptor returns the proto descriptor for this Enum
 	Descriptor() *descriptor.EnumDescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this Enum,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved.
+	ReflectDescriptor() protoreflect.EnumDescriptor
+
 	// Parent resolves to either a Message or File that directly contains this
 	// Enum.
 	Parent() ParentEntity
@@ -20,6 +28,27 @@ type Enum interface {
 	// Values returns each defined enumeration value.
 	Values() []EnumValue
 
+	// ValueByNumber returns the EnumValue with number n, or nil if there is
+	// none. If the Enum allows aliases, the first declared value with that
+	// number is returned; the others are available via Values.
+	ValueByNumber(n int32) EnumValue
+
+	// AllowAlias returns true if multiple values of this Enum may share the
+	// same number (ie, the allow_alias option is set).
+	AllowAlias() bool
+
+	// ReservedRanges returns the value numbers reserved by this Enum.
+	ReservedRanges() []Range
+
+	// ReservedNames returns the value names reserved by this Enum.
+	ReservedNames() []Name
+
+	// IsClosed returns true if the enum is closed, meaning unknown values are
+	// treated as unknown fields when parsed. Proto2 enums are always closed,
+	// while proto3 enums are open. With editions, this is controlled by the
+	// enum_type feature.
+	IsClosed() bool
+
 	// Dependents returns all of the messages where Enum is directly or
 	// transitively used.
 	Dependents() []Message
@@ -37,6 +66,7 @@ type enum struct {
 	fqn             string
 	dependents      []Message
 	dependentsCache map[string]Message
+	dependentsOnce  sync.Once
 }
 
 func (e *enum) Name() Name                                  { return Name(e.desc.GetName()) }
@@ -51,20 +81,42 @@ func (e *enum) Parent() ParentEntity                        { return e.parent }
 func (e *enum) Imports() []File                             { return nil }
 func (e *enum) Values() []EnumValue                         { return e.vals }
 
-func (e *enum) populateDependentsCache() {
-	if e.dependentsCache != nil {
-		return
-	}
+func (e *enum) AllowAlias() bool { return e.desc.GetOptions().GetAllowAlias() }
 
-	e.dependentsCache = map[string]Message{}
-	for _, dep := range e.dependents {
-		e.dependentsCache[dep.FullyQualifiedName()] = dep
-		dep.getDependents(e.dependentsCache)
+func (e *enum) ValueByNumber(n int32) EnumValue {
+	for _, v := range e.vals {
+		if v.Value() == n {
+			return v
+		}
 	}
+	return nil
+}
+
+func (e *enum) ReservedRanges() []Range {
+	return enumReservedRanges(e.desc.GetReservedRange())
+}
+
+func (e *enum) ReservedNames() []Name {
+	return reservedNames(e.desc.GetReservedName())
+}
+
+func (e *enum) Features() *descriptor.FeatureSet {
+	return resolveFeatures(e.parent.Features(), e.desc.GetOptions().GetFeatures())
+}
+
+func (e *enum) IsClosed() bool {
+	return e.Features().GetEnumType() == descriptor.FeatureSet_CLOSED
 }
 
 func (e *enum) Dependents() []Message {
-	e.populateDependentsCache()
+	e.dependentsOnce.Do(func() {
+		set := map[string]Message{}
+		for _, dep := range e.dependents {
+			set[dep.FullyQualifiedName()] = dep
+			dep.getDependents(set)
+		}
+		e.dependentsCache = set
+	})
 	return messageSetToSlice("", e.dependentsCache)
 }
 
@@ -72,6 +124,11 @@ func (e *enum) Extension(desc *proto.ExtensionDesc, ext interface{}) (bool, erro
 	return extension(e.desc.GetOptions(), desc, &ext)
 }
 
+func (e *enum) ReflectDescriptor() protoreflect.EnumDescriptor {
+	d, _ := findDescriptor(e).(protoreflect.EnumDescriptor)
+	return d
+}
+
 func (e *enum) accept(v Visitor) (err error) {
 	if v == nil {
 		return nil
@@ -194,6 +194,66 @@ func TestEnum_ChildAtPath(t *testing.T) {
 	assert.Nil(t, e.childAtPath([]int32{999, 123}))
 }
 
+func TestEnum_IsClosed(t *testing.T) {
+	t.Parallel()
+
+	e := dummyEnum()
+	assert.False(t, e.IsClosed())
+
+	fl := e.File().(*file)
+	fl.desc.Syntax = nil
+	assert.True(t, e.IsClosed())
+
+	fl.desc.Syntax = proto.String(string(Editions))
+	fl.desc.Edition = descriptor.Edition_EDITION_2023.Enum()
+	assert.False(t, e.IsClosed())
+
+	e.desc.Options = &descriptor.EnumOptions{Features: &descriptor.FeatureSet{
+		EnumType: descriptor.FeatureSet_CLOSED.Enum(),
+	}}
+	assert.True(t, e.IsClosed())
+
+	ev := &enumVal{desc: &descriptor.EnumValueDescriptorProto{}}
+	e.addValue(ev)
+	assert.Equal(t, descriptor.FeatureSet_CLOSED, ev.Features().GetEnumType())
+}
+
+func TestEnum_Reserved(t *testing.T) {
+	t.Parallel()
+
+	e := &enum{desc: &descriptor.EnumDescriptorProto{
+		ReservedRange: []*descriptor.EnumDescriptorProto_EnumReservedRange{
+			{Start: proto.Int32(2), End: proto.Int32(2)},
+			{Start: proto.Int32(10), End: proto.Int32(20)},
+		},
+		ReservedName: []string{"FOO"},
+	}}
+
+	assert.Equal(t, []Range{{2, 2}, {10, 20}}, e.ReservedRanges())
+	assert.Equal(t, []Name{"FOO"}, e.ReservedNames())
+}
+
+func TestEnum_ValueByNumber(t *testing.T) {
+	t.Parallel()
+
+	e := &enum{desc: &descriptor.EnumDescriptorProto{}}
+	assert.False(t, e.AllowAlias())
+
+	e.desc.Options = &descriptor.EnumOptions{AllowAlias: proto.Bool(true)}
+	assert.True(t, e.AllowAlias())
+
+	foo := &enumVal{desc: &descriptor.EnumValueDescriptorProto{Name: proto.String("FOO"), Number: proto.Int32(0)}}
+	bar := &enumVal{desc: &descriptor.EnumValueDescriptorProto{Name: proto.String("BAR"), Number: proto.Int32(1)}}
+	alias := &enumVal{desc: &descriptor.EnumValueDescriptorProto{Name: proto.String("BAZ"), Number: proto.Int32(1)}}
+	e.addValue(foo)
+	e.addValue(bar)
+	e.addValue(alias)
+
+	assert.Equal(t, foo, e.ValueByNumber(0))
+	assert.Equal(t, bar, e.ValueByNumber(1))
+	assert.Nil(t, e.ValueByNumber(2))
+}
+
 type mockEnum struct {
 	Enum
 	p   ParentEntity
@@ -3,6 +3,7 @@ package pgs
 import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // An EnumValue describes a name-value pair for an entry in an enum.
@@ -12,6 +13,11 @@ type EnumValue interface {
 	// Descriptor returns the proto descriptor for this Enum Value
 	Descriptor() *descriptor.EnumValueDescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this Enum Value,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved.
+	ReflectDescriptor() protoreflect.EnumValueDescriptor
+
 	// Enum returns the parent Enum for this value
 	Enum() Enum
 
@@ -41,10 +47,21 @@ func (ev *enumVal) Enum() Enum                                       { return ev
 func (ev *enumVal) Value() int32                                     { return ev.desc.GetNumber() }
 func (ev *enumVal) Imports() []File                                  { return nil }
 
+func (ev *enumVal) Features() *descriptor.FeatureSet {
+	return resolveFeatures(ev.enum.Features(), ev.desc.GetOptions().GetFeatures())
+}
+
 func (ev *enumVal) Extension(desc *proto.ExtensionDesc, ext interface{}) (bool, error) {
 	return extension(ev.desc.GetOptions(), desc, &ext)
 }
 
+func (ev *enumVal) ReflectDescriptor() protoreflect.EnumValueDescriptor {
+	if ed := ev.enum.ReflectDescriptor(); ed != nil {
+		return ed.Values().ByName(protoreflect.Name(ev.Name()))
+	}
+	return nil
+}
+
 func (ev *enumVal) accept(v Visitor) (err error) {
 	if v == nil {
 		return nil
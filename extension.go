@@ -6,6 +6,8 @@ import (
 	"reflect"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // An Extension is a custom option annotation that can be applied to an Entity to provide additional
@@ -39,10 +41,25 @@ func (e *ext) DefinedIn() ParentEntity    { return e.parent }
 func (e *ext) Extendee() Message          { return e.extendee }
 func (e *ext) Message() Message           { return nil }
 func (e *ext) InOneOf() bool              { return false }
+func (e *ext) InRealOneOf() bool          { return false }
 func (e *ext) OneOf() OneOf               { return nil }
 func (e *ext) setMessage(m Message)       {} // noop
 func (e *ext) setOneOf(o OneOf)           {} // noop
 func (e *ext) setExtendee(m Message)      { e.extendee = m }
+func (e *ext) HasPresence() bool          { return fieldHasPresence(e) }
+func (e *ext) HasOptionalKeyword() bool   { return fieldHasOptionalKeyword(e) }
+func (e *ext) Required() bool             { return fieldRequired(e) }
+func (e *ext) IsPacked() bool             { return fieldIsPacked(e) }
+func (e *ext) EnforceUTF8() bool          { return fieldEnforceUTF8(e) }
+
+func (e *ext) Default() (interface{}, error) { return fieldDefault(e) }
+
+func (e *ext) Features() *descriptor.FeatureSet { return fieldFeatures(e.parent, e) }
+
+func (e *ext) ReflectDescriptor() protoreflect.FieldDescriptor {
+	d, _ := findDescriptor(e).(protoreflect.ExtensionDescriptor)
+	return d
+}
 
 func (e *ext) accept(v Visitor) (err error) {
 	if v == nil {
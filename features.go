@@ -0,0 +1,140 @@
+package pgs
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// editionDefaults returns the FeatureSet defaults for edition e. These match
+// the defaults declared on the FeatureSet message in descriptor.proto.
+func editionDefaults(e Edition) *descriptor.FeatureSet {
+	switch {
+	case e >= Edition2023:
+		return &descriptor.FeatureSet{
+			FieldPresence:         descriptor.FeatureSet_EXPLICIT.Enum(),
+			EnumType:              descriptor.FeatureSet_OPEN.Enum(),
+			RepeatedFieldEncoding: descriptor.FeatureSet_PACKED.Enum(),
+			Utf8Validation:        descriptor.FeatureSet_VERIFY.Enum(),
+			MessageEncoding:       descriptor.FeatureSet_LENGTH_PREFIXED.Enum(),
+			JsonFormat:            descriptor.FeatureSet_ALLOW.Enum(),
+		}
+	case e == EditionProto3:
+		return &descriptor.FeatureSet{
+			FieldPresence:         descriptor.FeatureSet_IMPLICIT.Enum(),
+			EnumType:              descriptor.FeatureSet_OPEN.Enum(),
+			RepeatedFieldEncoding: descriptor.FeatureSet_PACKED.Enum(),
+			Utf8Validation:        descriptor.FeatureSet_VERIFY.Enum(),
+			MessageEncoding:       descriptor.FeatureSet_LENGTH_PREFIXED.Enum(),
+			JsonFormat:            descriptor.FeatureSet_ALLOW.Enum(),
+		}
+	default:
+		return &descriptor.FeatureSet{
+			FieldPresence:         descriptor.FeatureSet_EXPLICIT.Enum(),
+			EnumType:              descriptor.FeatureSet_CLOSED.Enum(),
+			RepeatedFieldEncoding: descriptor.FeatureSet_EXPANDED.Enum(),
+			Utf8Validation:        descriptor.FeatureSet_NONE.Enum(),
+			MessageEncoding:       descriptor.FeatureSet_LENGTH_PREFIXED.Enum(),
+			JsonFormat:            descriptor.FeatureSet_LEGACY_BEST_EFFORT.Enum(),
+		}
+	}
+}
+
+// resolveFeatures merges each of the overrides (in order) on top of a copy of
+// the parent FeatureSet. Nil overrides are ignored.
+func resolveFeatures(parent *descriptor.FeatureSet, overrides ...*descriptor.FeatureSet) *descriptor.FeatureSet {
+	out := proto.Clone(parent).(*descriptor.FeatureSet)
+	for _, o := range overrides {
+		if o != nil {
+			proto.Merge(out, o)
+		}
+	}
+	return out
+}
+
+// legacyFieldFeatures converts the proto2 and proto3 field-level options and
+// labels into their equivalent features. Nil is returned for files using
+// editions syntax, as these must declare features explicitly.
+func legacyFieldFeatures(f Field) *descriptor.FeatureSet {
+	desc := f.Descriptor()
+	fs := &descriptor.FeatureSet{}
+
+	switch f.Syntax() {
+	case Editions:
+		return nil
+	case Proto3:
+		if desc.GetProto3Optional() {
+			fs.FieldPresence = descriptor.FeatureSet_EXPLICIT.Enum()
+		}
+	default:
+		if desc.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED {
+			fs.FieldPresence = descriptor.FeatureSet_LEGACY_REQUIRED.Enum()
+		}
+	}
+
+	if opts := desc.GetOptions(); opts != nil && opts.Packed != nil {
+		if opts.GetPacked() {
+			fs.RepeatedFieldEncoding = descriptor.FeatureSet_PACKED.Enum()
+		} else {
+			fs.RepeatedFieldEncoding = descriptor.FeatureSet_EXPANDED.Enum()
+		}
+	}
+
+	if desc.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		fs.MessageEncoding = descriptor.FeatureSet_DELIMITED.Enum()
+	}
+
+	return fs
+}
+
+func fieldFeatures(parent Entity, f Field) *descriptor.FeatureSet {
+	return resolveFeatures(
+		parent.Features(),
+		f.Descriptor().GetOptions().GetFeatures(),
+		legacyFieldFeatures(f))
+}
+
+func fieldHasPresence(f Field) bool {
+	switch typ := f.Type(); {
+	case f.InRealOneOf():
+		return true
+	case typ.IsRepeated(), typ.IsMap():
+		return false
+	case typ.IsEmbed():
+		return true
+	default:
+		return f.Features().GetFieldPresence() != descriptor.FeatureSet_IMPLICIT
+	}
+}
+
+func fieldHasOptionalKeyword(f Field) bool {
+	switch f.Syntax() {
+	case Editions:
+		return false
+	case Proto3:
+		return f.Descriptor().GetProto3Optional()
+	default:
+		return f.Descriptor().GetLabel() == descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	}
+}
+
+func fieldRequired(f Field) bool {
+	return f.Features().GetFieldPresence() == descriptor.FeatureSet_LEGACY_REQUIRED
+}
+
+func fieldIsPacked(f Field) bool {
+	if f.Descriptor().GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return false
+	}
+
+	switch ProtoType(f.Descriptor().GetType()) {
+	case StringT, BytesT, MessageT, GroupT:
+		return false
+	}
+
+	return f.Features().GetRepeatedFieldEncoding() == descriptor.FeatureSet_PACKED
+}
+
+func fieldEnforceUTF8(f Field) bool {
+	return ProtoType(f.Descriptor().GetType()) == StringT &&
+		f.Features().GetUtf8Validation() == descriptor.FeatureSet_VERIFY
+}
@@ -0,0 +1,112 @@
+package pgs
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditionDefaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		edition  Edition
+		presence descriptor.FeatureSet_FieldPresence
+		enum     descriptor.FeatureSet_EnumType
+		repeated descriptor.FeatureSet_RepeatedFieldEncoding
+		utf8     descriptor.FeatureSet_Utf8Validation
+	}{
+		{EditionUnknown, descriptor.FeatureSet_EXPLICIT, descriptor.FeatureSet_CLOSED, descriptor.FeatureSet_EXPANDED, descriptor.FeatureSet_NONE},
+		{EditionProto2, descriptor.FeatureSet_EXPLICIT, descriptor.FeatureSet_CLOSED, descriptor.FeatureSet_EXPANDED, descriptor.FeatureSet_NONE},
+		{EditionProto3, descriptor.FeatureSet_IMPLICIT, descriptor.FeatureSet_OPEN, descriptor.FeatureSet_PACKED, descriptor.FeatureSet_VERIFY},
+		{Edition2023, descriptor.FeatureSet_EXPLICIT, descriptor.FeatureSet_OPEN, descriptor.FeatureSet_PACKED, descriptor.FeatureSet_VERIFY},
+	}
+
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.edition.String(), func(t *testing.T) {
+			t.Parallel()
+
+			fs := editionDefaults(tc.edition)
+			assert.Equal(t, tc.presence, fs.GetFieldPresence())
+			assert.Equal(t, tc.enum, fs.GetEnumType())
+			assert.Equal(t, tc.repeated, fs.GetRepeatedFieldEncoding())
+			assert.Equal(t, tc.utf8, fs.GetUtf8Validation())
+		})
+	}
+}
+
+func TestResolveFeatures(t *testing.T) {
+	t.Parallel()
+
+	parent := editionDefaults(Edition2023)
+	out := resolveFeatures(parent,
+		nil,
+		&descriptor.FeatureSet{FieldPresence: descriptor.FeatureSet_IMPLICIT.Enum()},
+		&descriptor.FeatureSet{EnumType: descriptor.FeatureSet_CLOSED.Enum()})
+
+	assert.Equal(t, descriptor.FeatureSet_IMPLICIT, out.GetFieldPresence())
+	assert.Equal(t, descriptor.FeatureSet_CLOSED, out.GetEnumType())
+	assert.Equal(t, descriptor.FeatureSet_PACKED, out.GetRepeatedFieldEncoding())
+	assert.Equal(t, descriptor.FeatureSet_EXPLICIT, parent.GetFieldPresence(), "parent must not be modified")
+}
+
+func TestLegacyFieldFeatures(t *testing.T) {
+	t.Parallel()
+
+	f := dummyField()
+	assert.Nil(t, legacyFieldFeatures(f).FieldPresence)
+
+	f.desc.Proto3Optional = proto.Bool(true)
+	assert.Equal(t, descriptor.FeatureSet_EXPLICIT, legacyFieldFeatures(f).GetFieldPresence())
+
+	f.desc.Options = &descriptor.FieldOptions{Packed: proto.Bool(false)}
+	assert.Equal(t, descriptor.FeatureSet_EXPANDED, legacyFieldFeatures(f).GetRepeatedFieldEncoding())
+
+	fl := dummyFile()
+	fl.desc.Syntax = nil
+	f.Message().setParent(fl)
+	f.desc.Label = descriptor.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+	f.desc.Type = descriptor.FieldDescriptorProto_TYPE_GROUP.Enum()
+	f.desc.Options.Packed = proto.Bool(true)
+	fs := legacyFieldFeatures(f)
+	assert.Equal(t, descriptor.FeatureSet_LEGACY_REQUIRED, fs.GetFieldPresence())
+	assert.Equal(t, descriptor.FeatureSet_PACKED, fs.GetRepeatedFieldEncoding())
+	assert.Equal(t, descriptor.FeatureSet_DELIMITED, fs.GetMessageEncoding())
+
+	fl.desc.Syntax = proto.String(string(Editions))
+	assert.Nil(t, legacyFieldFeatures(f))
+}
+
+func TestFeatures_Inheritance(t *testing.T) {
+	t.Parallel()
+
+	f := dummyField()
+	fl := f.File().(*file)
+	fl.desc.Syntax = proto.String(string(Editions))
+	fl.desc.Edition = descriptor.Edition_EDITION_2023.Enum()
+
+	assert.Equal(t, descriptor.FeatureSet_EXPLICIT, f.Features().GetFieldPresence())
+	assert.True(t, f.HasPresence())
+
+	fl.desc.Options = &descriptor.FileOptions{Features: &descriptor.FeatureSet{
+		FieldPresence: descriptor.FeatureSet_IMPLICIT.Enum(),
+	}}
+	assert.Equal(t, descriptor.FeatureSet_IMPLICIT, f.Message().Features().GetFieldPresence())
+	assert.False(t, f.HasPresence())
+
+	m := f.Message().(*msg)
+	m.desc.Options = &descriptor.MessageOptions{Features: &descriptor.FeatureSet{
+		FieldPresence: descriptor.FeatureSet_EXPLICIT.Enum(),
+	}}
+	assert.True(t, f.HasPresence())
+
+	f.desc.Options = &descriptor.FieldOptions{Features: &descriptor.FeatureSet{
+		FieldPresence: descriptor.FeatureSet_LEGACY_REQUIRED.Enum(),
+	}}
+	assert.True(t, f.Required())
+	assert.True(t, f.Type().IsRequired())
+	assert.False(t, f.HasOptionalKeyword())
+}
@@ -3,6 +3,7 @@ package pgs
 import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // A Field describes a member of a Message. A field may also be a member of a
@@ -13,12 +14,23 @@ type Field interface {
 	// Descriptor returns the proto descriptor for this field
 	Descriptor() *descriptor.FieldDescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this field,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved.
+	ReflectDescriptor() protoreflect.FieldDescriptor
+
 	// Message returns the Message containing this Field.
 	Message() Message
 
 	// InOneOf returns true if the field is in a OneOf of the parent Message.
+	// This includes synthetic OneOfs created for proto3 optional fields; use
+	// InRealOneOf to exclude them.
 	InOneOf() bool
 
+	// InRealOneOf returns true if the field is in a OneOf of the parent Message
+	// that is not synthetic.
+	InRealOneOf() bool
+
 	// OneOf returns the OneOf that this field is apart of. Nil is returned if
 	// the field is not within a OneOf.
 	OneOf() OneOf
@@ -27,9 +39,40 @@ type Field interface {
 	Type() FieldType
 
 	// Required returns whether or not the field is labeled as required. This
-	// will only be true if the syntax is proto2.
+	// will only be true if the syntax is proto2 or the field's presence feature
+	// is set to LEGACY_REQUIRED.
 	Required() bool
 
+	// HasPresence returns true if the field distinguishes between an unset
+	// value and its zero value. This is true for message fields, fields within
+	// a real OneOf, proto2 singular fields, and proto3 fields labeled with the
+	// optional keyword. Repeated and map fields never have presence.
+	HasPresence() bool
+
+	// HasOptionalKeyword returns true if the field is prefixed with the
+	// optional keyword in its source. For proto3, this is only true for fields
+	// that track presence via a synthetic OneOf. Files using editions syntax
+	// do not support the keyword, so this is always false for them.
+	HasOptionalKeyword() bool
+
+	// IsPacked returns true if the field is a repeated scalar or enum that is
+	// encoded on the wire in packed form.
+	IsPacked() bool
+
+	// EnforceUTF8 returns true if the field is a string that must contain
+	// valid UTF-8 when parsed or serialized.
+	EnforceUTF8() bool
+
+	// Default returns the default value of the field, parsed from its
+	// default_value: a bool, int32, int64, uint32, uint64, float32, float64,
+	// string or []byte for scalars, or the EnumValue for enums. If no
+	// default_value is declared (as is always the case in proto3), the zero
+	// value of the type, or the first value of an enum, is returned. Nil is
+	// returned for repeated and message fields. An error is returned if the
+	// default_value is malformed. Use EncodeTextValue or EncodeJSONValue to
+	// embed the value as a literal.
+	Default() (interface{}, error)
+
 	setMessage(m Message)
 	setOneOf(o OneOf)
 	addType(t FieldType)
@@ -42,6 +85,9 @@ type field struct {
 	oneof OneOf
 	typ   FieldType
 
+	// resolveType defers hydrating typ in a lazily built AST
+	resolveType *lazyRef
+
 	info SourceCodeInfo
 }
 
@@ -49,7 +95,7 @@ func (f *field) Name() Name                                   { return Name(f.de
 func (f *field) FullyQualifiedName() string                   { return f.fqn }
 func (f *field) Syntax() Syntax                               { return f.msg.Syntax() }
 func (f *field) Package() Package                             { return f.msg.Package() }
-func (f *field) Imports() []File                              { return f.typ.Imports() }
+func (f *field) Imports() []File                              { return f.Type().Imports() }
 func (f *field) File() File                                   { return f.msg.File() }
 func (f *field) BuildTarget() bool                            { return f.msg.BuildTarget() }
 func (f *field) SourceCodeInfo() SourceCodeInfo               { return f.info }
@@ -57,13 +103,31 @@ func (f *field) Descriptor() *descriptor.FieldDescriptorProto { return f.desc }
 func (f *field) Message() Message                             { return f.msg }
 func (f *field) InOneOf() bool                                { return f.oneof != nil }
 func (f *field) OneOf() OneOf                                 { return f.oneof }
-func (f *field) Type() FieldType                              { return f.typ }
 func (f *field) setMessage(m Message)                         { f.msg = m }
 func (f *field) setOneOf(o OneOf)                             { f.oneof = o }
 
-func (f *field) Required() bool {
-	return f.Syntax().SupportsRequiredPrefix() &&
-		f.desc.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+func (f *field) Type() FieldType {
+	f.resolveType.resolve()
+	return f.typ
+}
+
+func (f *field) InRealOneOf() bool {
+	return f.InOneOf() && !f.oneof.IsSynthetic()
+}
+
+func (f *field) HasPresence() bool        { return fieldHasPresence(f) }
+func (f *field) HasOptionalKeyword() bool { return fieldHasOptionalKeyword(f) }
+func (f *field) Required() bool           { return fieldRequired(f) }
+func (f *field) IsPacked() bool           { return fieldIsPacked(f) }
+func (f *field) EnforceUTF8() bool        { return fieldEnforceUTF8(f) }
+
+func (f *field) Default() (interface{}, error) { return fieldDefault(f) }
+
+func (f *field) Features() *descriptor.FeatureSet {
+	if f.InOneOf() {
+		return fieldFeatures(f.oneof, f)
+	}
+	return fieldFeatures(f.msg, f)
 }
 
 func (f *field) addType(t FieldType) {
@@ -75,6 +139,11 @@ func (f *field) Extension(desc *proto.ExtensionDesc, ext interface{}) (ok bool,
 	return extension(f.desc.GetOptions(), desc, &ext)
 }
 
+func (f *field) ReflectDescriptor() protoreflect.FieldDescriptor {
+	d, _ := findDescriptor(f).(protoreflect.FieldDescriptor)
+	return d
+}
+
 func (f *field) accept(v Visitor) (err error) {
 	if v == nil {
 		return
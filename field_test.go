@@ -99,6 +99,69 @@ func TestField_OneOf(t *testing.T) {
 	assert.True(t, f.InOneOf())
 }
 
+func TestField_InRealOneOf(t *testing.T) {
+	t.Parallel()
+
+	f := dummyField()
+	assert.False(t, f.InRealOneOf())
+
+	o := dummyOneof()
+	o.addField(f)
+	assert.True(t, f.InRealOneOf())
+
+	f.desc.Proto3Optional = proto.Bool(true)
+	assert.True(t, f.InOneOf())
+	assert.False(t, f.InRealOneOf())
+}
+
+func TestField_HasOptionalKeyword(t *testing.T) {
+	t.Parallel()
+
+	f := dummyField()
+	assert.False(t, f.HasOptionalKeyword())
+
+	f.desc.Proto3Optional = proto.Bool(true)
+	assert.True(t, f.HasOptionalKeyword())
+
+	fl := dummyFile()
+	fl.desc.Syntax = nil
+	f.Message().setParent(fl)
+	f.desc.Proto3Optional = nil
+	assert.True(t, f.HasOptionalKeyword())
+
+	f.desc.Label = descriptor.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+	assert.False(t, f.HasOptionalKeyword())
+}
+
+func TestField_HasPresence(t *testing.T) {
+	t.Parallel()
+
+	f := dummyField()
+	assert.False(t, f.HasPresence())
+
+	f.desc.Proto3Optional = proto.Bool(true)
+	assert.True(t, f.HasPresence())
+
+	f.desc.Proto3Optional = nil
+	f.addType(&embedT{scalarT: &scalarT{}})
+	assert.True(t, f.HasPresence())
+
+	f.addType(&repT{scalarT: &scalarT{}})
+	assert.False(t, f.HasPresence())
+
+	f.addType(&scalarT{})
+	o := dummyOneof()
+	o.addField(f)
+	o.addField(&field{})
+	assert.True(t, f.HasPresence())
+
+	p2 := dummyField()
+	fl := dummyFile()
+	fl.desc.Syntax = nil
+	p2.Message().setParent(fl)
+	assert.True(t, p2.HasPresence())
+}
+
 func TestField_Type(t *testing.T) {
 	t.Parallel()
 
@@ -158,6 +221,46 @@ func TestField_Required(t *testing.T) {
 	assert.False(t, f.Required(), "proto2 + optional")
 }
 
+func TestField_IsPacked(t *testing.T) {
+	t.Parallel()
+
+	f := dummyField()
+	f.desc.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	assert.False(t, f.IsPacked(), "strings are never packed")
+
+	f.desc.Type = descriptor.FieldDescriptorProto_TYPE_INT32.Enum()
+	assert.True(t, f.IsPacked(), "proto3 defaults to packed")
+
+	f.desc.Options = &descriptor.FieldOptions{Packed: proto.Bool(false)}
+	assert.False(t, f.IsPacked())
+
+	f.desc.Options = nil
+	fl := dummyFile()
+	fl.desc.Syntax = nil
+	f.Message().setParent(fl)
+	assert.False(t, f.IsPacked(), "proto2 defaults to expanded")
+
+	f.desc.Label = descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	f.desc.Options = &descriptor.FieldOptions{Packed: proto.Bool(true)}
+	assert.False(t, f.IsPacked(), "singular fields are never packed")
+}
+
+func TestField_EnforceUTF8(t *testing.T) {
+	t.Parallel()
+
+	f := dummyField()
+	assert.True(t, f.EnforceUTF8())
+
+	f.desc.Type = descriptor.FieldDescriptorProto_TYPE_BYTES.Enum()
+	assert.False(t, f.EnforceUTF8())
+
+	f.desc.Type = descriptor.FieldDescriptorProto_TYPE_STRING.Enum()
+	fl := dummyFile()
+	fl.desc.Syntax = nil
+	f.Message().setParent(fl)
+	assert.False(t, f.EnforceUTF8())
+}
+
 func TestField_ChildAtPath(t *testing.T) {
 	t.Parallel()
 
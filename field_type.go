@@ -22,11 +22,14 @@ type FieldType interface {
 	// repeated fields containing embeds will still return false.
 	IsEmbed() bool
 
-	// IsOptional returns true if the message's syntax is not Proto2 or
-	// the field is prefixed as optional.
+	// IsOptional returns true if the field is prefixed with the optional
+	// keyword. For proto3 fields, this is only true for fields labeled
+	// optional, which are tracked with a synthetic OneOf. See
+	// Field.HasOptionalKeyword.
 	IsOptional() bool
 
-	// IsRequired returns true if and only if the field is prefixed as required.
+	// IsRequired returns true if and only if the field is prefixed as required
+	// or, for editions, its field_presence feature is LEGACY_REQUIRED.
 	IsRequired() bool
 
 	// ProtoType returns the ProtoType value for this field.
@@ -84,11 +87,11 @@ func (s *scalarT) Element() FieldTypeElem { return nil }
 func (s *scalarT) Key() FieldTypeElem     { return nil }
 
 func (s *scalarT) IsOptional() bool {
-	return !s.fld.Syntax().SupportsRequiredPrefix() || s.ProtoLabel() == Optional
+	return s.fld.HasOptionalKeyword()
 }
 
 func (s *scalarT) IsRequired() bool {
-	return s.fld.Syntax().SupportsRequiredPrefix() && s.ProtoLabel() == Required
+	return s.fld.Required()
 }
 
 func (s *scalarT) toElem() FieldTypeElem {
@@ -92,6 +92,9 @@ func TestScalarT_IsOptional(t *testing.T) {
 	f := dummyField()
 	f.addType(s)
 
+	assert.False(t, s.IsOptional())
+
+	f.desc.Proto3Optional = proto.Bool(true)
 	assert.True(t, s.IsOptional())
 
 	fl := dummyFile()
@@ -1,8 +1,11 @@
 package pgs
 
 import (
+	"sync"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // File describes the contents of a single proto file.
@@ -16,6 +19,16 @@ type File interface {
 	// Descriptor returns the underlying descriptor for the proto file
 	Descriptor() *descriptor.FileDescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this proto file,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved; AST.ReflectFiles
+	// reports the cause.
+	ReflectDescriptor() protoreflect.FileDescriptor
+
+	// Edition returns the protobuf edition of this file. Files using proto2 or
+	// proto3 syntax return EditionProto2 and EditionProto3, respectively.
+	Edition() Edition
+
 	// TransitiveImports returns all direct and transitive dependencies of this
 	// File. Use Imports to obtain only direct dependencies.
 	TransitiveImports() []File
@@ -58,11 +71,18 @@ type file struct {
 	defExts                 []Extension
 	dependents              []File
 	dependentsCache         []File
+	dependentsOnce          sync.Once
 	fileDependencies        []File
 	msgs                    []Message
 	srvs                    []Service
 	buildTarget             bool
 	syntaxInfo, packageInfo SourceCodeInfo
+
+	// resolveImports and resolveDependents defer hydrating fileDependencies and
+	// dependents in a lazily built AST
+	resolveImports, resolveDependents *lazyRef
+
+	reg *registry // descriptors of the containing AST
 }
 
 func (f *file) Name() Name                                  { return Name(f.desc.GetName()) }
@@ -78,6 +98,21 @@ func (f *file) SourceCodeInfo() SourceCodeInfo              { return f.SyntaxSou
 func (f *file) SyntaxSourceCodeInfo() SourceCodeInfo        { return f.syntaxInfo }
 func (f *file) PackageSourceCodeInfo() SourceCodeInfo       { return f.packageInfo }
 
+func (f *file) Edition() Edition {
+	switch f.Syntax() {
+	case Proto3:
+		return EditionProto3
+	case Editions:
+		return Edition(f.desc.GetEdition())
+	default:
+		return EditionProto2
+	}
+}
+
+func (f *file) Features() *descriptor.FeatureSet {
+	return resolveFeatures(editionDefaults(f.Edition()), f.desc.GetOptions().GetFeatures())
+}
+
 func (f *file) Enums() []Enum {
 	return f.enums
 }
@@ -107,14 +142,16 @@ func (f *file) Services() []Service {
 }
 
 func (f *file) Imports() []File {
-	out := make([]File, len(f.fileDependencies))
-	copy(out, f.fileDependencies)
+	deps := f.imports()
+	out := make([]File, len(deps))
+	copy(out, deps)
 	return out
 }
 
 func (f *file) TransitiveImports() []File {
-	importMap := make(map[string]File, len(f.fileDependencies))
-	for _, fl := range f.fileDependencies {
+	deps := f.imports()
+	importMap := make(map[string]File, len(deps))
+	for _, fl := range deps {
 		importMap[fl.Name().String()] = fl
 		for _, imp := range fl.TransitiveImports() {
 			importMap[imp.File().Name().String()] = imp
@@ -135,8 +172,9 @@ func (f *file) UnusedImports() []File {
 		public[int(i)] = struct{}{}
 	}
 
-	mp := make(map[string]File, len(f.fileDependencies))
-	for i, fl := range f.fileDependencies {
+	deps := f.imports()
+	mp := make(map[string]File, len(deps))
+	for i, fl := range deps {
 		if _, ok := public[i]; ok {
 			continue
 		}
@@ -164,7 +202,8 @@ func (f *file) UnusedImports() []File {
 }
 
 func (f *file) Dependents() []File {
-	if f.dependentsCache == nil {
+	f.resolveDependents.resolve()
+	f.dependentsOnce.Do(func() {
 		set := make(map[string]File)
 		for _, fl := range f.dependents {
 			set[fl.Name().String()] = fl
@@ -177,10 +216,16 @@ func (f *file) Dependents() []File {
 		for _, d := range set {
 			f.dependentsCache = append(f.dependentsCache, d)
 		}
-	}
+	})
 	return f.dependentsCache
 }
 
+// imports returns the direct dependencies of f, hydrating them if necessary.
+func (f *file) imports() []File {
+	f.resolveImports.resolve()
+	return f.fileDependencies
+}
+
 func (f *file) Extension(desc *proto.ExtensionDesc, ext interface{}) (bool, error) {
 	return extension(f.desc.GetOptions(), desc, &ext)
 }
@@ -189,6 +234,20 @@ func (f *file) DefinedExtensions() []Extension {
 	return f.defExts
 }
 
+func (f *file) ReflectDescriptor() protoreflect.FileDescriptor {
+	if f.reg == nil {
+		return nil
+	}
+
+	files, err := f.reg.Files()
+	if err != nil {
+		return nil
+	}
+
+	fd, _ := files.FindFileByPath(f.Name().String())
+	return fd
+}
+
 func (f *file) accept(v Visitor) (err error) {
 	if v == nil {
 		return nil
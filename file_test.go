@@ -350,6 +350,37 @@ func (f *mockFile) accept(v Visitor) error {
 	return err
 }
 
+func TestFile_Edition(t *testing.T) {
+	t.Parallel()
+
+	f := dummyFile()
+	assert.Equal(t, EditionProto3, f.Edition())
+
+	f.desc.Syntax = nil
+	assert.Equal(t, EditionProto2, f.Edition())
+
+	f.desc.Syntax = proto.String(string(Editions))
+	f.desc.Edition = descriptor.Edition_EDITION_2023.Enum()
+	assert.Equal(t, Edition2023, f.Edition())
+}
+
+func TestFile_Features(t *testing.T) {
+	t.Parallel()
+
+	f := dummyFile()
+	assert.Equal(t, descriptor.FeatureSet_IMPLICIT, f.Features().GetFieldPresence())
+
+	f.desc.Syntax = proto.String(string(Editions))
+	f.desc.Edition = descriptor.Edition_EDITION_2023.Enum()
+	assert.Equal(t, descriptor.FeatureSet_EXPLICIT, f.Features().GetFieldPresence())
+
+	f.desc.Options = &descriptor.FileOptions{Features: &descriptor.FeatureSet{
+		Utf8Validation: descriptor.FeatureSet_NONE.Enum(),
+	}}
+	assert.Equal(t, descriptor.FeatureSet_NONE, f.Features().GetUtf8Validation())
+	assert.Equal(t, descriptor.FeatureSet_EXPLICIT, f.Features().GetFieldPresence())
+}
+
 func dummyFile() *file {
 	pkg := dummyPkg()
 	f := &file{
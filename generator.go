@@ -1,25 +1,45 @@
 package pgs
 
 import (
+	"bytes"
 	"io"
 	"log"
 	"os"
+
+	"github.com/golang/protobuf/proto"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/spf13/afero"
 )
 
 // Generator configures and executes a protoc plugin's lifecycle.
 type Generator struct {
 	Debugger
 
-	persister persister // handles writing artifacts to their output
+	persister Persister // handles writing artifacts to their output
 	workflow  workflow
 
+	fs       afero.Fs        // file system provided via the FileSystem option
+	manifest *manifestConfig // custom file manifest, applied to the persister
+	dryRun   *dryRunConfig   // dry run reporting, applied to the persister
+
 	mods []Module // registered pg* modules
 
 	in  io.Reader // protoc input reader
 	out io.Writer // protoc output writer
 
+	descriptorSet *descriptorSetInput // input provided via FromDescriptorSet
+	parameter     *string             // parameters provided via ProtocParameters
+	outputDir     string              // directory generated files are written to, if set
+	cacheDir      string              // directory PerFileModule artifacts are cached in, if set
+	lazyAST       bool                // whether the AST is hydrated on demand
+
 	debug bool // whether or not to print debug messages
 
+	collectErrors bool         // whether failures are collected instead of exiting
+	diags         *diagnostics // failures collected while collectErrors is enabled
+
+	parallelism int // max number of ConcurrentModules executed at once
+
 	params        Parameters     // CLI parameters passed in from protoc
 	paramMutators []ParamMutator // registered param mutators
 }
@@ -38,8 +58,26 @@ func Init(opts ...InitOption) *Generator {
 		opt(g)
 	}
 
+	if g.manifest != nil {
+		g.persister = newManifestPersister(g.persister, *g.manifest)
+	}
+
+	if g.dryRun != nil {
+		g.persister = newDryRunPersister(g.persister, g.dryRun.out, g.dryRun.format)
+	}
+
+	if g.fs != nil {
+		g.persister.SetFS(g.fs)
+	}
+
 	g.Debugger = initDebugger(g.debug, log.New(os.Stderr, "", 0))
-	g.persister.SetDebugger(g.Debugger)
+
+	if g.collectErrors {
+		g.diags = &diagnostics{}
+		g.persister.SetDebugger(initCollectingDebugger(g.Debugger, g.diags))
+	} else {
+		g.persister.SetDebugger(g.Debugger)
+	}
 
 	return g
 }
@@ -87,5 +125,41 @@ func (g *Generator) Render() {
 	g.workflow.Persist(arts)
 }
 
+// RenderRequest executes the protoc plugin flow in-process against req, as if
+// it were received from protoc, returning the CodeGeneratorResponse instead of
+// writing it to the output io.Writer. The input options (ProtocInput and
+// FromDescriptorSet) and OutputDir are ignored, while ProtocParameters still
+// overrides the parameters of req. Use ApplyResponse to resolve the files of
+// the response. As with Render, only the first call to either method has any
+// effect.
+func (g *Generator) RenderRequest(req *plugin_go.CodeGeneratorRequest) (*plugin_go.CodeGeneratorResponse, error) {
+	in, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	g.in, g.out = bytes.NewReader(in), out
+	g.descriptorSet, g.outputDir = nil, ""
+	g.Render()
+
+	resp := new(plugin_go.CodeGeneratorResponse)
+	if err = proto.Unmarshal(out.Bytes(), resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Diagnostics returns the failures collected so far when the Generator is
+// initialized with the CollectErrors InitOption. Diagnostics already reported
+// via the CodeGeneratorResponse are not included.
+func (g *Generator) Diagnostics() []Diagnostic {
+	if g.diags == nil {
+		return nil
+	}
+	return g.diags.all()
+}
+
 func (g *Generator) push(prefix string) { g.Debugger = g.Push(prefix) }
 func (g *Generator) pop()               { g.Debugger = g.Pop() }
@@ -101,6 +101,39 @@ func TestGenerator_Render(t *testing.T) {
 	assert.NoError(t, proto.Unmarshal(buf.Bytes(), &res))
 }
 
+func TestGenerator_RenderRequest(t *testing.T) {
+	// cannot be parallel
+
+	req := &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"foo.proto"},
+		Parameter:      proto.String("foo=bar"),
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			{
+				Name:        proto.String("foo.proto"),
+				Syntax:      proto.String("proto2"),
+				Package:     proto.String("bar"),
+				MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Msg")}},
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	g := Init(ProtocOutput(buf), ProtocParameters("fizz=buzz"))
+	g.RegisterModule(&perFileModule{ModuleBase: &ModuleBase{}})
+
+	resp, err := g.RenderRequest(req)
+	assert.NoError(t, err)
+	assert.Empty(t, buf.Bytes())
+	assert.Empty(t, resp.GetError())
+	if assert.Len(t, resp.GetFile(), 1) {
+		assert.Equal(t, "Msg.txt", resp.GetFile()[0].GetName())
+		assert.Equal(t, "Msg", resp.GetFile()[0].GetContent())
+	}
+
+	assert.Equal(t, "fizz=buzz", g.params.String())
+	assert.Equal(t, "foo=bar", req.GetParameter(), "the request is not modified")
+}
+
 func TestGenerator_PushPop(t *testing.T) {
 	t.Parallel()
 
@@ -1,12 +1,21 @@
 module github.com/vchitai/protoc-gen-star
 
-go 1.14
+go 1.21
 
 require (
+	github.com/bufbuild/protocompile v0.14.1
 	github.com/gogo/protobuf v1.3.2
-	github.com/golang/protobuf v1.5.2
+	github.com/golang/protobuf v1.5.4
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/spf13/afero v1.6.0
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.9.0
 	google.golang.org/genproto v0.0.0-20210329143202-679c6ae281ee
-	google.golang.org/protobuf v1.26.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/text v0.3.5 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -3,6 +3,7 @@ package pgs
 import (
 	"io"
 	"os"
+	"runtime"
 
 	"github.com/spf13/afero"
 )
@@ -25,6 +26,37 @@ func ProtocInput(r io.Reader) InitOption { return func(g *Generator) { g.in = r
 // os.Stdout is used.
 func ProtocOutput(w io.Writer) InitOption { return func(g *Generator) { g.out = w } }
 
+// FromDescriptorSet reads a serialized FileDescriptorSet from r instead of a
+// CodeGeneratorRequest from protoc, allowing the plugin to be executed
+// outside of protoc (eg, from a build system or a Go test). The set must be
+// self-contained, as generated by `protoc --include_imports -o`. Only the
+// files named in targets are generated; if none are provided, every file in
+// the set is a target. Use ProtocParameters to provide parameters and
+// OutputDir to write the generated files directly to disk.
+func FromDescriptorSet(r io.Reader, targets ...string) InitOption {
+	return func(g *Generator) { g.descriptorSet = &descriptorSetInput{in: r, targets: targets} }
+}
+
+type descriptorSetInput struct {
+	in      io.Reader
+	targets []string
+}
+
+// ProtocParameters overrides the parameter string received from protoc (eg,
+// "paths=source_relative,foo=bar"). This is typically used in conjunction
+// with FromDescriptorSet.
+func ProtocParameters(params string) InitOption {
+	return func(g *Generator) { g.parameter = &params }
+}
+
+// OutputDir writes the generated files directly to dir, instead of emitting
+// them to protoc via the output io.Writer. Files are written to the file
+// system provided via the FileSystem option, if any. Appends and insertion
+// points are applied as with ApplyResponse, resolving insertion points against
+// files already present in dir. Errors reported on the CodeGeneratorResponse
+// are fatal.
+func OutputDir(dir string) InitOption { return func(g *Generator) { g.outputDir = dir } }
+
 // DebugMode enables verbose logging for module development and debugging.
 func DebugMode() InitOption { return func(g *Generator) { g.debug = true } }
 
@@ -40,10 +72,55 @@ func MutateParams(pm ...ParamMutator) InitOption {
 // FileSystem overrides the default file system used to write Artifacts to
 // disk. By default, the OS's file system is used. This option currently only
 // impacts CustomFile and CustomTemplateFile artifacts generated by modules.
-func FileSystem(fs afero.Fs) InitOption { return func(g *Generator) { g.persister.SetFS(fs) } }
+func FileSystem(fs afero.Fs) InitOption {
+	return func(g *Generator) {
+		g.fs = fs
+		g.persister.SetFS(fs)
+	}
+}
+
+// UsePersister replaces the Persister used to convert Artifacts into the
+// CodeGeneratorResponse. The file system provided via the FileSystem option,
+// if any, is applied to p during Init.
+func UsePersister(p Persister) InitOption { return func(g *Generator) { g.persister = p } }
+
+// WrapPersister replaces the Persister with the result of fn, which receives
+// the current Persister. This allows intercepting or redirecting Artifacts
+// while retaining the standard behavior.
+func WrapPersister(fn func(p Persister) Persister) InitOption {
+	return func(g *Generator) { g.persister = fn(g.persister) }
+}
 
 // BiDirectional instructs the Generator to build the AST graph in both
 // directions (ie, accessing dependents of an entity, not just dependencies).
 func BiDirectional() InitOption {
 	return func(g *Generator) { g.workflow = &onceWorkflow{workflow: &standardWorkflow{BiDi: true}} }
 }
+
+// LazyAST builds the AST with ProcessCodeGeneratorRequestLazy, hydrating only
+// the target files up front and every other file as it is reached. Note that
+// the first ReflectDescriptor call still resolves the descriptors of the entire
+// input. This is ignored if BiDirectional is also provided.
+func LazyAST() InitOption { return func(g *Generator) { g.lazyAST = true } }
+
+// CollectErrors prevents failures reported by Modules and the persister (via
+// Fail, Failf, CheckErr, or Assert) from terminating the plugin. Instead, each
+// failure is collected and reported to protoc as a single error on the
+// CodeGeneratorResponse once all Modules have executed. Artifacts from a
+// Module that reported a failure are discarded. Failures while reading the
+// input or building the AST remain fatal.
+func CollectErrors() InitOption { return func(g *Generator) { g.collectErrors = true } }
+
+// ParallelModules permits up to n ConcurrentModules to be executed
+// concurrently. Modules that do not implement ConcurrentModule (or whose
+// Concurrent method returns false) are executed alone, after all Modules
+// registered before them. Artifacts are always returned in the order the
+// Modules were registered. If n is less than 1, runtime.NumCPU is used.
+func ParallelModules(n int) InitOption {
+	return func(g *Generator) {
+		if n < 1 {
+			n = runtime.NumCPU()
+		}
+		g.parallelism = n
+	}
+}
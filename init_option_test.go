@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"math/rand"
 	"os"
+	"runtime"
 	"strconv"
 	"testing"
 
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -61,6 +63,35 @@ func TestProtocInput(t *testing.T) {
 	assert.Equal(t, b, g.in)
 }
 
+func TestFromDescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	assert.Nil(t, g.descriptorSet)
+
+	b := &bytes.Buffer{}
+	FromDescriptorSet(b, "foo.proto", "bar.proto")(g)
+	assert.Equal(t, &descriptorSetInput{in: b, targets: []string{"foo.proto", "bar.proto"}}, g.descriptorSet)
+}
+
+func TestProtocParameters(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	assert.Nil(t, g.parameter)
+
+	ProtocParameters("foo=bar")(g)
+	assert.Equal(t, "foo=bar", *g.parameter)
+}
+
+func TestOutputDir(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	OutputDir("out")(g)
+	assert.Equal(t, "out", g.outputDir)
+}
+
 func TestProtocOutput(t *testing.T) {
 	t.Parallel()
 
@@ -89,3 +120,81 @@ func TestBiDirectional(t *testing.T) {
 
 	assert.True(t, std.BiDi)
 }
+
+func TestLazyAST(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	assert.False(t, g.lazyAST)
+
+	LazyAST()(g)
+	assert.True(t, g.lazyAST)
+}
+
+func TestCollectErrors(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	assert.False(t, g.collectErrors)
+
+	CollectErrors()(g)
+	assert.True(t, g.collectErrors)
+
+	g = Init(CollectErrors())
+	assert.NotNil(t, g.diags)
+	assert.IsType(t, collectingDebugger{}, g.persister.(*stdPersister).Debugger)
+	assert.Empty(t, g.Diagnostics())
+}
+
+func TestParallelModules(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	assert.Zero(t, g.parallelism)
+
+	ParallelModules(3)(g)
+	assert.Equal(t, 3, g.parallelism)
+
+	ParallelModules(0)(g)
+	assert.Equal(t, runtime.NumCPU(), g.parallelism)
+}
+
+func TestUsePersister(t *testing.T) {
+	t.Parallel()
+
+	p := dummyPersister(InitMockDebugger())
+	g := Init(UsePersister(p))
+
+	assert.Equal(t, p, g.persister)
+	assert.IsType(t, rootDebugger{}, p.Debugger)
+}
+
+func TestWrapPersister(t *testing.T) {
+	t.Parallel()
+
+	var wrapped Persister
+	w := &recordingPersister{}
+
+	g := Init(WrapPersister(func(p Persister) Persister {
+		wrapped = p
+		w.Persister = p
+		return w
+	}))
+
+	assert.IsType(t, &stdPersister{}, wrapped)
+	assert.Equal(t, w, g.persister)
+
+	g.persister.SetFS(afero.NewMemMapFs())
+	g.persister.Persist(GeneratorFile{Name: "foo"})
+	assert.Equal(t, []Artifact{GeneratorFile{Name: "foo"}}, w.arts)
+}
+
+type recordingPersister struct {
+	Persister
+	arts []Artifact
+}
+
+func (p *recordingPersister) Persist(a ...Artifact) *plugin_go.CodeGeneratorResponse {
+	p.arts = append(p.arts, a...)
+	return p.Persister.Persist(a...)
+}
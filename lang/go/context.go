@@ -46,7 +46,9 @@ type Context interface {
 
 	// TypeName returns the type name of a Field as it would appear in the
 	// generated message struct from protoc-gen-go. Fields from imported
-	// packages will be prefixed with the package name.
+	// packages will be prefixed with the package name. Scalar and enum fields
+	// with explicit presence (eg, proto3 optional) are returned as pointer
+	// types.
 	Type(field pgs.Field) TypeName
 
 	// PackageName returns the name of the Node's package as it would appear in
@@ -101,6 +101,14 @@ func TestType(t *testing.T) {
 		{"Proto3.map_ext_enum", "map[uint64]ptype.Syntax"},
 		{"Proto3.map_msg", "map[uint32]*Proto3_Message"},
 		{"Proto3.map_ext_msg", "map[int64]*duration.Duration"},
+
+		// proto3 syntax, optional
+		{"Proto3.optional_scalar", "*float64"},
+		{"Proto3.optional_string", "*string"},
+		{"Proto3.optional_bytes", "[]byte"},
+		{"Proto3.optional_enum", "*Proto3_Enum"},
+		{"Proto3.optional_ext_enum", "*ptype.Syntax"},
+		{"Proto3.optional_msg", "*Proto3_Message"},
 	}
 
 	for _, test := range tests {
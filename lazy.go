@@ -0,0 +1,364 @@
+package pgs
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// ProcessCodeGeneratorRequestLazy has the same functionality as
+// ProcessCodeGeneratorRequest, but only hydrates the target files up front.
+// Every other file is hydrated on demand, the first time it is reached from a
+// hydrated entity (eg, via a field's Type, a method's Input or a file's
+// Imports) or by Lookup. This can significantly reduce the cost of building the
+// AST for very large requests where the targets only depend on a small portion
+// of the input. The resulting AST behaves the same as an eagerly built one and
+// is safe for concurrent use.
+//
+// Bidirectional resolution is not supported lazily, as computing the dependents
+// of an entity requires hydrating the entire graph. Creating a Mutator from the
+// AST hydrates all remaining files. Likewise, the first call to ReflectFiles or
+// to any entity's ReflectDescriptor builds the protoreflect descriptors of every
+// file in the request, though without hydrating them.
+func ProcessCodeGeneratorRequestLazy(debug Debugger, req *plugin_go.CodeGeneratorRequest) AST {
+	g := &graph{
+		d:          debug,
+		req:        req,
+		targets:    make(map[string]File, len(req.GetFileToGenerate())),
+		packages:   make(map[string]Package),
+		entities:   make(map[string]Entity),
+		extensions: []Extension{},
+		reg:        newRegistry(debug, req.GetProtoFile()),
+		lazy:       newLazyIndex(req),
+	}
+
+	for _, f := range req.GetFileToGenerate() {
+		g.targets[f] = nil
+	}
+
+	for name, fds := range g.lazy.pkgFiles {
+		g.packages[name] = g.hydrateLazyPackage(fds)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, f := range req.GetProtoFile() {
+		if _, ok := g.targets[f.GetName()]; ok {
+			g.hydrateLazyFile(f)
+		}
+	}
+
+	return g
+}
+
+// lazyRef defers resolving part of an entity until it is first accessed. A nil
+// lazyRef is always resolved.
+type lazyRef struct {
+	once sync.Once
+	fn   func()
+}
+
+func (r *lazyRef) resolve() {
+	if r != nil {
+		r.once.Do(r.fn)
+	}
+}
+
+// lazyIndex locates the declarations of a CodeGeneratorRequest without
+// hydrating them.
+type lazyIndex struct {
+	files     map[string]*descriptor.FileDescriptorProto // by file name
+	order     map[string]int                             // input position by file name
+	symbols   map[string]string                          // file name by top-level FQN
+	importers map[string][]string                        // importing file names by file name
+	extenders map[string][]string                        // extending file names by extendee FQN
+	pkgFiles  map[string][]*descriptor.FileDescriptorProto
+	hydrated  map[string]File
+}
+
+func newLazyIndex(req *plugin_go.CodeGeneratorRequest) *lazyIndex {
+	idx := &lazyIndex{
+		files:     make(map[string]*descriptor.FileDescriptorProto, len(req.GetProtoFile())),
+		order:     make(map[string]int, len(req.GetProtoFile())),
+		symbols:   make(map[string]string),
+		importers: make(map[string][]string),
+		extenders: make(map[string][]string),
+		pkgFiles:  make(map[string][]*descriptor.FileDescriptorProto),
+		hydrated:  make(map[string]File, len(req.GetProtoFile())),
+	}
+
+	for i, fd := range req.GetProtoFile() {
+		name := fd.GetName()
+		idx.files[name] = fd
+		idx.order[name] = i
+		idx.pkgFiles[fd.GetPackage()] = append(idx.pkgFiles[fd.GetPackage()], fd)
+
+		prefix := ""
+		if pkg := fd.GetPackage(); pkg != "" {
+			prefix = "." + pkg
+		}
+
+		for _, md := range fd.GetMessageType() {
+			idx.symbols[prefix+"."+md.GetName()] = name
+		}
+		for _, ed := range fd.GetEnumType() {
+			idx.symbols[prefix+"."+ed.GetName()] = name
+		}
+		for _, sd := range fd.GetService() {
+			idx.symbols[prefix+"."+sd.GetName()] = name
+		}
+		for _, xd := range fd.GetExtension() {
+			idx.symbols[prefix+"."+xd.GetName()] = name
+		}
+
+		for _, dep := range fd.GetDependency() {
+			idx.importers[dep] = append(idx.importers[dep], name)
+		}
+
+		idx.indexExtensions(name, fd.GetExtension(), fd.GetMessageType())
+	}
+
+	return idx
+}
+
+func (idx *lazyIndex) indexExtensions(name string, exts []*descriptor.FieldDescriptorProto, msgs []*descriptor.DescriptorProto) {
+	for _, xd := range exts {
+		files := idx.extenders[xd.GetExtendee()]
+		if len(files) == 0 || files[len(files)-1] != name {
+			idx.extenders[xd.GetExtendee()] = append(files, name)
+		}
+	}
+
+	for _, md := range msgs {
+		idx.indexExtensions(name, md.GetExtension(), md.GetNestedType())
+	}
+}
+
+// lookupLocked returns the Entity with the provided name, hydrating the file
+// declaring it if necessary. Nil is returned if no such Entity exists. The
+// caller must hold g.mu if the graph is built lazily.
+func (g *graph) lookupLocked(name string) Entity {
+	if e, ok := g.entities[name]; ok || g.lazy == nil {
+		return e
+	}
+
+	if fd, ok := g.lazy.files[name]; ok {
+		return g.hydrateLazyFile(fd)
+	}
+
+	// the entity is declared in, or nested within, a top-level declaration.
+	// Several prefixes may match if packages share names with messages, so
+	// each is tried in turn from the most specific.
+	for prefix := name; prefix != ""; {
+		if fl, ok := g.lazy.symbols[prefix]; ok {
+			g.hydrateLazyFile(g.lazy.files[fl])
+			if e, ok := g.entities[name]; ok {
+				return e
+			}
+		}
+
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+
+	return nil
+}
+
+// hydrateLazyPackage creates the Package shared by fds, deferring hydration of
+// its files until they are accessed.
+func (g *graph) hydrateLazyPackage(fds []*descriptor.FileDescriptorProto) Package {
+	p := &pkg{fd: fds[0]}
+	p.resolveFiles = &lazyRef{fn: func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		files := make([]File, 0, len(fds))
+		for _, fd := range fds {
+			files = append(files, g.hydrateLazyFile(fd))
+		}
+		p.files = files
+	}}
+
+	return p
+}
+
+// hydrateLazyFile hydrates the entities declared in fd, deferring resolution
+// of any references to other files. The caller must hold g.mu.
+func (g *graph) hydrateLazyFile(fd *descriptor.FileDescriptorProto) File {
+	idx := g.lazy
+	if fl, ok := idx.hydrated[fd.GetName()]; ok {
+		return fl
+	}
+
+	fl := &file{
+		pkg:  g.packages[fd.GetPackage()],
+		desc: fd,
+		reg:  g.reg,
+	}
+	if pkg := fd.GetPackage(); pkg != "" {
+		fl.fqn = "." + pkg
+	}
+	g.add(fl)
+	idx.hydrated[fd.GetName()] = fl
+
+	if _, fl.buildTarget = g.targets[fd.GetName()]; fl.buildTarget {
+		g.targets[fd.GetName()] = fl
+	}
+
+	fl.resolveImports = &lazyRef{fn: func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		for _, dep := range fd.GetDependency() {
+			d := g.mustSeenLocked(dep).(File)
+			fl.addFileDependency(d)
+			d.addDependent(fl)
+		}
+	}}
+
+	fl.resolveDependents = &lazyRef{fn: func() {
+		// each importer adds itself to fl's dependents as its imports resolve
+		for _, name := range idx.importers[fd.GetName()] {
+			g.mustSeen(name).(File).Imports()
+		}
+	}}
+
+	start := len(g.extensions)
+
+	enums := fd.GetEnumType()
+	fl.enums = make([]Enum, 0, len(enums))
+	for _, e := range enums {
+		fl.addEnum(g.hydrateEnum(fl, e))
+	}
+
+	exts := fd.GetExtension()
+	fl.defExts = make([]Extension, 0, len(exts))
+	for _, ext := range exts {
+		fl.addDefExtension(g.hydrateExtension(fl, ext))
+	}
+
+	msgs := fd.GetMessageType()
+	fl.msgs = make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		fl.addMessage(g.hydrateMessage(fl, msg))
+	}
+
+	srvs := fd.GetService()
+	fl.srvs = make([]Service, 0, len(srvs))
+	for _, sd := range srvs {
+		fl.addService(g.hydrateService(fl, sd))
+	}
+
+	for _, m := range fl.AllMessages() {
+		for _, me := range m.MapEntries() {
+			for _, fld := range me.Fields() {
+				g.deferFieldType(fld)
+			}
+		}
+
+		for _, fld := range m.Fields() {
+			g.deferFieldType(fld)
+		}
+
+		if len(idx.extenders[m.FullyQualifiedName()]) > 0 {
+			g.deferExtensions(m.(*msg))
+		}
+	}
+
+	g.hydrateSourceCodeInfo(fl, fd)
+
+	// linking extensions may hydrate their extendees' files, which in turn
+	// append to g.extensions
+	for _, e := range g.extensions[start:len(g.extensions):len(g.extensions)] {
+		g.deferFieldType(e)
+		extendee := g.mustSeenLocked(e.Descriptor().GetExtendee()).(Message)
+		e.setExtendee(extendee)
+		if extendee != nil {
+			extendee.addExtension(e)
+		}
+	}
+
+	return fl
+}
+
+// deferFieldType defers hydrating the type of fld until it is accessed. The
+// caller must hold g.mu.
+func (g *graph) deferFieldType(fld Field) {
+	if fld.Descriptor().GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		// fails immediately, as with an eagerly built AST
+		fld.addType(g.hydrateFieldType(fld))
+		return
+	}
+
+	ref := &lazyRef{fn: func() { fld.addType(g.hydrateFieldType(fld)) }}
+
+	switch f := fld.(type) {
+	case *field:
+		f.resolveType = ref
+	case *ext:
+		f.resolveType = ref
+	}
+}
+
+// deferExtensions defers hydrating the files extending m until its
+// Extensions are accessed. The caller must hold g.mu.
+func (g *graph) deferExtensions(m *msg) {
+	idx := g.lazy
+	m.resolveExtensions = &lazyRef{fn: func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		for _, name := range idx.extenders[m.FullyQualifiedName()] {
+			g.hydrateLazyFile(idx.files[name])
+		}
+
+		// match the order of an eagerly built AST, regardless of the order the
+		// files were hydrated
+		sort.SliceStable(m.exts, func(i, j int) bool {
+			return idx.order[m.exts[i].File().Name().String()] < idx.order[m.exts[j].File().Name().String()]
+		})
+	}}
+}
+
+// hydrateAll hydrates every remaining file and reference of a lazily built
+// graph, after which it behaves as if it were built eagerly. This is not safe
+// for concurrent use.
+func (g *graph) hydrateAll() {
+	if g.lazy == nil {
+		return
+	}
+
+	for _, p := range g.packages {
+		p.Files()
+	}
+
+	g.files = make([]File, 0, len(g.req.GetProtoFile()))
+	for _, fd := range g.req.GetProtoFile() {
+		g.files = append(g.files, g.lazy.hydrated[fd.GetName()])
+	}
+
+	for _, e := range g.entities {
+		switch e := e.(type) {
+		case *file:
+			e.resolveImports.resolve()
+			e.resolveDependents.resolve()
+		case *msg:
+			e.resolveExtensions.resolve()
+		case *field:
+			e.resolveType.resolve()
+		case *ext:
+			e.resolveType.resolve()
+		case *method:
+			e.resolveIO.resolve()
+		}
+	}
+
+	g.lazy = nil
+}
@@ -0,0 +1,430 @@
+package pgs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var lazySources = map[string]string{
+	"a/common.proto": `syntax = "proto2";
+package a;
+
+// Shared is referenced by the target.
+message Shared {
+  optional string id = 1;
+  map<string, Shared> children = 2;
+  optional Kind kind = 3;
+  extensions 100 to 200;
+}
+
+enum Kind {
+  KIND_UNSPECIFIED = 0;
+}
+
+message Unused {}
+`,
+	"a/more.proto": `syntax = "proto2";
+package a;
+
+message More {}
+`,
+	"b/ext.proto": `syntax = "proto2";
+package b;
+
+import "a/common.proto";
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.MessageOptions {
+  optional string tag = 50000;
+}
+
+message Holder {
+  extend a.Shared {
+    // note is nested within Holder.
+    optional string note = 100;
+  }
+}
+
+extend a.Shared {
+  repeated a.Kind kinds = 101;
+}
+`,
+	"c/target.proto": `syntax = "proto3";
+package c;
+
+import "a/common.proto";
+import "b/ext.proto";
+
+// Req is the target.
+message Req {
+  option (b.tag) = "req";
+
+  a.Shared shared = 1;
+  repeated a.Kind kinds = 2;
+}
+
+service Svc {
+  // Do does things.
+  rpc Do(Req) returns (a.Shared);
+}
+`,
+	"d/unrelated.proto": `syntax = "proto3";
+package d;
+
+import "a/more.proto";
+
+message Big {
+  a.More more = 1;
+}
+`,
+}
+
+// lazyRequest compiles lazySources into a CodeGeneratorRequest targeting
+// c/target.proto.
+func lazyRequest(t *testing.T) *plugin_go.CodeGeneratorRequest {
+	req := compileRequest(t, lazySources, "c/target.proto", "d/unrelated.proto")
+	req.FileToGenerate = []string{"c/target.proto"}
+	return req
+}
+
+func lazyGraph(t *testing.T) *graph {
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequestLazy(d, lazyRequest(t))
+	require.False(t, d.Failed())
+	require.IsType(t, &graph{}, ast)
+	return ast.(*graph)
+}
+
+func (g *graph) isHydrated(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.lazy.hydrated[name]
+	return ok
+}
+
+// dumpAST describes every entity reachable from ast, in a deterministic order.
+func dumpAST(ast AST) []string {
+	var out []string
+	add := func(format string, args ...interface{}) { out = append(out, fmt.Sprintf(format, args...)) }
+
+	fileNames := func(fs []File) []string {
+		names := make([]string, len(fs))
+		for i, f := range fs {
+			names[i] = f.Name().String()
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	elemType := func(el FieldTypeElem) string {
+		switch {
+		case el.IsEmbed():
+			return el.Embed().FullyQualifiedName()
+		case el.IsEnum():
+			return el.Enum().FullyQualifiedName()
+		default:
+			return el.ProtoType().String()
+		}
+	}
+
+	fieldType := func(ft FieldType) string {
+		switch {
+		case ft.IsMap():
+			return fmt.Sprintf("map<%s, %s>", elemType(ft.Key()), elemType(ft.Element()))
+		case ft.IsRepeated():
+			return "repeated " + elemType(ft.Element())
+		case ft.IsEmbed():
+			return ft.Embed().FullyQualifiedName()
+		case ft.IsEnum():
+			return ft.Enum().FullyQualifiedName()
+		default:
+			return ft.ProtoType().String()
+		}
+	}
+
+	comments := func(info SourceCodeInfo) string {
+		if info == nil {
+			return ""
+		}
+		return info.LeadingComments()
+	}
+
+	dumpExts := func(exts []Extension) {
+		for _, e := range exts {
+			add("extension %s extends %s: %s %q", e.FullyQualifiedName(), e.Extendee().FullyQualifiedName(),
+				fieldType(e.Type()), comments(e.SourceCodeInfo()))
+		}
+	}
+
+	pkgs := make([]string, 0, len(ast.Packages()))
+	for name := range ast.Packages() {
+		pkgs = append(pkgs, name)
+	}
+	sort.Strings(pkgs)
+
+	for _, name := range pkgs {
+		add("package %s", name)
+		for _, f := range ast.Packages()[name].Files() {
+			add("file %s target=%v", f.Name(), f.BuildTarget())
+			add("imports %v transitive %v unused %v", fileNames(f.Imports()), fileNames(f.TransitiveImports()), fileNames(f.UnusedImports()))
+			add("dependents %v", fileNames(f.Dependents()))
+			dumpExts(f.DefinedExtensions())
+
+			for _, m := range f.AllMessages() {
+				add("message %s %q", m.FullyQualifiedName(), comments(m.SourceCodeInfo()))
+				for _, fld := range m.Fields() {
+					add("field %s: %s", fld.FullyQualifiedName(), fieldType(fld.Type()))
+				}
+				dumpExts(m.DefinedExtensions())
+				for _, e := range m.Extensions() {
+					add("extended by %s", e.FullyQualifiedName())
+				}
+			}
+
+			for _, s := range f.Services() {
+				for _, m := range s.Methods() {
+					add("method %s(%s) %s %q", m.FullyQualifiedName(), m.Input().FullyQualifiedName(),
+						m.Output().FullyQualifiedName(), comments(m.SourceCodeInfo()))
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+func TestProcessCodeGeneratorRequestLazy(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	eager := ProcessCodeGeneratorRequest(d, lazyRequest(t))
+	require.False(t, d.Failed())
+
+	lazy := lazyGraph(t)
+	assert.Equal(t, dumpAST(eager), dumpAST(lazy))
+	assert.True(t, proto.Equal(eager.ToFileDescriptorSet(), lazy.ToFileDescriptorSet()))
+	assert.True(t, proto.Equal(eager.ToCodeGeneratorRequest(), lazy.ToCodeGeneratorRequest()))
+
+	for name, f := range eager.Targets() {
+		assert.Equal(t, f.Name(), lazy.Targets()[name].Name())
+	}
+
+	for _, name := range []string{".a.Shared.children", ".b.Holder.note", ".c.Svc.Do", "d/unrelated.proto", ".missing", ""} {
+		e, ok := eager.Lookup(name)
+		le, lok := lazy.Lookup(name)
+		assert.Equal(t, ok, lok, name)
+		if ok && lok {
+			assert.Equal(t, e.FullyQualifiedName(), le.FullyQualifiedName())
+		}
+	}
+}
+
+func TestProcessCodeGeneratorRequestLazy_OnDemand(t *testing.T) {
+	t.Parallel()
+
+	g := lazyGraph(t)
+	require.Len(t, g.Targets(), 1)
+
+	f := g.Targets()["c/target.proto"]
+	require.NotNil(t, f)
+	assert.True(t, f.BuildTarget())
+	assert.Equal(t, " Req is the target.\n", f.Messages()[0].SourceCodeInfo().LeadingComments())
+	assert.True(t, g.isHydrated("c/target.proto"))
+	assert.False(t, g.isHydrated("a/common.proto"))
+
+	t.Run("field type", func(t *testing.T) {
+		shared := f.Messages()[0].Fields()[0].Type().Embed()
+		assert.Equal(t, ".a.Shared", shared.FullyQualifiedName())
+		assert.False(t, shared.BuildTarget())
+		assert.True(t, g.isHydrated("a/common.proto"))
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		e, ok := g.Lookup(".a.More")
+		require.True(t, ok)
+		assert.Equal(t, "a/more.proto", e.File().Name().String())
+
+		_, ok = g.Lookup(".a.More.missing")
+		assert.False(t, ok)
+
+		_, ok = g.Lookup(".d")
+		assert.False(t, ok)
+	})
+
+	t.Run("extensions", func(t *testing.T) {
+		shared, ok := g.Lookup(".a.Shared")
+		require.True(t, ok)
+
+		exts := shared.(Message).Extensions()
+		require.Len(t, exts, 2)
+		assert.Equal(t, ".b.kinds", exts[0].FullyQualifiedName())
+		assert.Equal(t, ".b.Holder.note", exts[1].FullyQualifiedName())
+		assert.Equal(t, shared, exts[0].Extendee())
+	})
+
+	t.Run("dependents", func(t *testing.T) {
+		more, ok := g.Lookup("a/more.proto")
+		require.True(t, ok)
+		assert.False(t, g.isHydrated("d/unrelated.proto"))
+
+		deps := more.(File).Dependents()
+		require.Len(t, deps, 1)
+		assert.Equal(t, "d/unrelated.proto", deps[0].Name().String())
+		assert.False(t, deps[0].BuildTarget())
+	})
+
+	t.Run("mutator", func(t *testing.T) {
+		g := lazyGraph(t)
+
+		_, err := NewMutator(g)
+		require.NoError(t, err)
+		assert.Nil(t, g.lazy)
+		assert.Len(t, g.files, len(g.req.GetProtoFile()))
+		assert.Len(t, g.Packages()["a"].Files(), 2)
+	})
+}
+
+func TestProcessCodeGeneratorRequestLazy_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	expected := dumpAST(ProcessCodeGeneratorRequest(d, lazyRequest(t)))
+
+	g := lazyGraph(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				g.Lookup(".b.Holder.note")
+			}
+			shared := g.Targets()["c/target.proto"].Messages()[0].Fields()[0].Type().Embed()
+			shared.Extensions()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, expected, dumpAST(g))
+}
+
+func TestProcessCodeGeneratorRequestLazy_Group(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	req := &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"foo.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{{
+			Name: proto.String("foo.proto"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name: proto.String("Foo"),
+				Field: []*descriptor.FieldDescriptorProto{{
+					Name: proto.String("bar"),
+					Type: descriptor.FieldDescriptorProto_TYPE_GROUP.Enum(),
+				}},
+			}},
+		}},
+	}
+
+	assert.Panics(t, func() { ProcessCodeGeneratorRequestLazy(d, req) })
+	assert.True(t, d.Failed())
+}
+
+// chainRequest creates a request of n files, each importing and referencing
+// the previous one, targeting only the last.
+func chainRequest(n int) *plugin_go.CodeGeneratorRequest {
+	req := &plugin_go.CodeGeneratorRequest{}
+
+	for i := 0; i < n; i++ {
+		name := "f" + strconv.Itoa(i)
+		fd := &descriptor.FileDescriptorProto{
+			Name:    proto.String(name + ".proto"),
+			Package: proto.String("chain." + name),
+			Syntax:  proto.String("proto3"),
+		}
+
+		msg := &descriptor.DescriptorProto{Name: proto.String("Msg")}
+		for j := 0; j < 20; j++ {
+			msg.Field = append(msg.Field, &descriptor.FieldDescriptorProto{
+				Name:   proto.String("field_" + strconv.Itoa(j)),
+				Number: proto.Int32(int32(j + 1)),
+				Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			})
+		}
+
+		if i > 0 {
+			prev := "f" + strconv.Itoa(i-1)
+			fd.Dependency = []string{prev + ".proto"}
+			msg.Field = append(msg.Field, &descriptor.FieldDescriptorProto{
+				Name:     proto.String("prev"),
+				Number:   proto.Int32(100),
+				Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				TypeName: proto.String(".chain." + prev + ".Msg"),
+			})
+		}
+
+		fd.MessageType = []*descriptor.DescriptorProto{msg}
+		req.ProtoFile = append(req.ProtoFile, fd)
+	}
+
+	req.FileToGenerate = []string{req.ProtoFile[n-1].GetName()}
+	return req
+}
+
+func benchmarkProcess(b *testing.B, req *plugin_go.CodeGeneratorRequest, process func(Debugger, *plugin_go.CodeGeneratorRequest) AST) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d := InitMockDebugger()
+		ast := process(d, req)
+		for _, f := range ast.Targets() {
+			for _, m := range f.AllMessages() {
+				for _, fld := range m.Fields() {
+					fld.Type()
+				}
+			}
+		}
+		if d.Failed() {
+			b.Fatal("failed to process request")
+		}
+	}
+}
+
+func BenchmarkProcessCodeGeneratorRequest_Chain(b *testing.B) {
+	req := chainRequest(500)
+
+	b.Run("eager", func(b *testing.B) { benchmarkProcess(b, req, ProcessCodeGeneratorRequest) })
+	b.Run("lazy", func(b *testing.B) { benchmarkProcess(b, req, ProcessCodeGeneratorRequestLazy) })
+}
+
+func BenchmarkProcessCodeGeneratorRequest_Graph(b *testing.B) {
+	for _, dir := range []string{"editions", "extensions", "info", "messages", "nested", "packageless", "services"} {
+		filename := filepath.Join("testdata", "graph", dir, "code_generator_request.pb.bin")
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			b.Logf("skipping %s: %v", dir, err)
+			continue
+		}
+
+		req := &plugin_go.CodeGeneratorRequest{}
+		if err = proto.Unmarshal(data, req); err != nil {
+			b.Fatalf("unable to unmarshal CDR data at %q: %v", filename, err)
+		}
+
+		b.Run(dir+"/eager", func(b *testing.B) { benchmarkProcess(b, req, ProcessCodeGeneratorRequest) })
+		b.Run(dir+"/lazy", func(b *testing.B) { benchmarkProcess(b, req, ProcessCodeGeneratorRequestLazy) })
+	}
+}
@@ -0,0 +1,154 @@
+package pgs
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/spf13/afero"
+)
+
+// StaleFileAction describes how files listed in a previous manifest but no
+// longer generated are handled. See the Manifest InitOption.
+type StaleFileAction int
+
+const (
+	// ReportStaleFiles logs each stale file, leaving it in place.
+	ReportStaleFiles StaleFileAction = iota
+
+	// DeleteStaleFiles removes each stale file from the file system.
+	DeleteStaleFiles
+)
+
+// manifestHeader is written as the first line of every manifest.
+const manifestHeader = "# Code generated by protoc-gen-star. DO NOT EDIT."
+
+// Manifest records the name of every CustomFile and CustomTemplateFile
+// generated during a run in the file with the provided name, one path per
+// line. On subsequent runs, files listed in the previous manifest that are no
+// longer generated are considered stale and handled according to action. If
+// the run reports an error (eg, with CollectErrors), no files are considered
+// stale and the previous entries are retained. The manifest is written to the
+// same file system as the custom files.
+func Manifest(name string, action StaleFileAction) InitOption {
+	return func(g *Generator) { g.manifest = &manifestConfig{name: name, action: action} }
+}
+
+type manifestConfig struct {
+	name   string
+	action StaleFileAction
+}
+
+// manifestPersister wraps a Persister, tracking the custom files it writes
+// across runs.
+type manifestPersister struct {
+	Persister
+	Debugger
+	manifestConfig
+
+	fs afero.Fs
+}
+
+func newManifestPersister(p Persister, cfg manifestConfig) *manifestPersister {
+	return &manifestPersister{
+		Persister:      p,
+		manifestConfig: cfg,
+		fs:             afero.NewOsFs(),
+	}
+}
+
+func (p *manifestPersister) SetDebugger(d Debugger) {
+	p.Debugger = d
+	p.Persister.SetDebugger(d)
+}
+
+func (p *manifestPersister) SetFS(fs afero.Fs) {
+	p.fs = fs
+	p.Persister.SetFS(fs)
+}
+
+func (p *manifestPersister) Persist(arts ...Artifact) *plugin_go.CodeGeneratorResponse {
+	resp := p.Persister.Persist(arts...)
+
+	generated := make(map[string]struct{})
+	for _, a := range arts {
+		switch a := a.(type) {
+		case CustomFile:
+			generated[filepath.Clean(a.Name)] = struct{}{}
+		case CustomTemplateFile:
+			generated[filepath.Clean(a.Name)] = struct{}{}
+		}
+	}
+
+	for _, name := range p.read() {
+		if _, ok := generated[name]; ok {
+			continue
+		}
+
+		// a failed module may not have produced its files, so the previous
+		// entries are kept rather than treated as stale
+		if resp.GetError() != "" {
+			generated[name] = struct{}{}
+			continue
+		}
+
+		if exists, _ := afero.Exists(p.fs, name); !exists {
+			continue
+		}
+
+		switch p.action {
+		case DeleteStaleFiles:
+			p.Debug("removing stale file:", name)
+			p.CheckErr(p.fs.Remove(name), "unable to remove stale file: ", name)
+		default:
+			p.Log("stale file:", name)
+		}
+	}
+
+	p.write(generated)
+
+	return resp
+}
+
+// read returns the file names listed in the existing manifest, if any.
+func (p *manifestPersister) read() (names []string) {
+	b, err := afero.ReadFile(p.fs, p.name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	p.CheckErr(err, "unable to read manifest: ", p.name)
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			names = append(names, filepath.Clean(line))
+		}
+	}
+	p.CheckErr(s.Err(), "unable to parse manifest: ", p.name)
+
+	return names
+}
+
+func (p *manifestPersister) write(generated map[string]struct{}) {
+	names := make([]string, 0, len(generated))
+	for name := range generated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(manifestHeader + "\n")
+	for _, name := range names {
+		buf.WriteString(name + "\n")
+	}
+
+	dir := filepath.Dir(p.name)
+	p.CheckErr(p.fs.MkdirAll(dir, 0755), "unable to create directory: ", dir)
+	p.CheckErr(afero.WriteFile(p.fs, p.name, buf.Bytes(), 0644), "unable to write manifest: ", p.name)
+}
+
+var _ Persister = (*manifestPersister)(nil)
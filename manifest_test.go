@@ -0,0 +1,109 @@
+package pgs
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dummyManifestPersister(action StaleFileAction) (*manifestPersister, MockDebugger, afero.Fs) {
+	d := InitMockDebugger()
+	fs := afero.NewMemMapFs()
+
+	p := newManifestPersister(dummyPersister(d), manifestConfig{name: "out/manifest", action: action})
+	p.SetDebugger(d)
+	p.SetFS(fs)
+
+	return p, d, fs
+}
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+
+	g := &Generator{}
+	Manifest("foo", DeleteStaleFiles)(g)
+
+	require.NotNil(t, g.manifest)
+	assert.Equal(t, "foo", g.manifest.name)
+	assert.Equal(t, DeleteStaleFiles, g.manifest.action)
+
+	g = Init(Manifest("foo", ReportStaleFiles))
+	assert.IsType(t, &manifestPersister{}, g.persister)
+}
+
+func TestManifestPersister_Persist(t *testing.T) {
+	t.Parallel()
+
+	p, d, fs := dummyManifestPersister(DeleteStaleFiles)
+
+	p.Persist(
+		CustomFile{Name: "out/b.txt", Contents: "b"},
+		CustomFile{Name: "out/a.txt", Contents: "a"},
+		GeneratorFile{Name: "gen.txt", Contents: "gen"},
+	)
+
+	b, err := afero.ReadFile(fs, "out/manifest")
+	require.NoError(t, err)
+	assert.Equal(t, manifestHeader+"\nout/a.txt\nout/b.txt\n", string(b))
+
+	p.Persist(CustomFile{Name: "out/a.txt", Contents: "a"})
+
+	exists, err := afero.Exists(fs, "out/b.txt")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = afero.Exists(fs, "out/a.txt")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	b, err = afero.ReadFile(fs, "out/manifest")
+	require.NoError(t, err)
+	assert.Equal(t, manifestHeader+"\nout/a.txt\n", string(b))
+	assert.False(t, d.Failed())
+}
+
+func TestManifestPersister_Persist_Report(t *testing.T) {
+	t.Parallel()
+
+	p, d, fs := dummyManifestPersister(ReportStaleFiles)
+	require.NoError(t, afero.WriteFile(fs, "out/manifest", []byte("out/stale.txt\nout/missing.txt\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "out/stale.txt", []byte("stale"), 0644))
+
+	p.Persist()
+
+	exists, err := afero.Exists(fs, "out/stale.txt")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	b, err := ioutil.ReadAll(d.Output())
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "stale file: out/stale.txt")
+	assert.NotContains(t, string(b), "missing")
+}
+
+func TestManifestPersister_Persist_Error(t *testing.T) {
+	t.Parallel()
+
+	p, d, fs := dummyManifestPersister(DeleteStaleFiles)
+	require.NoError(t, afero.WriteFile(fs, "out/manifest", []byte("out/a.txt\nout/b.txt\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "out/b.txt", []byte("b"), 0644))
+
+	resp := p.Persist(
+		CustomFile{Name: "out/a.txt", Contents: "a"},
+		CustomFile{Name: "out/c.txt", Contents: "c"},
+		GeneratorError{Message: "module failed"},
+	)
+	assert.Equal(t, "module failed", resp.GetError())
+
+	exists, err := afero.Exists(fs, "out/b.txt")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	b, err := afero.ReadFile(fs, "out/manifest")
+	require.NoError(t, err)
+	assert.Equal(t, manifestHeader+"\nout/a.txt\nout/b.txt\nout/c.txt\n", string(b))
+	assert.False(t, d.Failed())
+}
@@ -1,8 +1,12 @@
 package pgs
 
 import (
+	"sync"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 // Message describes a proto message. Messages can be contained in either
@@ -15,6 +19,11 @@ type Message interface {
 	// Descriptor returns the underlying proto descriptor for this message
 	Descriptor() *descriptor.DescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this message,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved.
+	ReflectDescriptor() protoreflect.MessageDescriptor
+
 	// Parent returns either the File or Message that directly contains this
 	// Message.
 	Parent() ParentEntity
@@ -26,15 +35,45 @@ type Message interface {
 	// NonOneOfFields returns all fields not contained within OneOf blocks.
 	NonOneOfFields() []Field
 
-	// OneOfFields returns only the fields contained within OneOf blocks.
+	// OneOfFields returns only the fields contained within OneOf blocks,
+	// including synthetic OneOfs.
 	OneOfFields() []Field
 
-	// OneOfs returns the OneOfs contained within this Message.
+	// SyntheticOneOfFields returns only the fields contained within synthetic
+	// OneOf blocks (ie, proto3 fields labeled with the optional keyword).
+	SyntheticOneOfFields() []Field
+
+	// OneOfs returns the OneOfs contained within this Message, including
+	// synthetic OneOfs.
 	OneOfs() []OneOf
 
+	// RealOneOfs returns the OneOfs contained within this Message, excluding
+	// synthetic OneOfs generated for proto3 optional fields.
+	RealOneOfs() []OneOf
+
 	// Extensions returns all of the Extensions applied to this Message.
 	Extensions() []Extension
 
+	// ReservedRanges returns the field numbers reserved by this Message.
+	ReservedRanges() []Range
+
+	// ReservedNames returns the field names reserved by this Message.
+	ReservedNames() []Name
+
+	// ExtensionRanges returns the field numbers this Message declares as
+	// available for Extensions.
+	ExtensionRanges() []Range
+
+	// IsFieldNumberAvailable returns true if n is a valid field number that is
+	// not used by a Field, reserved, or set aside for Extensions on this
+	// Message.
+	IsFieldNumberAvailable(n int32) bool
+
+	// New returns an empty dynamic message of this type, built from its
+	// ReflectDescriptor. Nil is returned if the descriptors of the AST cannot
+	// be resolved.
+	New() *dynamicpb.Message
+
 	// Dependents returns all of the messages where message is directly or
 	// transitively used.
 	Dependents() []Message
@@ -75,6 +114,11 @@ type msg struct {
 	maps                []Message
 	dependents          []Message
 	dependentsCache     map[string]Message
+	dependentsOnce      sync.Once
+
+	// resolveExtensions defers hydrating the files defining exts in a lazily
+	// built AST
+	resolveExtensions *lazyRef
 
 	info SourceCodeInfo
 }
@@ -95,6 +139,10 @@ func (m *msg) Fields() []Field                         { return m.fields }
 func (m *msg) OneOfs() []OneOf                         { return m.oneofs }
 func (m *msg) MapEntries() []Message                   { return m.maps }
 
+func (m *msg) Features() *descriptor.FeatureSet {
+	return resolveFeatures(m.parent.Features(), m.desc.GetOptions().GetFeatures())
+}
+
 func (m *msg) WellKnownType() WellKnownType {
 	if m.Package().ProtoName() == WellKnownTypePackage {
 		return LookupWKT(m.Name())
@@ -139,6 +187,26 @@ func (m *msg) OneOfFields() (f []Field) {
 	return f
 }
 
+func (m *msg) SyntheticOneOfFields() (f []Field) {
+	for _, o := range m.oneofs {
+		if o.IsSynthetic() {
+			f = append(f, o.Fields()...)
+		}
+	}
+
+	return f
+}
+
+func (m *msg) RealOneOfs() (r []OneOf) {
+	for _, o := range m.oneofs {
+		if !o.IsSynthetic() {
+			r = append(r, o)
+		}
+	}
+
+	return r
+}
+
 func (m *msg) Imports() (i []File) {
 	// Mapping for avoiding duplicate entries
 	mp := make(map[string]File, len(m.fields))
@@ -153,28 +221,25 @@ func (m *msg) Imports() (i []File) {
 	return
 }
 
+// getDependents adds the direct and transitive dependents of m to set. Only
+// the immutable dependents of each message are read, so it is safe to call
+// concurrently and terminates on recursive messages.
 func (m *msg) getDependents(set map[string]Message) {
-	m.populateDependentsCache()
-
-	for fqn, d := range m.dependentsCache {
-		set[fqn] = d
-	}
-}
-
-func (m *msg) populateDependentsCache() {
-	if m.dependentsCache != nil {
-		return
-	}
-
-	m.dependentsCache = map[string]Message{}
 	for _, dep := range m.dependents {
-		m.dependentsCache[dep.FullyQualifiedName()] = dep
-		dep.getDependents(m.dependentsCache)
+		if _, ok := set[dep.FullyQualifiedName()]; ok {
+			continue
+		}
+		set[dep.FullyQualifiedName()] = dep
+		dep.getDependents(set)
 	}
 }
 
 func (m *msg) Dependents() []Message {
-	m.populateDependentsCache()
+	m.dependentsOnce.Do(func() {
+		set := map[string]Message{}
+		m.getDependents(set)
+		m.dependentsCache = set
+	})
 	return messageSetToSlice(m.FullyQualifiedName(), m.dependentsCache)
 }
 
@@ -183,6 +248,7 @@ func (m *msg) Extension(desc *proto.ExtensionDesc, ext interface{}) (bool, error
 }
 
 func (m *msg) Extensions() []Extension {
+	m.resolveExtensions.resolve()
 	return m.exts
 }
 
@@ -190,6 +256,44 @@ func (m *msg) DefinedExtensions() []Extension {
 	return m.defExts
 }
 
+func (m *msg) ReservedRanges() []Range {
+	return messageReservedRanges(m.desc.GetReservedRange())
+}
+
+func (m *msg) ReservedNames() []Name {
+	return reservedNames(m.desc.GetReservedName())
+}
+
+func (m *msg) ExtensionRanges() []Range {
+	return extensionRanges(m.desc.GetExtensionRange())
+}
+
+func (m *msg) IsFieldNumberAvailable(n int32) bool {
+	if !IsValidFieldNumber(n) {
+		return false
+	}
+
+	for _, f := range m.desc.GetField() {
+		if f.GetNumber() == n {
+			return false
+		}
+	}
+
+	return !rangesContain(m.ReservedRanges(), n) && !rangesContain(m.ExtensionRanges(), n)
+}
+
+func (m *msg) New() *dynamicpb.Message {
+	if md := m.ReflectDescriptor(); md != nil {
+		return dynamicpb.NewMessage(md)
+	}
+	return nil
+}
+
+func (m *msg) ReflectDescriptor() protoreflect.MessageDescriptor {
+	d, _ := findDescriptor(m).(protoreflect.MessageDescriptor)
+	return d
+}
+
 func (m *msg) accept(v Visitor) (err error) {
 	if v == nil {
 		return nil
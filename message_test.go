@@ -2,6 +2,7 @@ package pgs
 
 import (
 	"errors"
+	"sync"
 	"testing"
 
 	desc "github.com/golang/protobuf/descriptor"
@@ -211,6 +212,39 @@ func TestMsg_OneOfs(t *testing.T) {
 	assert.Len(t, m.OneOfs(), 1)
 }
 
+func TestMsg_SyntheticOneOfFields(t *testing.T) {
+	t.Parallel()
+
+	m := &msg{}
+	assert.Empty(t, m.SyntheticOneOfFields())
+
+	o := &oneof{}
+	o.addField(&field{})
+	m.addOneOf(o)
+	assert.Empty(t, m.SyntheticOneOfFields())
+
+	so := &oneof{}
+	so.addField(&field{desc: &descriptor.FieldDescriptorProto{Proto3Optional: proto.Bool(true)}})
+	m.addOneOf(so)
+	assert.Len(t, m.SyntheticOneOfFields(), 1)
+}
+
+func TestMsg_RealOneOfs(t *testing.T) {
+	t.Parallel()
+
+	m := &msg{}
+	assert.Empty(t, m.RealOneOfs())
+
+	m.addOneOf(&oneof{})
+	assert.Len(t, m.RealOneOfs(), 1)
+
+	so := &oneof{}
+	so.addField(&field{desc: &descriptor.FieldDescriptorProto{Proto3Optional: proto.Bool(true)}})
+	m.addOneOf(so)
+	assert.Len(t, m.OneOfs(), 2)
+	assert.Len(t, m.RealOneOfs(), 1)
+}
+
 func TestMsg_Extension(t *testing.T) {
 	// cannot be parallel
 	m := &msg{desc: &descriptor.DescriptorProto{}}
@@ -339,6 +373,68 @@ func TestMsg_Imports(t *testing.T) {
 	assert.Len(t, m.Imports(), 2)
 }
 
+func TestMsg_Reserved(t *testing.T) {
+	t.Parallel()
+
+	m := &msg{desc: &descriptor.DescriptorProto{
+		ReservedRange: []*descriptor.DescriptorProto_ReservedRange{
+			{Start: proto.Int32(2), End: proto.Int32(3)},
+			{Start: proto.Int32(10), End: proto.Int32(20)},
+		},
+		ReservedName: []string{"foo", "bar"},
+	}}
+
+	assert.Equal(t, []Range{{2, 2}, {10, 19}}, m.ReservedRanges())
+	assert.Equal(t, []Name{"foo", "bar"}, m.ReservedNames())
+	assert.Empty(t, m.ExtensionRanges())
+
+	m.desc.ExtensionRange = []*descriptor.DescriptorProto_ExtensionRange{
+		{Start: proto.Int32(100), End: proto.Int32(201)},
+	}
+	assert.Equal(t, []Range{{100, 200}}, m.ExtensionRanges())
+}
+
+func TestMsg_IsFieldNumberAvailable(t *testing.T) {
+	t.Parallel()
+
+	m := &msg{desc: &descriptor.DescriptorProto{
+		Field: []*descriptor.FieldDescriptorProto{{Number: proto.Int32(1)}},
+		ReservedRange: []*descriptor.DescriptorProto_ReservedRange{
+			{Start: proto.Int32(5), End: proto.Int32(10)},
+		},
+		ExtensionRange: []*descriptor.DescriptorProto_ExtensionRange{
+			{Start: proto.Int32(100), End: proto.Int32(536870912)},
+		},
+	}}
+
+	tests := []struct {
+		n         int32
+		available bool
+	}{
+		{0, false},
+		{-1, false},
+		{1, false},
+		{2, true},
+		{5, false},
+		{9, false},
+		{10, true},
+		{99, true},
+		{100, false},
+		{536870911, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.available, m.IsFieldNumberAvailable(test.n), test.n)
+	}
+
+	m.desc.ExtensionRange = nil
+	assert.True(t, m.IsFieldNumberAvailable(18999))
+	assert.False(t, m.IsFieldNumberAvailable(19000))
+	assert.False(t, m.IsFieldNumberAvailable(19999))
+	assert.True(t, m.IsFieldNumberAvailable(20000))
+	assert.True(t, m.IsFieldNumberAvailable(536870911))
+}
+
 func TestMsg_Dependents(t *testing.T) {
 	t.Parallel()
 
@@ -362,6 +458,27 @@ func TestMsg_Dependents(t *testing.T) {
 	assert.Contains(t, deps, m2)
 }
 
+func TestMsg_Dependents_Recursive(t *testing.T) {
+	t.Parallel()
+
+	a, b, c := dummyMsg(), dummyMsg(), dummyMsg()
+	a.fqn, b.fqn, c.fqn = ".pkg.A", ".pkg.B", ".pkg.C"
+	a.addDependent(b)
+	b.addDependent(a)
+	b.addDependent(c)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.ElementsMatch(t, []Message{b, c}, a.Dependents())
+			assert.ElementsMatch(t, []Message{a, c}, b.Dependents())
+		}()
+	}
+	wg.Wait()
+}
+
 func TestMsg_ChildAtPath(t *testing.T) {
 	t.Parallel()
 
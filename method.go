@@ -3,6 +3,7 @@ package pgs
 import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // Method describes a method on a proto service
@@ -12,6 +13,11 @@ type Method interface {
 	// Descriptor returns the underlying proto descriptor for this.
 	Descriptor() *descriptor.MethodDescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this method,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved.
+	ReflectDescriptor() protoreflect.MethodDescriptor
+
 	// Service returns the parent service for this.
 	Service() Service
 
@@ -38,6 +44,9 @@ type method struct {
 	in, out Message
 	options string
 	info    SourceCodeInfo
+
+	// resolveIO defers hydrating in and out in a lazily built AST
+	resolveIO *lazyRef
 }
 
 func (m *method) Name() Name                                    { return Name(m.desc.GetName()) }
@@ -49,12 +58,24 @@ func (m *method) BuildTarget() bool                             { return m.servi
 func (m *method) SourceCodeInfo() SourceCodeInfo                { return m.info }
 func (m *method) Descriptor() *descriptor.MethodDescriptorProto { return m.desc }
 func (m *method) Service() Service                              { return m.service }
-func (m *method) Input() Message                                { return m.in }
-func (m *method) Output() Message                               { return m.out }
 func (m *method) ClientStreaming() bool                         { return m.desc.GetClientStreaming() }
 func (m *method) ServerStreaming() bool                         { return m.desc.GetServerStreaming() }
 func (m *method) BiDirStreaming() bool                          { return m.ClientStreaming() && m.ServerStreaming() }
 
+func (m *method) Input() Message {
+	m.resolveIO.resolve()
+	return m.in
+}
+
+func (m *method) Output() Message {
+	m.resolveIO.resolve()
+	return m.out
+}
+
+func (m *method) Features() *descriptor.FeatureSet {
+	return resolveFeatures(m.service.Features(), m.desc.GetOptions().GetFeatures())
+}
+
 func (m *method) Imports() (i []File) {
 	mine := m.File().Name()
 	input := m.Input().File()
@@ -73,6 +94,11 @@ func (m *method) Extension(desc *proto.ExtensionDesc, ext interface{}) (ok bool,
 	return extension(m.desc.GetOptions(), desc, &ext)
 }
 
+func (m *method) ReflectDescriptor() protoreflect.MethodDescriptor {
+	d, _ := findDescriptor(m).(protoreflect.MethodDescriptor)
+	return d
+}
+
 func (m *method) accept(v Visitor) (err error) {
 	if v == nil {
 		return
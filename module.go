@@ -19,6 +19,86 @@ type Module interface {
 	Execute(targets map[string]File, packages map[string]Package) []Artifact
 }
 
+// ConcurrentModule describes a Module that may be executed concurrently with
+// other ConcurrentModules when the Generator is initialized with the
+// ParallelModules InitOption. A ConcurrentModule must not depend on the side
+// effects of other Modules and must not mutate the AST.
+type ConcurrentModule interface {
+	Module
+
+	// Concurrent returns true if the Module is safe to execute concurrently
+	// with other Modules.
+	Concurrent() bool
+}
+
+// PerFileModule describes a Module whose Artifacts for a target File depend
+// only on that File and its TransitiveImports. When the Generator is
+// initialized with the Cache InitOption, a PerFileModule is executed once per
+// target File, receiving only that File in its targets, and its Artifacts are
+// reused while the File, its imports, the parameters and the Module's version
+// are unchanged. See VersionedModule.
+// Otherwise, the Module is executed as usual.
+type PerFileModule interface {
+	Module
+
+	// PerFile returns true if the Module's output for each target File is
+	// independent of the other targets.
+	PerFile() bool
+}
+
+// VersionedModule describes a Module that reports the version of its own
+// code and templates. When the Generator is initialized with the Cache
+// InitOption, the version is part of the cache key of a PerFileModule, so
+// changing it invalidates previously cached Artifacts. Modules that do not
+// implement this interface are keyed by a hash of the plugin executable
+// instead.
+type VersionedModule interface {
+	Module
+
+	// Version returns an identifier that changes whenever the Module's output
+	// for the same input may change (eg, a release version or template hash).
+	Version() string
+}
+
+// DependentModule describes a Module that must be executed after the Modules
+// it depends on. Before its Execute method is called, the Module receives the
+// output of each of its dependencies via InitDependencies. The Generator
+// executes Modules in dependency order, failing if a dependency is missing or
+// a cycle exists, though their Artifacts are returned in the order the Modules
+// were registered. ModuleBase implements InitDependencies, so Modules embedding
+// it only need to implement DependsOn.
+type DependentModule interface {
+	Module
+
+	// DependsOn returns the names of the Modules that must be executed before
+	// this Module.
+	DependsOn() []string
+
+	// InitDependencies is called prior to Execute with the output of each
+	// Module named by DependsOn, keyed by their names.
+	InitDependencies(deps map[string]ModuleOutput)
+}
+
+// A Publisher is a Module that exposes arbitrary data to the Modules depending
+// on it. ModuleBase implements Publisher via its Publish method.
+type Publisher interface {
+	// Published returns the data made available to dependent Modules after
+	// Execute is called.
+	Published() interface{}
+}
+
+// ModuleOutput is the result of executing a Module, provided to each
+// DependentModule that depends on it.
+type ModuleOutput struct {
+	// Artifacts are the Artifacts returned by the Module's Execute method.
+	// These Artifacts will still be persisted; a dependent Module should not
+	// return them again.
+	Artifacts []Artifact
+
+	// Data is the value published by the Module if it implements Publisher.
+	Data interface{}
+}
+
 // ModuleBase provides utility methods and a base implementation for a
 // protoc-gen-star Module. ModuleBase should be used as an anonymously embedded
 // field of an actual Module implementation. The only methods that need to be
@@ -37,6 +117,8 @@ type Module interface {
 type ModuleBase struct {
 	BuildContext
 	artifacts []Artifact
+	published interface{}
+	deps      map[string]ModuleOutput
 }
 
 // InitContext populates this Module with the BuildContext from the parent
@@ -89,6 +171,26 @@ func (m *ModuleBase) PopDir() BuildContext {
 	return m
 }
 
+// InitDependencies stores the output of the Module's dependencies, making
+// them available via Dependency. This method is called prior to Execute for
+// registered modules that also implement DependentModule.
+func (m *ModuleBase) InitDependencies(deps map[string]ModuleOutput) { m.deps = deps }
+
+// Dependency returns the output of the dependency with the provided name. If
+// the Module does not depend on a Module with that name, false is returned.
+func (m *ModuleBase) Dependency(name string) (out ModuleOutput, ok bool) {
+	out, ok = m.deps[name]
+	return
+}
+
+// Publish makes v available to Modules that depend on this one. Subsequent
+// calls replace the previously published value.
+func (m *ModuleBase) Publish(v interface{}) { m.published = v }
+
+// Published returns the value provided to Publish, satisfying the Publisher
+// interface.
+func (m *ModuleBase) Published() interface{} { return m.published }
+
 // Artifacts returns the slice of generation artifacts that have been captured
 // by the Module. This method should/can be the return value of its Execute
 // method. Subsequent calls will return a nil slice until more artifacts are
@@ -152,6 +254,51 @@ func (m *ModuleBase) OverwriteGeneratorTemplateFile(name string, tpl Template, d
 	})
 }
 
+// AddGeneratorBinaryFile behaves the same as AddGeneratorFile, however the
+// content may contain arbitrary bytes.
+func (m *ModuleBase) AddGeneratorBinaryFile(name string, content []byte) {
+	m.AddArtifact(GeneratorBinaryFile{
+		Name:     name,
+		Contents: content,
+	})
+}
+
+// OverwriteGeneratorBinaryFile behaves the same as OverwriteGeneratorFile,
+// however the content may contain arbitrary bytes.
+func (m *ModuleBase) OverwriteGeneratorBinaryFile(name string, content []byte) {
+	m.AddArtifact(GeneratorBinaryFile{
+		Name:      name,
+		Contents:  content,
+		Overwrite: true,
+	})
+}
+
+// AddGeneratorBinaryTemplateFile behaves the same as AddGeneratorTemplateFile,
+// however the rendered content may contain arbitrary bytes.
+func (m *ModuleBase) AddGeneratorBinaryTemplateFile(name string, tpl Template, data interface{}) {
+	m.AddArtifact(GeneratorBinaryTemplateFile{
+		Name: name,
+		TemplateArtifact: TemplateArtifact{
+			Template: tpl,
+			Data:     data,
+		},
+	})
+}
+
+// OverwriteGeneratorBinaryTemplateFile behaves the same as
+// OverwriteGeneratorTemplateFile, however the rendered content may contain
+// arbitrary bytes.
+func (m *ModuleBase) OverwriteGeneratorBinaryTemplateFile(name string, tpl Template, data interface{}) {
+	m.AddArtifact(GeneratorBinaryTemplateFile{
+		Name:      name,
+		Overwrite: true,
+		TemplateArtifact: TemplateArtifact{
+			Template: tpl,
+			Data:     data,
+		},
+	})
+}
+
 // AddGeneratorAppend attempts to append content to the specified file name.
 // Name must be a path relative to and within the protoc-plugin's output
 // destination, which may differ from the BuildContext's OutputPath value. If
@@ -116,6 +116,36 @@ func TestModuleBase_OverwriteGeneratorFile(t *testing.T) {
 	}, arts[0])
 }
 
+func TestModuleBase_AddGeneratorBinaryFile(t *testing.T) {
+	t.Parallel()
+
+	m := new(ModuleBase)
+	m.AddGeneratorBinaryFile("foo", []byte{0xff})
+	m.OverwriteGeneratorBinaryFile("bar", []byte{0xfe})
+	m.AddGeneratorBinaryTemplateFile("baz", template.New("fizz"), "buzz")
+	m.OverwriteGeneratorBinaryTemplateFile("qux", template.New("fizz"), "buzz")
+
+	assert.Equal(t, []Artifact{
+		GeneratorBinaryFile{Name: "foo", Contents: []byte{0xff}},
+		GeneratorBinaryFile{Name: "bar", Contents: []byte{0xfe}, Overwrite: true},
+		GeneratorBinaryTemplateFile{
+			Name: "baz",
+			TemplateArtifact: TemplateArtifact{
+				Template: template.New("fizz"),
+				Data:     "buzz",
+			},
+		},
+		GeneratorBinaryTemplateFile{
+			Name:      "qux",
+			Overwrite: true,
+			TemplateArtifact: TemplateArtifact{
+				Template: template.New("fizz"),
+				Data:     "buzz",
+			},
+		},
+	}, m.Artifacts())
+}
+
 func TestModuleBase_AddGeneratorTemplateFile(t *testing.T) {
 	t.Parallel()
 
@@ -282,3 +312,28 @@ func TestModuleBase_AddError(t *testing.T) {
 	assert.Len(t, arts, 1)
 	assert.Equal(t, GeneratorError{Message: "bohoo"}, arts[0])
 }
+
+func TestModuleBase_Dependency(t *testing.T) {
+	t.Parallel()
+
+	m := &ModuleBase{}
+	_, ok := m.Dependency("foo")
+	assert.False(t, ok)
+
+	out := ModuleOutput{Artifacts: []Artifact{CustomFile{Name: "bar"}}, Data: 123}
+	m.InitDependencies(map[string]ModuleOutput{"foo": out})
+
+	dep, ok := m.Dependency("foo")
+	assert.True(t, ok)
+	assert.Equal(t, out, dep)
+}
+
+func TestModuleBase_Publish(t *testing.T) {
+	t.Parallel()
+
+	m := &ModuleBase{}
+	assert.Nil(t, m.Published())
+
+	m.Publish("foo")
+	assert.Equal(t, "foo", m.Published())
+}
@@ -0,0 +1,854 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Mutator applies validated changes to the entities of an AST. Each change
+// updates the underlying descriptors, assigns parents and fully-qualified
+// names, and registers (or unregisters) the affected entities for
+// AST.Lookup. A change that would produce an invalid AST, such as a duplicate
+// name or field number or an unresolvable type, returns an error and leaves
+// the AST unmodified.
+//
+// Type names in added descriptors may be fully-qualified (eg, `.foo.Bar`) or
+// relative to the scope of the new entity, following protoc's scoping rules.
+// They are replaced with their fully-qualified form.
+//
+// Mutations do not update the Dependents of messages and enums. A Mutator is
+// not safe for concurrent use, nor may the AST be read during a mutation.
+type Mutator struct {
+	g *graph
+}
+
+// NewMutator creates a Mutator for ast, which must have been created by one of
+// the Process* functions or a Generator. An AST built lazily is fully hydrated
+// first.
+func NewMutator(ast AST) (*Mutator, error) {
+	g, ok := ast.(*graph)
+	if !ok {
+		return nil, fmt.Errorf("unsupported AST implementation: %T", ast)
+	}
+	g.hydrateAll()
+	return &Mutator{g: g}, nil
+}
+
+// AddMessage adds the message described by md, including any nested messages,
+// enums, oneofs, and fields, to parent. Nested extensions are not supported.
+func (mu *Mutator) AddMessage(parent ParentEntity, md *descriptor.DescriptorProto) (Message, error) {
+	if err := mu.owned(parent); err != nil {
+		return nil, err
+	}
+
+	fqn := parent.FullyQualifiedName() + "." + md.GetName()
+	if err := mu.validateMessage(fqn, md, make(map[string]struct{})); err != nil {
+		return nil, err
+	}
+
+	m := mu.g.hydrateMessage(parent, md).(*msg)
+	if err := mu.resolveMessageTypes(m); err != nil {
+		mu.unregister(m)
+		return nil, err
+	}
+	mu.hydrateMessageTypes(m)
+
+	switch p := parent.(type) {
+	case *file:
+		p.desc.MessageType = append(p.desc.MessageType, md)
+	case *msg:
+		p.desc.NestedType = append(p.desc.NestedType, md)
+		p.preservedMsgs = append(p.preservedMsgs, m)
+	}
+	parent.addMessage(m)
+
+	return m, nil
+}
+
+// RemoveMessage removes m and all entities nested within it. An error is
+// returned if any field, extension, or method outside of m references m or
+// its nested types. Map entries are removed along with their map field by
+// RemoveField, but may also be removed here once no field references them.
+func (mu *Mutator) RemoveMessage(m Message) error {
+	if err := mu.owned(m); err != nil {
+		return err
+	}
+
+	if ref := mu.referrer(m.FullyQualifiedName()); ref != nil {
+		return fmt.Errorf("%s: referenced by %s", m.FullyQualifiedName(), ref.FullyQualifiedName())
+	}
+
+	mu.removeMessage(m.(*msg))
+
+	return nil
+}
+
+// removeMessage unregisters m and drops it from the descriptor and entities
+// of its parent.
+func (mu *Mutator) removeMessage(m *msg) {
+	path := descriptorPath(m)
+	i := path[len(path)-1]
+	mu.unregister(m)
+
+	switch p := m.Parent().(type) {
+	case *file:
+		p.desc.MessageType = append(p.desc.MessageType[:i:i], p.desc.MessageType[i+1:]...)
+		p.msgs = removeMessage(p.msgs, m)
+	case *msg:
+		p.desc.NestedType = append(p.desc.NestedType[:i:i], p.desc.NestedType[i+1:]...)
+		p.msgs = removeMessage(p.msgs, m)
+		p.maps = removeMessage(p.maps, m)
+		p.preservedMsgs = removeMessage(p.preservedMsgs, m)
+	}
+	removeSourceLocations(m.File().Descriptor(), path)
+}
+
+// AddField adds the field described by fd to m. If fd is part of a OneOf, its
+// OneofIndex must refer to an existing OneOf of m.
+func (mu *Mutator) AddField(m Message, fd *descriptor.FieldDescriptorProto) (Field, error) {
+	if err := mu.owned(m); err != nil {
+		return nil, err
+	}
+	mm := m.(*msg)
+
+	fqn := m.FullyQualifiedName() + "." + fd.GetName()
+	if err := mu.validateField(fqn, mm.desc, fd, make(map[string]struct{})); err != nil {
+		return nil, err
+	}
+
+	if err := mu.resolveFieldType(m.File(), m.FullyQualifiedName(), fd); err != nil {
+		return nil, err
+	}
+
+	f := mu.g.hydrateField(mm, fd)
+	f.addType(mu.g.hydrateFieldType(f))
+
+	mm.desc.Field = append(mm.desc.Field, fd)
+	mm.addField(f)
+	if fd.OneofIndex != nil {
+		mm.oneofs[fd.GetOneofIndex()].addField(f)
+	}
+
+	return f, nil
+}
+
+// RemoveField removes f from its Message. The MapEntry of a map field is
+// removed with it, as is a OneOf left without fields, such as the synthetic
+// OneOf of a proto3 optional field. Extensions cannot be removed.
+func (mu *Mutator) RemoveField(f Field) error {
+	if err := mu.owned(f); err != nil {
+		return err
+	}
+
+	fld, ok := f.(*field)
+	if !ok {
+		return fmt.Errorf("%s: extensions cannot be removed", f.FullyQualifiedName())
+	}
+
+	mm := fld.msg.(*msg)
+	if mm.IsMapEntry() {
+		return fmt.Errorf("%s: map entry fields cannot be removed", f.FullyQualifiedName())
+	}
+
+	path := descriptorPath(f)
+	mu.unregister(f)
+
+	i := path[len(path)-1]
+	mm.desc.Field = append(mm.desc.Field[:i:i], mm.desc.Field[i+1:]...)
+	mm.fields = removeField(mm.fields, f)
+	removeSourceLocations(mm.File().Descriptor(), path)
+
+	if entry, ok := mu.g.entities[fld.desc.GetTypeName()].(*msg); ok && entry.IsMapEntry() && entry.parent == mm {
+		mu.removeMessage(entry)
+	}
+
+	if o, ok := fld.oneof.(*oneof); ok {
+		if o.flds = removeField(o.flds, f); len(o.flds) == 0 {
+			mu.removeOneOf(o)
+		}
+	}
+
+	return nil
+}
+
+// removeOneOf unregisters o and drops it from the descriptor and entities of
+// its Message, shifting the OneofIndex of the fields in subsequent OneOfs.
+func (mu *Mutator) removeOneOf(o *oneof) {
+	mm := o.msg.(*msg)
+
+	path := descriptorPath(o)
+	i := path[len(path)-1]
+	mu.unregister(o)
+
+	mm.desc.OneofDecl = append(mm.desc.OneofDecl[:i:i], mm.desc.OneofDecl[i+1:]...)
+	mm.oneofs = append(mm.oneofs[:i:i], mm.oneofs[i+1:]...)
+	for _, fd := range mm.desc.Field {
+		if fd.OneofIndex != nil && fd.GetOneofIndex() > i {
+			fd.OneofIndex = proto.Int32(fd.GetOneofIndex() - 1)
+		}
+	}
+	removeSourceLocations(mm.File().Descriptor(), path)
+}
+
+// AddService adds the service described by sd, including its methods, to f.
+func (mu *Mutator) AddService(f File, sd *descriptor.ServiceDescriptorProto) (Service, error) {
+	if err := mu.owned(f); err != nil {
+		return nil, err
+	}
+
+	fqn := f.FullyQualifiedName() + "." + sd.GetName()
+	seen := make(map[string]struct{})
+	if err := mu.validateName(fqn, seen); err != nil {
+		return nil, err
+	}
+
+	for _, md := range sd.GetMethod() {
+		if err := mu.validateMethod(f, fqn, md, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	s := mu.g.hydrateService(f, sd)
+
+	fl := f.(*file)
+	fl.desc.Service = append(fl.desc.Service, sd)
+	fl.addService(s)
+
+	return s, nil
+}
+
+// RemoveService removes s and its methods from its File.
+func (mu *Mutator) RemoveService(s Service) error {
+	if err := mu.owned(s); err != nil {
+		return err
+	}
+
+	path := descriptorPath(s)
+	mu.unregister(s)
+
+	fl := s.File().(*file)
+	i := path[len(path)-1]
+	fl.desc.Service = append(fl.desc.Service[:i:i], fl.desc.Service[i+1:]...)
+	fl.srvs = append(fl.srvs[:i:i], fl.srvs[i+1:]...)
+	removeSourceLocations(fl.desc, path)
+
+	return nil
+}
+
+// AddMethod adds the method described by md to s. The input and output types
+// must resolve to Messages.
+func (mu *Mutator) AddMethod(s Service, md *descriptor.MethodDescriptorProto) (Method, error) {
+	if err := mu.owned(s); err != nil {
+		return nil, err
+	}
+
+	if err := mu.validateMethod(s.File(), s.FullyQualifiedName(), md, make(map[string]struct{})); err != nil {
+		return nil, err
+	}
+
+	m := mu.g.hydrateMethod(s, md)
+
+	srv := s.(*service)
+	srv.desc.Method = append(srv.desc.Method, md)
+	srv.addMethod(m)
+
+	return m, nil
+}
+
+// RemoveMethod removes m from its Service.
+func (mu *Mutator) RemoveMethod(m Method) error {
+	if err := mu.owned(m); err != nil {
+		return err
+	}
+
+	path := descriptorPath(m)
+	mu.unregister(m)
+
+	srv := m.Service().(*service)
+	i := path[len(path)-1]
+	srv.desc.Method = append(srv.desc.Method[:i:i], srv.desc.Method[i+1:]...)
+	srv.methods = append(srv.methods[:i:i], srv.methods[i+1:]...)
+	removeSourceLocations(srv.File().Descriptor(), path)
+
+	return nil
+}
+
+// MergeOptions merges the options in text, using the protobuf text format,
+// into the options of e. For example: `deprecated: true` or
+// `[google.api.http]: { post: "/foo" body: "*" }`. Extensions must be linked
+// into the plugin binary.
+func (mu *Mutator) MergeOptions(e Entity, text string) error {
+	return mu.updateOptions(e, func(opts protoreflect.Message) error {
+		src := opts.New()
+		if err := prototext.Unmarshal([]byte(text), src.Interface()); err != nil {
+			return fmt.Errorf("%s: invalid options: %v", e.FullyQualifiedName(), err)
+		}
+		proto.Merge(proto.MessageV1(opts.Interface()), proto.MessageV1(src.Interface()))
+		return nil
+	})
+}
+
+// SetExtension sets the extension described by desc to v on the options of
+// e. An error is returned if desc does not extend the options of e or v is
+// not of the extension's type.
+func (mu *Mutator) SetExtension(e Entity, desc *proto.ExtensionDesc, v interface{}) error {
+	return mu.updateOptions(e, func(opts protoreflect.Message) error {
+		return proto.SetExtension(proto.MessageV1(opts.Interface()), desc, v)
+	})
+}
+
+// ClearOption removes the option with name from e. Names take the form
+// printed by ProtoPrinter: a field of the options message (eg, `deprecated`)
+// or the fully-qualified name of an extension in parentheses (eg,
+// `(google.api.http)`). Clearing an option that is not set is a no-op.
+func (mu *Mutator) ClearOption(e Entity, name string) error {
+	return mu.updateOptions(e, func(opts protoreflect.Message) error {
+		var fd protoreflect.FieldDescriptor
+
+		if strings.HasPrefix(name, "(") && strings.HasSuffix(name, ")") {
+			xt, err := protoregistry.GlobalTypes.FindExtensionByName(protoreflect.FullName(name[1 : len(name)-1]))
+			if err != nil {
+				return fmt.Errorf("%s: unknown option %s: %v", e.FullyQualifiedName(), name, err)
+			}
+			fd = xt.TypeDescriptor()
+		} else {
+			fd = opts.Descriptor().Fields().ByName(protoreflect.Name(name))
+		}
+
+		if fd == nil || fd.ContainingMessage().FullName() != opts.Descriptor().FullName() {
+			return fmt.Errorf("%s: unknown option %s", e.FullyQualifiedName(), name)
+		}
+
+		opts.Clear(fd)
+		return nil
+	})
+}
+
+// updateOptions applies fn to a copy of the options of e, replacing the
+// options only if fn succeeds.
+func (mu *Mutator) updateOptions(e Entity, fn func(opts protoreflect.Message) error) error {
+	if err := mu.owned(e); err != nil {
+		return err
+	}
+
+	desc := entityDescriptor(e)
+	if desc == nil {
+		return fmt.Errorf("%s: entity does not support options", e.FullyQualifiedName())
+	}
+
+	d := proto.MessageReflect(desc)
+	fd := d.Descriptor().Fields().ByName("options")
+
+	opts := d.NewField(fd).Message()
+	if d.Has(fd) {
+		proto.Merge(proto.MessageV1(opts.Interface()), proto.MessageV1(d.Get(fd).Message().Interface()))
+	}
+
+	if err := fn(opts); err != nil {
+		return err
+	}
+
+	d.Set(fd, protoreflect.ValueOfMessage(opts))
+	return nil
+}
+
+func entityDescriptor(e Entity) proto.Message {
+	switch e := e.(type) {
+	case File:
+		return e.Descriptor()
+	case Message:
+		return e.Descriptor()
+	case Field:
+		return e.Descriptor()
+	case OneOf:
+		return e.Descriptor()
+	case Enum:
+		return e.Descriptor()
+	case EnumValue:
+		return e.Descriptor()
+	case Service:
+		return e.Descriptor()
+	case Method:
+		return e.Descriptor()
+	default:
+		return nil
+	}
+}
+
+// owned returns an error if e is not an entity of the Mutator's AST.
+func (mu *Mutator) owned(e Entity) error {
+	if e == nil {
+		return errors.New("entity is nil")
+	}
+
+	fqn := mu.g.resolveFQN(e)
+	if existing, ok := mu.g.entities[fqn]; !ok || existing != e {
+		return fmt.Errorf("%s: entity is not part of the AST", fqn)
+	}
+
+	// every change is validated here first, so the protoreflect descriptors are
+	// invalidated ahead of it
+	mu.g.reg.reset()
+
+	return nil
+}
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateName returns an error if the last component of fqn is not a valid
+// identifier, or fqn is already in use by the AST or seen.
+func (mu *Mutator) validateName(fqn string, seen map[string]struct{}) error {
+	if name := fqn[strings.LastIndex(fqn, ".")+1:]; !identPattern.MatchString(name) {
+		return fmt.Errorf("%s: invalid name %q", fqn, name)
+	}
+
+	if _, ok := mu.g.entities[fqn]; ok {
+		return fmt.Errorf("%s: name is already defined", fqn)
+	}
+
+	if _, ok := seen[fqn]; ok {
+		return fmt.Errorf("%s: name is already defined", fqn)
+	}
+	seen[fqn] = struct{}{}
+
+	return nil
+}
+
+func (mu *Mutator) validateMessage(fqn string, md *descriptor.DescriptorProto, seen map[string]struct{}) error {
+	if err := mu.validateName(fqn, seen); err != nil {
+		return err
+	}
+
+	if len(md.GetExtension()) > 0 {
+		return fmt.Errorf("%s: nested extensions are not supported", fqn)
+	}
+
+	for _, nested := range md.GetNestedType() {
+		if err := mu.validateMessage(fqn+"."+nested.GetName(), nested, seen); err != nil {
+			return err
+		}
+	}
+
+	for _, ed := range md.GetEnumType() {
+		efqn := fqn + "." + ed.GetName()
+		if err := mu.validateName(efqn, seen); err != nil {
+			return err
+		}
+
+		for _, vd := range ed.GetValue() {
+			if err := mu.validateName(efqn+"."+vd.GetName(), seen); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, od := range md.GetOneofDecl() {
+		if err := mu.validateName(fqn+"."+od.GetName(), seen); err != nil {
+			return err
+		}
+	}
+
+	for i, fd := range md.GetField() {
+		// validate against the preceding fields only, to not match fd itself
+		prior := &descriptor.DescriptorProto{
+			Field:          md.GetField()[:i],
+			OneofDecl:      md.GetOneofDecl(),
+			ReservedRange:  md.GetReservedRange(),
+			ReservedName:   md.GetReservedName(),
+			ExtensionRange: md.GetExtensionRange(),
+		}
+
+		if err := mu.validateField(fqn+"."+fd.GetName(), prior, fd, seen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateField returns an error if fd cannot be added to the message md.
+func (mu *Mutator) validateField(fqn string, md *descriptor.DescriptorProto, fd *descriptor.FieldDescriptorProto, seen map[string]struct{}) error {
+	if err := mu.validateName(fqn, seen); err != nil {
+		return err
+	}
+
+	n := fd.GetNumber()
+	switch {
+	case n < 1 || n > maxFieldNumber:
+		return fmt.Errorf("%s: field number %d is out of range", fqn, n)
+	case n >= 19000 && n <= 19999:
+		return fmt.Errorf("%s: field number %d is reserved for the protobuf implementation", fqn, n)
+	}
+
+	for _, other := range md.GetField() {
+		if other.GetNumber() == n {
+			return fmt.Errorf("%s: field number %d is already used by %s", fqn, n, other.GetName())
+		}
+	}
+
+	for _, r := range md.GetReservedRange() {
+		if n >= r.GetStart() && n < r.GetEnd() {
+			return fmt.Errorf("%s: field number %d is reserved", fqn, n)
+		}
+	}
+
+	for _, r := range md.GetExtensionRange() {
+		if n >= r.GetStart() && n < r.GetEnd() {
+			return fmt.Errorf("%s: field number %d is within an extension range", fqn, n)
+		}
+	}
+
+	for _, name := range md.GetReservedName() {
+		if name == fd.GetName() {
+			return fmt.Errorf("%s: field name is reserved", fqn)
+		}
+	}
+
+	if fd.OneofIndex != nil && (fd.GetOneofIndex() < 0 || int(fd.GetOneofIndex()) >= len(md.GetOneofDecl())) {
+		return fmt.Errorf("%s: oneof index %d is out of range", fqn, fd.GetOneofIndex())
+	}
+
+	switch {
+	case fd.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return fmt.Errorf("%s: group fields are not supported", fqn)
+	case fd.Type == nil && fd.TypeName == nil:
+		return fmt.Errorf("%s: field type is not set", fqn)
+	}
+
+	return nil
+}
+
+func (mu *Mutator) validateMethod(fl File, scope string, md *descriptor.MethodDescriptorProto, seen map[string]struct{}) error {
+	fqn := scope + "." + md.GetName()
+	if err := mu.validateName(fqn, seen); err != nil {
+		return err
+	}
+
+	in, err := mu.resolveMessage(fl, scope, md.GetInputType())
+	if err != nil {
+		return fmt.Errorf("%s: input %v", fqn, err)
+	}
+
+	out, err := mu.resolveMessage(fl, scope, md.GetOutputType())
+	if err != nil {
+		return fmt.Errorf("%s: output %v", fqn, err)
+	}
+
+	md.InputType = proto.String(in)
+	md.OutputType = proto.String(out)
+
+	return nil
+}
+
+func (mu *Mutator) resolveMessage(fl File, scope, name string) (string, error) {
+	e, fqn := mu.resolve(scope, name)
+	if _, ok := e.(Message); !ok {
+		return "", fmt.Errorf("type %q is not a message", name)
+	}
+	if !visible(fl, e.File()) {
+		return "", fmt.Errorf("type %q is defined in %s, which is not imported by %s", name, e.File().Name(), fl.Name())
+	}
+	return fqn, nil
+}
+
+// visible returns true if the entities of def may be referenced from fl: def is
+// fl itself, one of its direct imports, or publicly imported by one of them.
+func visible(fl, def File) bool {
+	if fl == def {
+		return true
+	}
+
+	for _, imp := range fl.(*file).imports() {
+		if exports(imp, def) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exports returns true if def is fl or is transitively publicly imported by
+// fl.
+func exports(fl, def File) bool {
+	if fl == def {
+		return true
+	}
+
+	deps := fl.Descriptor().GetDependency()
+	for _, i := range fl.Descriptor().GetPublicDependency() {
+		for _, imp := range fl.(*file).imports() {
+			if imp.Name().String() == deps[i] && exports(imp, def) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// resolve looks up the type name relative to scope, searching each enclosing
+// scope in turn as protoc does.
+func (mu *Mutator) resolve(scope, name string) (Entity, string) {
+	if strings.HasPrefix(name, ".") {
+		e := mu.g.entities[name]
+		return e, name
+	}
+
+	for {
+		fqn := scope + "." + name
+		if e, ok := mu.g.entities[fqn]; ok {
+			return e, fqn
+		}
+
+		if scope == "" {
+			return nil, ""
+		}
+
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// resolveFieldType qualifies the type name of fd, also inferring its type if
+// not set. The type must be defined in fl or a file it imports.
+func (mu *Mutator) resolveFieldType(fl File, scope string, fd *descriptor.FieldDescriptorProto) error {
+	if fd.TypeName == nil {
+		return nil
+	}
+
+	e, fqn := mu.resolve(scope, fd.GetTypeName())
+
+	var typ descriptor.FieldDescriptorProto_Type
+	switch e.(type) {
+	case Message:
+		typ = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+	case Enum:
+		typ = descriptor.FieldDescriptorProto_TYPE_ENUM
+	default:
+		return fmt.Errorf("%s.%s: unknown type %q", scope, fd.GetName(), fd.GetTypeName())
+	}
+
+	if !visible(fl, e.File()) {
+		return fmt.Errorf("%s.%s: type %q is defined in %s, which is not imported by %s",
+			scope, fd.GetName(), fd.GetTypeName(), e.File().Name(), fl.Name())
+	}
+
+	if fd.Type != nil && fd.GetType() != typ {
+		return fmt.Errorf("%s.%s: type %q is not a %s", scope, fd.GetName(), fd.GetTypeName(), fd.GetType())
+	}
+
+	fd.Type = typ.Enum()
+	fd.TypeName = proto.String(fqn)
+
+	return nil
+}
+
+func (mu *Mutator) resolveMessageTypes(m Message) error {
+	for _, f := range m.Fields() {
+		if err := mu.resolveFieldType(m.File(), m.FullyQualifiedName(), f.Descriptor()); err != nil {
+			return err
+		}
+	}
+
+	for _, nested := range append(m.MapEntries(), m.Messages()...) {
+		if err := mu.resolveMessageTypes(nested); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mu *Mutator) hydrateMessageTypes(m Message) {
+	for _, me := range m.MapEntries() {
+		mu.hydrateMessageTypes(me)
+	}
+
+	for _, nested := range m.Messages() {
+		mu.hydrateMessageTypes(nested)
+	}
+
+	for _, f := range m.Fields() {
+		f.addType(mu.g.hydrateFieldType(f))
+	}
+}
+
+// referrer returns an entity outside of the entity with fqn that references
+// it or any entity nested within it. If there are multiple, the referrer with
+// the lowest sorting name is returned.
+func (mu *Mutator) referrer(fqn string) (ref Entity) {
+	within := func(name string) bool {
+		return name == fqn || strings.HasPrefix(name, fqn+".")
+	}
+
+	for name, e := range mu.g.entities {
+		if within(name) || (ref != nil && name > ref.FullyQualifiedName()) {
+			continue
+		}
+
+		switch e := e.(type) {
+		case Field:
+			if within(e.Descriptor().GetTypeName()) || within(e.Descriptor().GetExtendee()) {
+				ref = e
+			}
+		case Method:
+			if within(e.Descriptor().GetInputType()) || within(e.Descriptor().GetOutputType()) {
+				ref = e
+			}
+		}
+	}
+
+	return ref
+}
+
+// unregister removes e and all entities nested within it from the AST.
+func (mu *Mutator) unregister(e Entity) {
+	delete(mu.g.entities, mu.g.resolveFQN(e))
+
+	switch e := e.(type) {
+	case *msg:
+		for _, child := range e.enums {
+			mu.unregister(child)
+		}
+		for _, child := range append(e.maps, e.msgs...) {
+			mu.unregister(child)
+		}
+		for _, child := range e.fields {
+			mu.unregister(child)
+		}
+		for _, child := range e.oneofs {
+			mu.unregister(child)
+		}
+		for _, child := range e.defExts {
+			mu.unregister(child)
+		}
+	case *enum:
+		for _, child := range e.vals {
+			mu.unregister(child)
+		}
+	case *service:
+		for _, child := range e.methods {
+			mu.unregister(child)
+		}
+	case *ext:
+		for i, x := range mu.g.extensions {
+			if x == e {
+				mu.g.extensions = append(mu.g.extensions[:i:i], mu.g.extensions[i+1:]...)
+				break
+			}
+		}
+		if extendee, ok := e.extendee.(*msg); ok {
+			for i, x := range extendee.exts {
+				if x == e {
+					extendee.exts = append(extendee.exts[:i:i], extendee.exts[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+}
+
+// descriptorPath returns the SourceCodeInfo path of e within its File.
+func descriptorPath(e Entity) []int32 {
+	switch e := e.(type) {
+	case *msg:
+		switch p := e.parent.(type) {
+		case *file:
+			return []int32{messageTypePath, indexOf(len(p.desc.MessageType), func(i int) bool { return p.desc.MessageType[i] == e.desc })}
+		case *msg:
+			return append(descriptorPath(p), messageTypeNestedTypePath, indexOf(len(p.desc.NestedType), func(i int) bool { return p.desc.NestedType[i] == e.desc }))
+		}
+	case *field:
+		m := e.msg.(*msg)
+		return append(descriptorPath(m), messageTypeFieldPath, indexOf(len(m.desc.Field), func(i int) bool { return m.desc.Field[i] == e.desc }))
+	case *oneof:
+		m := e.msg.(*msg)
+		return append(descriptorPath(m), messageTypeOneofDeclPath, indexOf(len(m.desc.OneofDecl), func(i int) bool { return m.desc.OneofDecl[i] == e.desc }))
+	case *service:
+		srvs := e.file.Descriptor().GetService()
+		return []int32{servicePath, indexOf(len(srvs), func(i int) bool { return srvs[i] == e.desc })}
+	case *method:
+		s := e.service.(*service)
+		return append(descriptorPath(s), serviceTypeMethodPath, indexOf(len(s.desc.Method), func(i int) bool { return s.desc.Method[i] == e.desc }))
+	}
+	return nil
+}
+
+func indexOf(n int, match func(i int) bool) int32 {
+	for i := 0; i < n; i++ {
+		if match(i) {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
+func removeMessage(msgs []Message, m Message) []Message {
+	for i, x := range msgs {
+		if x == m {
+			return append(msgs[:i:i], msgs[i+1:]...)
+		}
+	}
+	return msgs
+}
+
+func removeField(flds []Field, f Field) []Field {
+	for i, x := range flds {
+		if x == f {
+			return append(flds[:i:i], flds[i+1:]...)
+		}
+	}
+	return flds
+}
+
+// removeSourceLocations drops the locations of the declaration at path and
+// its children from fd, shifting the paths of subsequent siblings to match
+// their new indexes.
+func removeSourceLocations(fd *descriptor.FileDescriptorProto, path []int32) {
+	if fd.GetSourceCodeInfo() == nil || len(path) == 0 {
+		return
+	}
+
+	n := len(path) - 1
+	locs := fd.SourceCodeInfo.Location[:0]
+
+	for _, loc := range fd.SourceCodeInfo.Location {
+		p := loc.GetPath()
+
+		if len(p) > n && int32sEqual(p[:n], path[:n]) {
+			switch {
+			case p[n] == path[n]:
+				continue
+			case p[n] > path[n]:
+				p[n]--
+			}
+		}
+
+		locs = append(locs, loc)
+	}
+
+	fd.SourceCodeInfo.Location = locs
+}
+
+func int32sEqual(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
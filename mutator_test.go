@@ -0,0 +1,486 @@
+package pgs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protodesc"
+)
+
+func dummyMutator(t *testing.T) (*Mutator, AST, File) {
+	ast := printerAST(t, append(printerDeps(), proto3PrinterFile())...)
+	mu, err := NewMutator(ast)
+	require.NoError(t, err)
+	return mu, ast, ast.Targets()["foo/foo.proto"]
+}
+
+func TestNewMutator(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewMutator(nil)
+	assert.Error(t, err)
+
+	_, err = NewMutator(&graph{})
+	assert.NoError(t, err)
+}
+
+func TestMutator_AddMessage(t *testing.T) {
+	t.Parallel()
+
+	mu, ast, f := dummyMutator(t)
+	parent := f.Messages()[0]
+
+	m, err := mu.AddMessage(parent, &descriptor.DescriptorProto{
+		Name: proto.String("Nested"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{Name: proto.String("msg"), Number: proto.Int32(1), TypeName: proto.String("Msg")},
+			{Name: proto.String("inner"), Number: proto.Int32(2), TypeName: proto.String("Inner")},
+			{Name: proto.String("en"), Number: proto.Int32(3), TypeName: proto.String("Enum")},
+		},
+		NestedType: []*descriptor.DescriptorProto{{Name: proto.String("Inner")}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ".foo.Msg.Nested", m.FullyQualifiedName())
+	assert.Equal(t, parent, m.Parent())
+	assert.Equal(t, f, m.File())
+	assert.Contains(t, parent.Messages(), m)
+	assert.Contains(t, parent.Descriptor().GetNestedType(), m.Descriptor())
+
+	e, ok := ast.Lookup(".foo.Msg.Nested.Inner")
+	require.True(t, ok)
+	assert.Equal(t, m.Messages()[0], e)
+
+	flds := m.Fields()
+	require.Len(t, flds, 3)
+	assert.Equal(t, ".foo.Msg", flds[0].Descriptor().GetTypeName())
+	assert.Equal(t, parent, flds[0].Type().Embed())
+	assert.Equal(t, ".foo.Msg.Nested.Inner", flds[1].Descriptor().GetTypeName())
+	assert.Equal(t, descriptor.FieldDescriptorProto_TYPE_ENUM, flds[2].Descriptor().GetType())
+	assert.True(t, flds[2].Type().IsEnum())
+
+	top, err := mu.AddMessage(f, &descriptor.DescriptorProto{Name: proto.String("Top")})
+	require.NoError(t, err)
+	assert.Equal(t, ".foo.Top", top.FullyQualifiedName())
+	assert.Equal(t, f.Messages()[len(f.Messages())-1], top)
+}
+
+func TestMutator_AddMessage_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		desc *descriptor.DescriptorProto
+	}{
+		{"duplicate", &descriptor.DescriptorProto{Name: proto.String("Msg")}},
+		{"invalid name", &descriptor.DescriptorProto{Name: proto.String("1Msg")}},
+		{"duplicate nested", &descriptor.DescriptorProto{
+			Name:       proto.String("New"),
+			NestedType: []*descriptor.DescriptorProto{{Name: proto.String("A")}, {Name: proto.String("A")}},
+		}},
+		{"duplicate number", &descriptor.DescriptorProto{
+			Name: proto.String("New"),
+			Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("a"), Number: proto.Int32(1), Type: descriptor.FieldDescriptorProto_TYPE_BOOL.Enum()},
+				{Name: proto.String("b"), Number: proto.Int32(1), Type: descriptor.FieldDescriptorProto_TYPE_BOOL.Enum()},
+			},
+		}},
+		{"unknown type", &descriptor.DescriptorProto{
+			Name: proto.String("New"),
+			Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("a"), Number: proto.Int32(1), TypeName: proto.String("Missing")},
+			},
+		}},
+		{"extension", &descriptor.DescriptorProto{
+			Name:      proto.String("New"),
+			Extension: []*descriptor.FieldDescriptorProto{{Name: proto.String("ext")}},
+		}},
+	}
+
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mu, ast, f := dummyMutator(t)
+			n := len(f.Messages())
+
+			_, err := mu.AddMessage(f, tc.desc)
+			assert.Error(t, err)
+			assert.Len(t, f.Messages(), n)
+			assert.Len(t, f.Descriptor().GetMessageType(), n)
+
+			_, ok := ast.Lookup(".foo.New")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestMutator_RemoveMessage(t *testing.T) {
+	t.Parallel()
+
+	mu, ast, f := dummyMutator(t)
+	msg := f.Messages()[0]
+
+	assert.EqualError(t, mu.RemoveMessage(msg.MapEntries()[0]), ".foo.Msg.MapFieldEntry: referenced by .foo.Msg.map_field")
+
+	other, err := mu.AddMessage(f, &descriptor.DescriptorProto{
+		Name: proto.String("Other"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{Name: proto.String("en"), Number: proto.Int32(1), TypeName: proto.String(".foo.Enum")},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, mu.RemoveMessage(other))
+	_, ok := ast.Lookup(".foo.Other")
+	assert.False(t, ok)
+	_, ok = ast.Lookup(".foo.Other.en")
+	assert.False(t, ok)
+	assert.Len(t, f.Messages(), 1)
+
+	assert.EqualError(t, mu.RemoveMessage(other), ".foo.Other: entity is not part of the AST")
+	assert.EqualError(t, mu.RemoveMessage(msg), ".foo.Msg: referenced by .foo.Svc.Stream")
+}
+
+func TestMutator_Field(t *testing.T) {
+	t.Parallel()
+
+	mu, ast, f := dummyMutator(t)
+	msg := f.Messages()[0]
+
+	_, err := mu.AddField(msg, &descriptor.FieldDescriptorProto{
+		Name:   proto.String("string_field"),
+		Number: proto.Int32(100),
+		Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+	})
+	assert.EqualError(t, err, ".foo.Msg.string_field: name is already defined")
+
+	for _, n := range []int32{1, 10, 25, 19500, 0, maxFieldNumber + 1} {
+		_, err = mu.AddField(msg, &descriptor.FieldDescriptorProto{
+			Name:   proto.String("new_field"),
+			Number: proto.Int32(n),
+			Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+		})
+		assert.Error(t, err, n)
+	}
+
+	_, err = mu.AddField(msg, &descriptor.FieldDescriptorProto{
+		Name:   proto.String("foo"),
+		Number: proto.Int32(50),
+		Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+	})
+	assert.EqualError(t, err, ".foo.Msg.foo: field name is reserved")
+
+	_, err = mu.AddField(msg, &descriptor.FieldDescriptorProto{
+		Name:     proto.String("new_field"),
+		Number:   proto.Int32(50),
+		Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+		TypeName: proto.String("Enum"),
+	})
+	assert.Error(t, err)
+
+	fld, err := mu.AddField(msg, &descriptor.FieldDescriptorProto{
+		Name:       proto.String("c"),
+		Number:     proto.Int32(50),
+		Type:       descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+		OneofIndex: proto.Int32(0),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ".foo.Msg.c", fld.FullyQualifiedName())
+	assert.Equal(t, msg, fld.Message())
+	assert.Equal(t, msg.OneOfs()[0], fld.OneOf())
+	assert.Contains(t, msg.OneOfs()[0].Fields(), fld)
+	assert.Equal(t, fld.Descriptor(), msg.Descriptor().GetField()[len(msg.Fields())-1])
+
+	e, ok := ast.Lookup(".foo.Msg.c")
+	assert.True(t, ok)
+	assert.Equal(t, fld, e)
+
+	require.NoError(t, mu.RemoveField(fld))
+	assert.NotContains(t, msg.Fields(), fld)
+	assert.NotContains(t, msg.OneOfs()[0].Fields(), fld)
+	_, ok = ast.Lookup(".foo.Msg.c")
+	assert.False(t, ok)
+
+	first := msg.Fields()[0]
+	require.NoError(t, mu.RemoveField(first))
+	assert.Equal(t, "enum_field", msg.Descriptor().GetField()[0].GetName())
+
+	// the trailing comment of the removed field is dropped
+	for _, loc := range f.Descriptor().GetSourceCodeInfo().GetLocation() {
+		assert.NotEqual(t, " trailing\n", loc.GetTrailingComments())
+	}
+}
+
+func TestMutator_RemoveField_Generated(t *testing.T) {
+	t.Parallel()
+
+	opt := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	rep := descriptor.FieldDescriptorProto_LABEL_REPEATED
+
+	key := printerField("key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, opt)
+	val := printerField("value", 2, descriptor.FieldDescriptorProto_TYPE_INT32, opt)
+
+	m := printerField("m", 1, descriptor.FieldDescriptorProto_TYPE_MESSAGE, rep)
+	m.TypeName = proto.String(".a.M.MEntry")
+
+	o := printerField("o", 2, descriptor.FieldDescriptorProto_TYPE_STRING, opt)
+	o.OneofIndex = proto.Int32(1)
+	o.Proto3Optional = proto.Bool(true)
+
+	c := printerField("c", 3, descriptor.FieldDescriptorProto_TYPE_STRING, opt)
+	c.OneofIndex = proto.Int32(0)
+
+	d := printerField("d", 4, descriptor.FieldDescriptorProto_TYPE_STRING, opt)
+	d.OneofIndex = proto.Int32(2)
+	d.Proto3Optional = proto.Bool(true)
+
+	ast := printerAST(t, &descriptor.FileDescriptorProto{
+		Name:    proto.String("a.proto"),
+		Package: proto.String("a"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name:  proto.String("M"),
+			Field: []*descriptor.FieldDescriptorProto{m, o, c, d},
+			NestedType: []*descriptor.DescriptorProto{{
+				Name:    proto.String("MEntry"),
+				Field:   []*descriptor.FieldDescriptorProto{key, val},
+				Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+			OneofDecl: []*descriptor.OneofDescriptorProto{
+				{Name: proto.String("c_oneof")},
+				{Name: proto.String("_o")},
+				{Name: proto.String("_d")},
+			},
+		}},
+	})
+
+	mu, err := NewMutator(ast)
+	require.NoError(t, err)
+	msg := ast.Targets()["a.proto"].Messages()[0]
+
+	require.NoError(t, mu.RemoveField(msg.Fields()[0]))
+	assert.Empty(t, msg.MapEntries())
+	assert.Empty(t, msg.Descriptor().GetNestedType())
+	_, ok := ast.Lookup(".a.M.MEntry")
+	assert.False(t, ok)
+
+	require.NoError(t, mu.RemoveField(msg.Fields()[0]))
+	require.Len(t, msg.OneOfs(), 2)
+	assert.Equal(t, "c_oneof", msg.OneOfs()[0].Name().String())
+	assert.Equal(t, "_d", msg.OneOfs()[1].Name().String())
+	assert.Len(t, msg.Descriptor().GetOneofDecl(), 2)
+	assert.Equal(t, int32(1), msg.Descriptor().GetField()[1].GetOneofIndex())
+	_, ok = ast.Lookup(".a.M._o")
+	assert.False(t, ok)
+
+	require.NoError(t, mu.RemoveField(msg.Fields()[0]))
+	require.Len(t, msg.OneOfs(), 1)
+	assert.Equal(t, int32(0), msg.Descriptor().GetField()[0].GetOneofIndex())
+
+	_, err = protodesc.NewFiles(ast.ToFileDescriptorSet())
+	assert.NoError(t, err)
+}
+
+func TestMutator_RemoveMessage_MapEntry(t *testing.T) {
+	t.Parallel()
+
+	mu, ast, f := dummyMutator(t)
+	msg := f.Messages()[0]
+	entry := msg.MapEntries()[0]
+
+	// the entry is removed along with its map field
+	fld, ok := ast.Lookup(".foo.Msg.map_field")
+	require.True(t, ok)
+	require.NoError(t, mu.RemoveField(fld.(Field)))
+	assert.EqualError(t, mu.RemoveMessage(entry), ".foo.Msg.MapFieldEntry: entity is not part of the AST")
+
+	// an entry without a map field can be removed directly
+	orphan, err := mu.AddMessage(msg, &descriptor.DescriptorProto{
+		Name:    proto.String("OrphanEntry"),
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, mu.RemoveMessage(orphan))
+	_, ok = ast.Lookup(".foo.Msg.OrphanEntry")
+	assert.False(t, ok)
+}
+
+func TestMutator_Imports(t *testing.T) {
+	t.Parallel()
+
+	dep := func(name, pkg string, deps []string, public ...int32) *descriptor.FileDescriptorProto {
+		return &descriptor.FileDescriptorProto{
+			Name:             proto.String(name),
+			Package:          proto.String(pkg),
+			Syntax:           proto.String("proto3"),
+			Dependency:       deps,
+			PublicDependency: public,
+			MessageType:      []*descriptor.DescriptorProto{{Name: proto.String(strings.ToUpper(pkg))}},
+		}
+	}
+
+	ast := printerAST(t,
+		dep("c.proto", "c", nil),
+		dep("b.proto", "b", nil),
+		dep("p.proto", "p", []string{"c.proto"}, 0),
+		dep("a.proto", "a", []string{"p.proto"}),
+	)
+
+	mu, err := NewMutator(ast)
+	require.NoError(t, err)
+	m := ast.Targets()["a.proto"].Messages()[0]
+
+	_, err = mu.AddField(m, &descriptor.FieldDescriptorProto{
+		Name:     proto.String("b"),
+		Number:   proto.Int32(1),
+		TypeName: proto.String(".b.B"),
+	})
+	assert.EqualError(t, err, `.a.A.b: type ".b.B" is defined in b.proto, which is not imported by a.proto`)
+
+	// c.proto is publicly imported by p.proto
+	_, err = mu.AddField(m, &descriptor.FieldDescriptorProto{
+		Name:     proto.String("c"),
+		Number:   proto.Int32(1),
+		TypeName: proto.String(".c.C"),
+	})
+	assert.NoError(t, err)
+
+	_, err = mu.AddService(ast.Targets()["a.proto"], &descriptor.ServiceDescriptorProto{
+		Name: proto.String("Svc"),
+		Method: []*descriptor.MethodDescriptorProto{{
+			Name:       proto.String("Do"),
+			InputType:  proto.String(".a.A"),
+			OutputType: proto.String(".b.B"),
+		}},
+	})
+	assert.EqualError(t, err, `.a.Svc.Do: output type ".b.B" is defined in b.proto, which is not imported by a.proto`)
+
+	_, err = protodesc.NewFiles(ast.ToFileDescriptorSet())
+	assert.NoError(t, err)
+}
+
+func TestMutator_ServiceAndMethod(t *testing.T) {
+	t.Parallel()
+
+	mu, ast, f := dummyMutator(t)
+	svc := f.Services()[0]
+
+	_, err := mu.AddMethod(svc, &descriptor.MethodDescriptorProto{
+		Name:       proto.String("Unary"),
+		InputType:  proto.String("Msg"),
+		OutputType: proto.String("Msg"),
+	})
+	assert.EqualError(t, err, ".foo.Svc.Unary: name is already defined")
+
+	_, err = mu.AddMethod(svc, &descriptor.MethodDescriptorProto{
+		Name:       proto.String("New"),
+		InputType:  proto.String("Enum"),
+		OutputType: proto.String("Msg"),
+	})
+	assert.EqualError(t, err, `.foo.Svc.New: input type "Enum" is not a message`)
+
+	m, err := mu.AddMethod(svc, &descriptor.MethodDescriptorProto{
+		Name:       proto.String("New"),
+		InputType:  proto.String("Msg"),
+		OutputType: proto.String(".foo.Msg"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ".foo.Svc.New", m.FullyQualifiedName())
+	assert.Equal(t, svc, m.Service())
+	assert.Equal(t, f.Messages()[0], m.Input())
+	assert.Equal(t, ".foo.Msg", m.Descriptor().GetInputType())
+
+	require.NoError(t, mu.RemoveMethod(svc.Methods()[0]))
+	assert.Len(t, svc.Methods(), 2)
+	assert.Equal(t, "Stream", svc.Descriptor().GetMethod()[0].GetName())
+
+	s, err := mu.AddService(f, &descriptor.ServiceDescriptorProto{
+		Name: proto.String("Other"),
+		Method: []*descriptor.MethodDescriptorProto{{
+			Name:       proto.String("Do"),
+			InputType:  proto.String("Msg"),
+			OutputType: proto.String("Msg"),
+		}},
+	})
+	require.NoError(t, err)
+	_, ok := ast.Lookup(".foo.Other.Do")
+	assert.True(t, ok)
+
+	_, err = mu.AddService(f, &descriptor.ServiceDescriptorProto{Name: proto.String("Other")})
+	assert.Error(t, err)
+
+	require.NoError(t, mu.RemoveService(svc))
+	assert.Equal(t, []Service{s}, f.Services())
+	assert.Len(t, f.Descriptor().GetService(), 1)
+	_, ok = ast.Lookup(".foo.Svc.New")
+	assert.False(t, ok)
+}
+
+func TestMutator_Options(t *testing.T) {
+	t.Parallel()
+
+	mu, _, f := dummyMutator(t)
+	m := f.Services()[0].Methods()[0]
+
+	assert.Error(t, mu.MergeOptions(m, "not_an_option: true"))
+	assert.Nil(t, m.Descriptor().GetOptions())
+
+	require.NoError(t, mu.MergeOptions(m, "deprecated: true"))
+	require.NoError(t, mu.MergeOptions(m, `[google.api.http]: { post: "/foo" }`))
+	assert.True(t, m.Descriptor().GetOptions().GetDeprecated())
+
+	// the package's tests replace the extension extractor, so read it directly
+	rule, err := proto.GetExtension(m.Descriptor().GetOptions(), annotations.E_Http)
+	require.NoError(t, err)
+	assert.Equal(t, "/foo", rule.(*annotations.HttpRule).GetPost())
+
+	require.NoError(t, mu.SetExtension(m, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/bar"},
+	}))
+	rule, err = proto.GetExtension(m.Descriptor().GetOptions(), annotations.E_Http)
+	require.NoError(t, err)
+	assert.Equal(t, "/bar", rule.(*annotations.HttpRule).GetGet())
+
+	assert.Error(t, mu.SetExtension(f, annotations.E_Http, &annotations.HttpRule{}))
+
+	require.NoError(t, mu.ClearOption(m, "(google.api.http)"))
+	assert.False(t, proto.HasExtension(m.Descriptor().GetOptions(), annotations.E_Http))
+
+	require.NoError(t, mu.ClearOption(m, "deprecated"))
+	assert.False(t, m.Descriptor().GetOptions().GetDeprecated())
+
+	assert.Error(t, mu.ClearOption(m, "(google.api.missing)"))
+	assert.Error(t, mu.ClearOption(f, "idempotency_level"))
+}
+
+func TestRemoveSourceLocations(t *testing.T) {
+	t.Parallel()
+
+	fd := &descriptor.FileDescriptorProto{SourceCodeInfo: &descriptor.SourceCodeInfo{
+		Location: []*descriptor.SourceCodeInfo_Location{
+			{Path: []int32{4}},
+			{Path: []int32{4, 0}},
+			{Path: []int32{4, 1}},
+			{Path: []int32{4, 1, 2, 0}},
+			{Path: []int32{4, 2}},
+			{Path: []int32{4, 2, 2, 0}},
+			{Path: []int32{6, 2}},
+		},
+	}}
+
+	removeSourceLocations(fd, []int32{4, 1})
+
+	var paths [][]int32
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		paths = append(paths, loc.GetPath())
+	}
+
+	assert.Equal(t, [][]int32{{4}, {4, 0}, {4, 1}, {4, 1, 2, 0}, {6, 2}}, paths)
+}
@@ -3,6 +3,7 @@ package pgs
 import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // OneOf describes a OneOf block within a Message. OneOfs behave like C++
@@ -13,12 +14,24 @@ type OneOf interface {
 	// Descriptor returns the underlying proto descriptor for this OneOf
 	Descriptor() *descriptor.OneofDescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this OneOf,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved.
+	ReflectDescriptor() protoreflect.OneofDescriptor
+
 	// Message returns the parent message for this OneOf.
 	Message() Message
 
 	// Fields returns all fields contained within this OneOf.
 	Fields() []Field
 
+	// IsSynthetic returns true if this OneOf was generated by protoc to track
+	// presence of a proto3 field labeled with the optional keyword. Synthetic
+	// OneOfs contain exactly one field and are not present in generated code.
+	//
+	// See: https://github.com/protocolbuffers/protobuf/blob/master/docs/implementing_proto3_presence.md
+	IsSynthetic() bool
+
 	setMessage(m Message)
 	addField(f Field)
 }
@@ -32,6 +45,11 @@ type oneof struct {
 	info SourceCodeInfo
 }
 
+func (o *oneof) ReflectDescriptor() protoreflect.OneofDescriptor {
+	d, _ := findDescriptor(o).(protoreflect.OneofDescriptor)
+	return d
+}
+
 func (o *oneof) accept(v Visitor) (err error) {
 	if v == nil {
 		return
@@ -52,6 +70,10 @@ func (o *oneof) Descriptor() *descriptor.OneofDescriptorProto { return o.desc }
 func (o *oneof) Message() Message                             { return o.msg }
 func (o *oneof) setMessage(m Message)                         { o.msg = m }
 
+func (o *oneof) Features() *descriptor.FeatureSet {
+	return resolveFeatures(o.msg.Features(), o.desc.GetOptions().GetFeatures())
+}
+
 func (o *oneof) Imports() (i []File) {
 	// Mapping for avoiding duplicate entries
 	mp := make(map[string]File, len(o.flds))
@@ -76,6 +98,10 @@ func (o *oneof) Fields() []Field {
 	return f
 }
 
+func (o *oneof) IsSynthetic() bool {
+	return len(o.flds) == 1 && o.flds[0].Descriptor().GetProto3Optional()
+}
+
 func (o *oneof) addField(f Field) {
 	f.setOneOf(o)
 	o.flds = append(o.flds, f)
@@ -109,6 +109,23 @@ func TestOneof_Extension(t *testing.T) {
 	assert.NotPanics(t, func() { o.Extension(nil, nil) })
 }
 
+func TestOneof_IsSynthetic(t *testing.T) {
+	t.Parallel()
+
+	o := &oneof{}
+	assert.False(t, o.IsSynthetic())
+
+	f := &field{desc: &descriptor.FieldDescriptorProto{}}
+	o.addField(f)
+	assert.False(t, o.IsSynthetic())
+
+	f.desc.Proto3Optional = proto.Bool(true)
+	assert.True(t, o.IsSynthetic())
+
+	o.addField(&field{})
+	assert.False(t, o.IsSynthetic())
+}
+
 func TestOneof_Fields(t *testing.T) {
 	t.Parallel()
 
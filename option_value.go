@@ -0,0 +1,119 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// OptionValue returns the value of the custom option described by xt from the
+// options of e. Unlike Extension, the option is resolved even if it was
+// received from protoc as an unknown field, so xt may be a generated extension
+// type (eg, annotations.E_Http) or a dynamic one (see LookupExtensionType). An
+// error is returned if xt does not extend the options of e. The ok value will
+// be true if the option is set.
+func OptionValue(e Entity, xt protoreflect.ExtensionType) (v protoreflect.Value, ok bool, err error) {
+	if xt == nil {
+		return v, false, errors.New("nil protoreflect.ExtensionType parameter provided")
+	}
+
+	opts, err := entityOptions(e)
+	if err != nil || opts == nil {
+		return v, false, err
+	}
+
+	xd := xt.TypeDescriptor()
+	if xd.ContainingMessage().FullName() != opts.Descriptor().FullName() {
+		return v, false, fmt.Errorf("%s: extension %s extends %s, not %s", e.FullyQualifiedName(),
+			xd.FullName(), xd.ContainingMessage().FullName(), opts.Descriptor().FullName())
+	}
+
+	// reparse the options so that xt is resolved, regardless of the types
+	// known when the request was decoded
+	b, err := proto.Marshal(opts.Interface())
+	if err != nil {
+		return v, false, err
+	}
+
+	types := new(protoregistry.Types)
+	if err = types.RegisterExtension(xt); err != nil {
+		return v, false, err
+	}
+
+	m := opts.New()
+	if err = (proto.UnmarshalOptions{Resolver: types}).Unmarshal(b, m.Interface()); err != nil {
+		return v, false, fmt.Errorf("%s: invalid options: %v", e.FullyQualifiedName(), err)
+	}
+
+	if !m.Has(xd) {
+		return v, false, nil
+	}
+
+	return m.Get(xd), true, nil
+}
+
+// OptionValueByName has the same functionality as OptionValue, but resolves
+// the extension by its fully-qualified name (eg, ".foo.bar") with
+// LookupExtensionType.
+func OptionValueByName(e Entity, name string) (v protoreflect.Value, ok bool, err error) {
+	xt, err := LookupExtensionType(e, name)
+	if err != nil {
+		return v, false, err
+	}
+
+	return OptionValue(e, xt)
+}
+
+// LookupExtensionType returns a dynamic protoreflect.ExtensionType for the
+// extension with the fully-qualified name (eg, ".foo.bar"), resolved from the
+// files of the AST containing e. This permits reading options defined in the
+// protoc request that are not compiled into the plugin. Message values of the
+// extension are dynamicpb messages.
+func LookupExtensionType(e Entity, name string) (protoreflect.ExtensionType, error) {
+	reg, err := entityRegistry(e)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := reg.Files()
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve descriptors: %v", err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(name, ".")))
+	if err != nil {
+		return nil, fmt.Errorf("unknown extension %s: %v", name, err)
+	}
+
+	xd, ok := d.(protoreflect.ExtensionDescriptor)
+	if !ok || !xd.IsExtension() {
+		return nil, fmt.Errorf("%s is not an extension", name)
+	}
+
+	return dynamicpb.NewExtensionType(xd), nil
+}
+
+// entityOptions returns the options of e, or nil if none are set.
+func entityOptions(e Entity) (protoreflect.Message, error) {
+	if e == nil {
+		return nil, errors.New("entity is nil")
+	}
+
+	desc := entityDescriptor(e)
+	if desc == nil {
+		return nil, fmt.Errorf("%s: entity does not support options", e.FullyQualifiedName())
+	}
+
+	d := desc.(protoreflect.ProtoMessage).ProtoReflect()
+	fd := d.Descriptor().Fields().ByName("options")
+	if !d.Has(fd) {
+		return nil, nil
+	}
+
+	return d.Get(fd).Message(), nil
+}
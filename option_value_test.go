@@ -0,0 +1,201 @@
+package pgs
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var optionSources = map[string]string{
+	"opts/opts.proto": `syntax = "proto3";
+package opts;
+
+import "google/protobuf/descriptor.proto";
+
+message Rule {
+  int32 min = 1;
+  repeated string tags = 2;
+}
+
+extend google.protobuf.MessageOptions {
+  string tag = 50000;
+}
+
+extend google.protobuf.FieldOptions {
+  Rule rule = 50001;
+}
+`,
+	"foo/foo.proto": `syntax = "proto3";
+package foo;
+
+import "opts/opts.proto";
+
+message Foo {
+  option (opts.tag) = "foo";
+
+  string bar = 1 [(opts.rule) = {min: 3, tags: ["a", "b"]}];
+  string baz = 2;
+}
+`,
+}
+
+func optionAST(t *testing.T) AST {
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, compileRequest(t, optionSources, "foo/foo.proto"))
+	require.False(t, d.Failed())
+	return ast
+}
+
+func TestOptionValueByName(t *testing.T) {
+	t.Parallel()
+
+	ast := optionAST(t)
+
+	foo, ok := ast.Lookup(".foo.Foo")
+	require.True(t, ok)
+
+	v, ok, err := OptionValueByName(foo, ".opts.tag")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "foo", v.String())
+
+	bar, ok := ast.Lookup(".foo.Foo.bar")
+	require.True(t, ok)
+
+	v, ok, err = OptionValueByName(bar, "opts.rule")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	rule := v.Message()
+	assert.Equal(t, int64(3), rule.Get(rule.Descriptor().Fields().ByName("min")).Int())
+	assert.Equal(t, 2, rule.Get(rule.Descriptor().Fields().ByName("tags")).List().Len())
+
+	t.Run("not set", func(t *testing.T) {
+		baz, ok := ast.Lookup(".foo.Foo.baz")
+		require.True(t, ok)
+
+		_, ok, err := OptionValueByName(baz, ".opts.rule")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		_, ok, err = OptionValueByName(bar, ".opts.tag")
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("unknown extension", func(t *testing.T) {
+		_, _, err := OptionValueByName(foo, ".opts.missing")
+		assert.Error(t, err)
+
+		_, _, err = OptionValueByName(foo, ".opts.Rule")
+		assert.Error(t, err)
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		d := InitMockDebugger()
+		ast := ProcessCodeGeneratorRequestLazy(d, compileRequest(t, optionSources, "foo/foo.proto"))
+		require.False(t, d.Failed())
+
+		foo, ok := ast.Lookup(".foo.Foo")
+		require.True(t, ok)
+
+		v, ok, err := OptionValueByName(foo, ".opts.tag")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "foo", v.String())
+	})
+}
+
+func TestOptionValue(t *testing.T) {
+	t.Parallel()
+
+	md := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("Do"),
+		InputType:  proto.String(".foo.Foo"),
+		OutputType: proto.String(".foo.Foo"),
+		Options:    &descriptor.MethodOptions{},
+	}
+	require.NoError(t, proto.SetExtension(md.Options, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/foo"},
+	}))
+
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"foo.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{{
+			Name:        proto.String("foo.proto"),
+			Package:     proto.String("foo"),
+			Syntax:      proto.String("proto3"),
+			MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Foo")}},
+			Service: []*descriptor.ServiceDescriptorProto{{
+				Name:   proto.String("Svc"),
+				Method: []*descriptor.MethodDescriptorProto{md},
+			}},
+		}},
+	})
+	require.False(t, d.Failed())
+
+	m, ok := ast.Lookup(".foo.Svc.Do")
+	require.True(t, ok)
+
+	v, ok, err := OptionValue(m, annotations.E_Http)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/foo", v.Message().Interface().(*annotations.HttpRule).GetGet())
+
+	t.Run("no options", func(t *testing.T) {
+		f, ok := ast.Lookup(".foo.Foo")
+		require.True(t, ok)
+
+		_, ok, err := OptionValue(f, annotations.E_Http)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("wrong extendee", func(t *testing.T) {
+		svc, ok := ast.Lookup(".foo.Svc")
+		require.True(t, ok)
+		svc.(Service).Descriptor().Options = &descriptor.ServiceOptions{Deprecated: proto.Bool(true)}
+
+		_, ok, err := OptionValue(svc, annotations.E_Http)
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("nil type", func(t *testing.T) {
+		_, _, err := OptionValue(m, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("nil entity", func(t *testing.T) {
+		_, _, err := OptionValue(nil, annotations.E_Http)
+		assert.Error(t, err)
+
+		_, err = LookupExtensionType(nil, ".foo.bar")
+		assert.Error(t, err)
+	})
+
+	t.Run("detached entity", func(t *testing.T) {
+		_, err := LookupExtensionType(&msg{parent: &file{desc: &descriptor.FileDescriptorProto{}}}, ".foo.bar")
+		assert.Error(t, err)
+	})
+}
+
+func TestLookupExtensionType(t *testing.T) {
+	t.Parallel()
+
+	ast := optionAST(t)
+	f := ast.Targets()["foo/foo.proto"]
+	require.NotNil(t, f)
+
+	xt, err := LookupExtensionType(f, ".opts.rule")
+	require.NoError(t, err)
+	assert.Equal(t, protoreflect.FullName("opts.rule"), xt.TypeDescriptor().FullName())
+	assert.Equal(t, protoreflect.FullName("google.protobuf.FieldOptions"), xt.TypeDescriptor().ContainingMessage().FullName())
+}
@@ -22,13 +22,19 @@ type pkg struct {
 	fd    *descriptor.FileDescriptorProto
 	files []File
 
+	// resolveFiles defers hydrating files in a lazily built AST
+	resolveFiles *lazyRef
+
 	comments string
 }
 
 func (p *pkg) ProtoName() Name  { return Name(p.fd.GetPackage()) }
 func (p *pkg) Comments() string { return p.comments }
 
-func (p *pkg) Files() []File { return p.files }
+func (p *pkg) Files() []File {
+	p.resolveFiles.resolve()
+	return p.files
+}
 
 func (p *pkg) accept(v Visitor) (err error) {
 	if v == nil {
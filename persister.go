@@ -3,17 +3,28 @@ package pgs
 import (
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/golang/protobuf/proto"
 	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/spf13/afero"
 )
 
-type persister interface {
+// A Persister converts the Artifacts produced by Modules into the
+// CodeGeneratorResponse returned to protoc, writing any custom files directly
+// to its file system. A custom Persister may be provided to the Generator via
+// the UsePersister or WrapPersister InitOptions.
+type Persister interface {
+	// SetDebugger sets the Debugger used to report failures while persisting.
 	SetDebugger(d Debugger)
+
+	// SetFS sets the file system custom files are written to.
 	SetFS(fs afero.Fs)
+
+	// AddPostProcessor registers PostProcessors to be applied to the
+	// content of matching Artifacts, in the order they are added.
 	AddPostProcessor(proc ...PostProcessor)
+
+	// Persist converts the Artifacts into a CodeGeneratorResponse.
 	Persist(a ...Artifact) *plugin_go.CodeGeneratorResponse
 }
 
@@ -24,6 +35,11 @@ type stdPersister struct {
 	procs []PostProcessor
 }
 
+// NewPersister returns the default Persister used by the Generator, which
+// writes custom files to the OS's file system. This is useful for wrapping the
+// standard behavior in a custom Persister.
+func NewPersister() Persister { return newPersister() }
+
 func newPersister() *stdPersister { return &stdPersister{fs: afero.NewOsFs()} }
 
 func (p *stdPersister) SetDebugger(d Debugger)                 { p.Debugger = d }
@@ -37,34 +53,60 @@ func (p *stdPersister) Persist(arts ...Artifact) *plugin_go.CodeGeneratorRespons
 		switch a := a.(type) {
 		case GeneratorFile:
 			f, err := a.ProtoFile()
-			p.CheckErr(err, "unable to convert ", a.Name, " to proto")
+			if !p.check(err, "unable to convert ", a.Name, " to proto") {
+				continue
+			}
 			f.Content = proto.String(p.postProcess(a, f.GetContent()))
 			p.insertFile(resp, f, a.Overwrite)
 		case GeneratorTemplateFile:
 			f, err := a.ProtoFile()
-			p.CheckErr(err, "unable to convert ", a.Name, " to proto")
+			if !p.check(err, "unable to convert ", a.Name, " to proto") {
+				continue
+			}
 			f.Content = proto.String(p.postProcess(a, f.GetContent()))
 			p.insertFile(resp, f, a.Overwrite)
+		case GeneratorBinaryFile:
+			f, err := a.ProtoFile()
+			if !p.check(err, "unable to convert ", a.Name, " to proto") {
+				continue
+			}
+			f.Content = proto.String(string(p.postProcessBytes(a, []byte(f.GetContent()))))
+			p.insertFile(resp, f, a.Overwrite)
+		case GeneratorBinaryTemplateFile:
+			f, err := a.ProtoFile()
+			if !p.check(err, "unable to convert ", a.Name, " to proto") {
+				continue
+			}
+			f.Content = proto.String(string(p.postProcessBytes(a, []byte(f.GetContent()))))
+			p.insertFile(resp, f, a.Overwrite)
 		case GeneratorAppend:
 			f, err := a.ProtoFile()
-			p.CheckErr(err, "unable to convert append for ", a.FileName, " to proto")
+			if !p.check(err, "unable to convert append for ", a.FileName, " to proto") {
+				continue
+			}
 			f.Content = proto.String(p.postProcess(a, f.GetContent()))
 			n, _ := cleanGeneratorFileName(a.FileName)
 			p.insertAppend(resp, n, f)
 		case GeneratorTemplateAppend:
 			f, err := a.ProtoFile()
-			p.CheckErr(err, "unable to convert append for ", a.FileName, " to proto")
+			if !p.check(err, "unable to convert append for ", a.FileName, " to proto") {
+				continue
+			}
 			f.Content = proto.String(p.postProcess(a, f.GetContent()))
 			n, _ := cleanGeneratorFileName(a.FileName)
 			p.insertAppend(resp, n, f)
 		case GeneratorInjection:
 			f, err := a.ProtoFile()
-			p.CheckErr(err, "unable to convert injection ", a.InsertionPoint, " for ", a.FileName, " to proto")
+			if !p.check(err, "unable to convert injection ", a.InsertionPoint, " for ", a.FileName, " to proto") {
+				continue
+			}
 			f.Content = proto.String(p.postProcess(a, f.GetContent()))
 			p.insertFile(resp, f, false)
 		case GeneratorTemplateInjection:
 			f, err := a.ProtoFile()
-			p.CheckErr(err, "unable to convert injection ", a.InsertionPoint, " for ", a.FileName, " to proto")
+			if !p.check(err, "unable to convert injection ", a.InsertionPoint, " for ", a.FileName, " to proto") {
+				continue
+			}
 			f.Content = proto.String(p.postProcess(a, f.GetContent()))
 			p.insertFile(resp, f, false)
 		case CustomFile:
@@ -76,7 +118,9 @@ func (p *stdPersister) Persist(arts ...Artifact) *plugin_go.CodeGeneratorRespons
 			)
 		case CustomTemplateFile:
 			content, err := a.render()
-			p.CheckErr(err, "unable to render CustomTemplateFile: ", a.Name)
+			if !p.check(err, "unable to render CustomTemplateFile: ", a.Name) {
+				continue
+			}
 			content = p.postProcess(a, content)
 			p.writeFile(
 				a.Name,
@@ -85,11 +129,7 @@ func (p *stdPersister) Persist(arts ...Artifact) *plugin_go.CodeGeneratorRespons
 				a.Perms,
 			)
 		case GeneratorError:
-			if resp.Error == nil {
-				resp.Error = proto.String(a.Message)
-				continue
-			}
-			resp.Error = proto.String(strings.Join([]string{resp.GetError(), a.Message}, "; "))
+			appendResponseError(resp, a.Message)
 		default:
 			p.Failf("unrecognized artifact type: %T", a)
 		}
@@ -141,7 +181,10 @@ func (p *stdPersister) insertFile(resp *plugin_go.CodeGeneratorResponse,
 func (p *stdPersister) insertAppend(resp *plugin_go.CodeGeneratorResponse,
 	name string, f *plugin_go.CodeGeneratorResponse_File) {
 	i := p.tailOfFile(resp, name)
-	p.Assert(i > -1, "append target ", name, " missing")
+	if i == -1 {
+		p.Fail("append target ", name, " missing")
+		return
+	}
 
 	resp.File = append(
 		resp.File[:i+1],
@@ -154,12 +197,16 @@ func (p *stdPersister) insertAppend(resp *plugin_go.CodeGeneratorResponse,
 
 func (p *stdPersister) writeFile(name string, content []byte, overwrite bool, perms os.FileMode) {
 	dir := filepath.Dir(name)
-	p.CheckErr(
+	if !p.check(
 		p.fs.MkdirAll(dir, 0755),
-		"unable to create directory:", dir)
+		"unable to create directory:", dir) {
+		return
+	}
 
 	exists, err := afero.Exists(p.fs, name)
-	p.CheckErr(err, "unable to check file exists:", name)
+	if !p.check(err, "unable to check file exists:", name) {
+		return
+	}
 
 	if exists {
 		if !overwrite {
@@ -175,14 +222,28 @@ func (p *stdPersister) writeFile(name string, content []byte, overwrite bool, pe
 }
 
 func (p *stdPersister) postProcess(a Artifact, in string) string {
-	var err error
-	b := []byte(in)
+	return string(p.postProcessBytes(a, []byte(in)))
+}
+
+func (p *stdPersister) postProcessBytes(a Artifact, b []byte) []byte {
 	for _, pp := range p.procs {
 		if pp.Match(a) {
-			b, err = pp.Process(b)
-			p.CheckErr(err, "failed post-processing")
+			out, err := pp.Process(b)
+			if p.check(err, "failed post-processing") {
+				b = out
+			}
 		}
 	}
 
-	return string(b)
+	return b
+}
+
+// check reports err to the Debugger, returning true if err is nil. This
+// allows the persister to skip an Artifact if the Debugger is collecting
+// errors instead of exiting.
+func (p *stdPersister) check(err error, v ...interface{}) bool {
+	p.CheckErr(err, v...)
+	return err == nil
 }
+
+var _ Persister = (*stdPersister)(nil)
@@ -89,6 +89,35 @@ func TestPersister_Persist_GeneratorTemplateFile(t *testing.T) {
 	assert.Equal(t, "fizz", resp.File[0].GetContent())
 }
 
+func TestPersister_Persist_GeneratorBinaryFile(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	p := dummyPersister(d)
+
+	resp := p.Persist(
+		GeneratorBinaryFile{Name: "foo.bin", Contents: []byte{0xff, 0x00}},
+		GeneratorBinaryTemplateFile{
+			Name: "bar.bin",
+			TemplateArtifact: TemplateArtifact{
+				Template: genTpl,
+				Data:     "baz",
+			},
+		},
+		GeneratorBinaryFile{Name: "foo.bin", Contents: []byte{0x01}, Overwrite: true},
+	)
+
+	assert.Len(t, resp.File, 2)
+	assert.Equal(t, "foo.bin", resp.File[0].GetName())
+	assert.Equal(t, []byte{0x01}, []byte(resp.File[0].GetContent()))
+	assert.Equal(t, "bar.bin", resp.File[1].GetName())
+	assert.Equal(t, "baz", resp.File[1].GetContent())
+
+	p.AddPostProcessor(mockPP{match: true, out: []byte{0xff, 0xfe}})
+	resp = p.Persist(GeneratorBinaryFile{Name: "foo.bin", Contents: []byte{0x00}})
+	assert.Equal(t, []byte{0xff, 0xfe}, []byte(resp.File[0].GetContent()))
+}
+
 func TestPersister_Persist_GeneratorAppend(t *testing.T) {
 	t.Parallel()
 
@@ -338,6 +367,14 @@ func TestPersister_AddPostProcessor(t *testing.T) {
 	assert.Equal(t, "good", out)
 }
 
+func TestNewPersister(t *testing.T) {
+	t.Parallel()
+
+	p := NewPersister()
+	assert.IsType(t, &stdPersister{}, p)
+	assert.IsType(t, afero.NewOsFs(), p.(*stdPersister).fs)
+}
+
 func dummyPersister(d Debugger) *stdPersister {
 	return &stdPersister{
 		Debugger: d,
@@ -382,3 +419,32 @@ func TestPersister_Persist_GeneratorError(t *testing.T) {
 		})
 	}
 }
+
+func TestPersister_Persist_CollectErrors(t *testing.T) {
+	t.Parallel()
+
+	md := InitMockDebugger()
+	diags := &diagnostics{}
+	p := dummyPersister(initCollectingDebugger(md, diags))
+	fs := afero.NewMemMapFs()
+	p.SetFS(fs)
+
+	resp := p.Persist(
+		GeneratorFile{Name: "/foo", Contents: "bar"},
+		GeneratorFile{Name: "fizz", Contents: "buzz"},
+		GeneratorAppend{FileName: "missing", Contents: "baz"},
+		CustomTemplateFile{Name: "quux", TemplateArtifact: TemplateArtifact{
+			Template: template.Must(template.New("foo").Parse("{{ .Bad.Field }}")),
+			Data:     struct{}{},
+		}},
+	)
+
+	assert.False(t, md.Failed())
+	assert.Len(t, resp.File, 1)
+	assert.Equal(t, "fizz", resp.File[0].GetName())
+	assert.Len(t, diags.all(), 3)
+
+	exists, err := afero.Exists(fs, "quux")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
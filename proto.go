@@ -18,6 +18,13 @@ const (
 	// structs are value types.
 	// See: https://developers.google.com/protocol-buffers/docs/proto3
 	Proto3 Syntax = "proto3"
+
+	// Editions syntax replaces the proto2 and proto3 distinction with an
+	// edition and a set of features that may be overridden at any level of the
+	// file. Use Edition on File and Features on any Entity to determine the
+	// resolved behavior.
+	// See: https://protobuf.dev/editions/overview/
+	Editions Syntax = "editions"
 )
 
 // SupportsRequiredPrefix returns true if s supports "optional" and
@@ -30,6 +37,38 @@ func (s Syntax) String() string {
 	return string(s)
 }
 
+// Edition wraps the Edition enum for better readability. It is a 1-to-1
+// conversion. Files using proto2 or proto3 syntax are considered to be in the
+// EditionProto2 and EditionProto3 editions, respectively.
+type Edition descriptor.Edition
+
+const (
+	// EditionUnknown indicates the edition could not be determined.
+	EditionUnknown = Edition(descriptor.Edition_EDITION_UNKNOWN)
+
+	// EditionProto2 is the edition of all files using proto2 syntax.
+	EditionProto2 = Edition(descriptor.Edition_EDITION_PROTO2)
+
+	// EditionProto3 is the edition of all files using proto3 syntax.
+	EditionProto3 = Edition(descriptor.Edition_EDITION_PROTO3)
+
+	// Edition2023 is the first edition, declared via `edition = "2023";`.
+	Edition2023 = Edition(descriptor.Edition_EDITION_2023)
+
+	// MinEdition is the earliest edition supported by PG*.
+	MinEdition = EditionProto2
+
+	// MaxEdition is the latest edition supported by PG*.
+	MaxEdition = Edition2023
+)
+
+// Proto returns the Edition enum value for this Edition. This method is
+// exclusively used to improve readability without having to switch the types.
+func (e Edition) Proto() descriptor.Edition { return descriptor.Edition(e) }
+
+// String returns a string representation of the edition.
+func (e Edition) String() string { return e.Proto().String() }
+
 // ProtoLabel wraps the FieldDescriptorProto_Label enum for better readability.
 // It is a 1-to-1 conversion.
 type ProtoLabel descriptor.FieldDescriptorProto_Label
@@ -0,0 +1,971 @@
+package pgs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxFieldNumber is the largest field number permitted in a message, printed
+// as `max` in extension and reserved ranges.
+const maxFieldNumber = 536870911
+
+// maxEnumNumber is the largest value permitted in an enum, printed as `max` in
+// reserved ranges.
+const maxEnumNumber = math.MaxInt32
+
+// ProtoPrinter renders entities back into formatted `.proto` source. Any File,
+// Message, Enum, EnumValue, Service, Method, Field, Extension, or OneOf may be
+// printed. Printed Files are valid input to protoc. The zero value prints all
+// options and comments, using two spaces for indentation.
+//
+// Type references are printed fully-qualified (eg, `.foo.bar.Baz`) to avoid
+// any ambiguity when resolving names. Custom options whose extensions are not
+// linked into the plugin binary are resolved from the descriptors of the
+// entity's AST. Any option that still cannot be decoded is omitted and logged
+// through the AST's Debugger, and causes Print to fail.
+type ProtoPrinter struct {
+	// Indent is the string used to indent nested declarations. If empty, two
+	// spaces are used.
+	Indent string
+
+	// OmitComments prevents comments from the entities' SourceCodeInfo from
+	// being printed.
+	OmitComments bool
+
+	// OptionFilter, if non-nil, is called for each option set on an entity
+	// with the option's name as it would be printed (eg, `deprecated`,
+	// `features.field_presence`, or `(google.api.http)`). The option is only
+	// printed if OptionFilter returns true.
+	OptionFilter func(e Entity, name string) bool
+
+	// FileOptions are additional options printed on every File, written as
+	// they appear after the `option` keyword (eg,
+	// `(gogoproto.marshaler_all) = true`).
+	FileOptions []string
+
+	// FileImports are additional import paths printed on every File, typically
+	// required by the extensions used in FileOptions. Paths the File already
+	// imports are not repeated.
+	FileImports []string
+}
+
+// Print writes the `.proto` source for e to w. An error is returned without
+// writing anything if any options of e could not be decoded.
+func (p ProtoPrinter) Print(w io.Writer, e Entity) error {
+	pp := p.print(e)
+	if len(pp.unresolved) > 0 {
+		return fmt.Errorf("unable to decode options of %s", strings.Join(pp.unresolved, ", "))
+	}
+
+	_, err := io.WriteString(w, pp.buf.String())
+	return err
+}
+
+// Sprint returns the `.proto` source for e. Options that could not be decoded
+// are omitted.
+func (p ProtoPrinter) Sprint(e Entity) string {
+	return p.print(e).buf.String()
+}
+
+func (p ProtoPrinter) print(e Entity) *protoPrinter {
+	pp := &protoPrinter{ProtoPrinter: p}
+	if pp.Indent == "" {
+		pp.Indent = "  "
+	}
+
+	switch e := e.(type) {
+	case File:
+		pp.syntax = e.Syntax()
+		pp.file(e)
+	case Message:
+		pp.syntax = entitySyntax(e)
+		pp.message(e)
+	case Enum:
+		pp.enum(e)
+	case EnumValue:
+		pp.enumValue(e)
+	case Service:
+		pp.service(e)
+	case Method:
+		pp.method(e)
+	case Extension:
+		pp.syntax = entitySyntax(e)
+		pp.extend(e.Descriptor().GetExtendee(), []Extension{e})
+	case Field:
+		pp.syntax = entitySyntax(e)
+		pp.field(e, e.Message())
+	case OneOf:
+		pp.syntax = entitySyntax(e)
+		pp.oneOf(e)
+	}
+
+	return pp
+}
+
+// entitySyntax returns the syntax of e, tolerating entities that are not
+// attached to a File.
+func entitySyntax(e Entity) (s Syntax) {
+	defer func() {
+		if recover() != nil {
+			s = Proto2
+		}
+	}()
+	return e.Syntax()
+}
+
+type protoPrinter struct {
+	ProtoPrinter
+
+	buf    bytes.Buffer
+	depth  int
+	syntax Syntax
+
+	// types resolves the extensions declared in the AST of the printed
+	// entities, built the first time an option cannot be decoded
+	types *dynamicpb.Types
+
+	// unresolved lists the entities with options that could not be decoded
+	unresolved []string
+}
+
+func (p *protoPrinter) line(s ...string) {
+	if len(s) == 0 {
+		p.buf.WriteByte('\n')
+		return
+	}
+
+	p.buf.WriteString(strings.Repeat(p.Indent, p.depth))
+	for _, str := range s {
+		p.buf.WriteString(str)
+	}
+	p.buf.WriteByte('\n')
+}
+
+// open writes the first line of a block, followed by its trailing comment.
+func (p *protoPrinter) open(info SourceCodeInfo, s ...string) {
+	p.buf.WriteString(strings.Repeat(p.Indent, p.depth))
+	for _, str := range s {
+		p.buf.WriteString(str)
+	}
+	p.buf.WriteString(" {")
+	p.trailing(info)
+	p.depth++
+}
+
+func (p *protoPrinter) close() {
+	p.depth--
+	p.line("}")
+}
+
+// statement writes a single line declaration, followed by its trailing
+// comment.
+func (p *protoPrinter) statement(info SourceCodeInfo, s ...string) {
+	p.buf.WriteString(strings.Repeat(p.Indent, p.depth))
+	for _, str := range s {
+		p.buf.WriteString(str)
+	}
+	p.buf.WriteByte(';')
+	p.trailing(info)
+}
+
+func (p *protoPrinter) leading(info SourceCodeInfo) {
+	if p.OmitComments || info == nil || info.Location() == nil {
+		return
+	}
+
+	for _, c := range info.LeadingDetachedComments() {
+		p.comment(c)
+		p.line()
+	}
+
+	if c := info.LeadingComments(); c != "" {
+		p.comment(c)
+	}
+}
+
+func (p *protoPrinter) trailing(info SourceCodeInfo) {
+	if p.OmitComments || info == nil || info.Location() == nil || info.TrailingComments() == "" {
+		p.buf.WriteByte('\n')
+		return
+	}
+
+	lines := commentLines(info.TrailingComments())
+	p.buf.WriteString(" //" + lines[0] + "\n")
+	for _, l := range lines[1:] {
+		p.line("//", l)
+	}
+}
+
+func (p *protoPrinter) comment(c string) {
+	for _, l := range commentLines(c) {
+		p.line("//", l)
+	}
+}
+
+func commentLines(c string) []string {
+	return strings.Split(strings.TrimSuffix(c, "\n"), "\n")
+}
+
+func (p *protoPrinter) file(f File) {
+	desc := f.Descriptor()
+
+	p.leading(f.SyntaxSourceCodeInfo())
+	if p.syntax == Editions {
+		p.statement(f.SyntaxSourceCodeInfo(), `edition = "`, strings.TrimPrefix(f.Edition().String(), "EDITION_"), `"`)
+	} else {
+		syntax := p.syntax.String()
+		if p.syntax == Proto2 {
+			syntax = "proto2"
+		}
+		p.statement(f.SyntaxSourceCodeInfo(), `syntax = "`, syntax, `"`)
+	}
+
+	if pkg := desc.GetPackage(); pkg != "" {
+		p.line()
+		p.leading(f.PackageSourceCodeInfo())
+		p.statement(f.PackageSourceCodeInfo(), "package ", pkg)
+	}
+
+	if len(desc.GetDependency()) > 0 || len(p.FileImports) > 0 {
+		p.line()
+	}
+
+	public := make(map[int32]bool, len(desc.GetPublicDependency()))
+	for _, i := range desc.GetPublicDependency() {
+		public[i] = true
+	}
+
+	weak := make(map[int32]bool, len(desc.GetWeakDependency()))
+	for _, i := range desc.GetWeakDependency() {
+		weak[i] = true
+	}
+
+	for i, dep := range desc.GetDependency() {
+		switch {
+		case public[int32(i)]:
+			p.line("import public ", quoteString(dep), ";")
+		case weak[int32(i)]:
+			p.line("import weak ", quoteString(dep), ";")
+		default:
+			p.line("import ", quoteString(dep), ";")
+		}
+	}
+
+	for _, imp := range p.FileImports {
+		if !slices.Contains(desc.GetDependency(), imp) {
+			p.line("import ", quoteString(imp), ";")
+		}
+	}
+
+	opts := p.options(f, desc.GetOptions())
+	if len(opts) > 0 || len(p.FileOptions) > 0 {
+		p.line()
+	}
+	p.optionStatements(opts)
+	for _, opt := range p.FileOptions {
+		p.line("option ", opt, ";")
+	}
+
+	for _, e := range f.Enums() {
+		p.line()
+		p.enum(e)
+	}
+
+	for _, m := range f.Messages() {
+		if p.isGroupBody(m, nil, f.DefinedExtensions()) {
+			continue
+		}
+		p.line()
+		p.message(m)
+	}
+
+	for _, s := range f.Services() {
+		p.line()
+		p.service(s)
+	}
+
+	p.extensions(f.DefinedExtensions())
+}
+
+func (p *protoPrinter) message(m Message) {
+	desc := m.Descriptor()
+
+	p.leading(m.SourceCodeInfo())
+	p.open(m.SourceCodeInfo(), "message ", desc.GetName())
+	p.messageBody(m)
+	p.close()
+}
+
+func (p *protoPrinter) messageBody(m Message) {
+	desc := m.Descriptor()
+
+	p.optionStatements(p.options(m, desc.GetOptions()))
+	p.reserved(m.ReservedRanges(), m.ReservedNames(), maxFieldNumber)
+
+	printed := make(map[int32]bool)
+	for _, f := range m.Fields() {
+		if !f.InRealOneOf() {
+			p.field(f, m)
+			continue
+		}
+
+		if idx := f.Descriptor().GetOneofIndex(); !printed[idx] {
+			printed[idx] = true
+			p.oneOf(f.OneOf())
+		}
+	}
+
+	for _, r := range desc.GetExtensionRange() {
+		opts := p.compactOptions(p.options(m, r.GetOptions()))
+		p.line("extensions ", rangeString(r.GetStart(), r.GetEnd()-1, maxFieldNumber), opts, ";")
+	}
+
+	for _, e := range m.Enums() {
+		p.enum(e)
+	}
+
+	for _, nested := range m.Messages() {
+		if p.isGroupBody(nested, m.Fields(), m.DefinedExtensions()) {
+			continue
+		}
+		p.message(nested)
+	}
+
+	p.extensions(m.DefinedExtensions())
+}
+
+func (p *protoPrinter) oneOf(o OneOf) {
+	p.leading(o.SourceCodeInfo())
+	p.open(o.SourceCodeInfo(), "oneof ", o.Descriptor().GetName())
+	p.optionStatements(p.options(o, o.Descriptor().GetOptions()))
+	for _, f := range o.Fields() {
+		p.field(f, o.Message())
+	}
+	p.close()
+}
+
+func (p *protoPrinter) field(f Field, m Message) {
+	fd := f.Descriptor()
+
+	var label string
+	switch {
+	case fd.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED && !p.isMap(fd, m):
+		label = "repeated "
+	case f.InRealOneOf():
+	case p.syntax == Proto3:
+		if fd.GetProto3Optional() {
+			label = "optional "
+		}
+	case p.syntax == Editions:
+	case fd.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		label = "required "
+	default:
+		label = "optional "
+	}
+
+	opts := p.compactOptions(append(fieldPseudoOptions(fd), p.options(f, fd.GetOptions())...))
+
+	if g := p.groupMessage(f); g != nil {
+		p.leading(f.SourceCodeInfo())
+		p.open(f.SourceCodeInfo(), label, "group ", g.Descriptor().GetName(), " = ", strconv.Itoa(int(fd.GetNumber())), opts)
+		p.messageBody(g)
+		p.close()
+		return
+	}
+
+	p.leading(f.SourceCodeInfo())
+	p.statement(f.SourceCodeInfo(), label, p.fieldType(fd, m), " ", fd.GetName(), " = ", strconv.Itoa(int(fd.GetNumber())), opts)
+}
+
+func (p *protoPrinter) fieldType(fd *descriptor.FieldDescriptorProto, m Message) string {
+	if entry := p.mapEntry(fd, m); entry != nil {
+		var key, val *descriptor.FieldDescriptorProto
+		for _, f := range entry.GetField() {
+			switch f.GetNumber() {
+			case 1:
+				key = f
+			case 2:
+				val = f
+			}
+		}
+		return "map<" + p.fieldType(key, nil) + ", " + p.fieldType(val, nil) + ">"
+	}
+
+	switch fd.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_ENUM,
+		descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// groups that cannot be printed inline (eg, delimited message fields in
+		// editions) are referenced like any other message
+		return fd.GetTypeName()
+	default:
+		return strings.ToLower(strings.TrimPrefix(fd.GetType().String(), "TYPE_"))
+	}
+}
+
+// groupMessage returns the message declared by f, if f is a proto2 group whose
+// message is nested in the same scope as f. Such groups are printed inline,
+// using the `group` syntax.
+func (p *protoPrinter) groupMessage(f Field) Message {
+	fd := f.Descriptor()
+	if p.syntax != Proto2 || fd.GetType() != descriptor.FieldDescriptorProto_TYPE_GROUP {
+		return nil
+	}
+
+	var scope ParentEntity = f.Message()
+	if ext, ok := f.(Extension); ok {
+		scope = ext.DefinedIn()
+	}
+
+	if scope == nil {
+		return nil
+	}
+
+	for _, m := range scope.Messages() {
+		if m.FullyQualifiedName() == fd.GetTypeName() {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// isGroupBody returns true if m is the message of a proto2 group declared by
+// one of fields or exts, in which case it is printed with the group instead
+// of as a nested message.
+func (p *protoPrinter) isGroupBody(m Message, fields []Field, exts []Extension) bool {
+	if p.syntax != Proto2 {
+		return false
+	}
+
+	for _, f := range fields {
+		if fd := f.Descriptor(); fd.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP && fd.GetTypeName() == m.FullyQualifiedName() {
+			return true
+		}
+	}
+
+	for _, e := range exts {
+		if fd := e.Descriptor(); fd.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP && fd.GetTypeName() == m.FullyQualifiedName() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *protoPrinter) isMap(fd *descriptor.FieldDescriptorProto, m Message) bool {
+	return p.mapEntry(fd, m) != nil
+}
+
+// mapEntry returns the map entry message for fd, if fd is a map field of m.
+func (p *protoPrinter) mapEntry(fd *descriptor.FieldDescriptorProto, m Message) *descriptor.DescriptorProto {
+	if m == nil ||
+		fd.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED ||
+		fd.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return nil
+	}
+
+	prefix := m.FullyQualifiedName() + "."
+	if !strings.HasPrefix(fd.GetTypeName(), prefix) {
+		return nil
+	}
+
+	name := strings.TrimPrefix(fd.GetTypeName(), prefix)
+	for _, nested := range m.Descriptor().GetNestedType() {
+		if nested.GetName() == name && nested.GetOptions().GetMapEntry() {
+			return nested
+		}
+	}
+
+	return nil
+}
+
+// fieldPseudoOptions returns the default and json_name options, which are
+// stored directly on the descriptor instead of its options.
+func fieldPseudoOptions(fd *descriptor.FieldDescriptorProto) (opts []protoOption) {
+	if fd.DefaultValue != nil {
+		val := fd.GetDefaultValue()
+		switch fd.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			val = quoteString(val)
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			val = `"` + val + `"` // protoc stores bytes defaults escaped
+		}
+		opts = append(opts, protoOption{name: "default", value: val})
+	}
+
+	if fd.JsonName != nil && fd.GetJsonName() != jsonName(fd.GetName()) {
+		opts = append(opts, protoOption{name: "json_name", value: quoteString(fd.GetJsonName())})
+	}
+
+	return opts
+}
+
+// jsonName returns the JSON name protoc derives for a field with name.
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (p *protoPrinter) extensions(exts []Extension) {
+	var order []string
+	byExtendee := make(map[string][]Extension)
+	for _, e := range exts {
+		extendee := e.Descriptor().GetExtendee()
+		if _, ok := byExtendee[extendee]; !ok {
+			order = append(order, extendee)
+		}
+		byExtendee[extendee] = append(byExtendee[extendee], e)
+	}
+
+	for _, extendee := range order {
+		if p.depth == 0 {
+			p.line()
+		}
+		p.extend(extendee, byExtendee[extendee])
+	}
+}
+
+func (p *protoPrinter) extend(extendee string, exts []Extension) {
+	p.line("extend ", extendee, " {")
+	p.depth++
+	for _, e := range exts {
+		p.field(e, nil)
+	}
+	p.close()
+}
+
+func (p *protoPrinter) enum(e Enum) {
+	desc := e.Descriptor()
+
+	p.leading(e.SourceCodeInfo())
+	p.open(e.SourceCodeInfo(), "enum ", desc.GetName())
+	p.optionStatements(p.options(e, desc.GetOptions()))
+
+	p.reserved(e.ReservedRanges(), e.ReservedNames(), maxEnumNumber)
+
+	for _, v := range e.Values() {
+		p.enumValue(v)
+	}
+	p.close()
+}
+
+func (p *protoPrinter) enumValue(v EnumValue) {
+	desc := v.Descriptor()
+	opts := p.compactOptions(p.options(v, desc.GetOptions()))
+
+	p.leading(v.SourceCodeInfo())
+	p.statement(v.SourceCodeInfo(), desc.GetName(), " = ", strconv.Itoa(int(desc.GetNumber())), opts)
+}
+
+// reserved writes the reserved statements for the ranges and names.
+func (p *protoPrinter) reserved(ranges []Range, names []Name, max int32) {
+	if len(ranges) > 0 {
+		rs := make([]string, len(ranges))
+		for i, r := range ranges {
+			rs[i] = rangeString(r.Start, r.End, max)
+		}
+		p.line("reserved ", strings.Join(rs, ", "), ";")
+	}
+
+	if len(names) > 0 {
+		ns := make([]string, len(names))
+		for i, n := range names {
+			ns[i] = quoteString(n.String())
+		}
+		p.line("reserved ", strings.Join(ns, ", "), ";")
+	}
+}
+
+// rangeString formats the inclusive range [start, end].
+func rangeString(start, end, max int32) string {
+	switch {
+	case start == end:
+		return strconv.Itoa(int(start))
+	case end == max:
+		return strconv.Itoa(int(start)) + " to max"
+	default:
+		return strconv.Itoa(int(start)) + " to " + strconv.Itoa(int(end))
+	}
+}
+
+func (p *protoPrinter) service(s Service) {
+	desc := s.Descriptor()
+
+	p.leading(s.SourceCodeInfo())
+	p.open(s.SourceCodeInfo(), "service ", desc.GetName())
+	p.optionStatements(p.options(s, desc.GetOptions()))
+	for _, m := range s.Methods() {
+		p.method(m)
+	}
+	p.close()
+}
+
+func (p *protoPrinter) method(m Method) {
+	desc := m.Descriptor()
+
+	in, out := desc.GetInputType(), desc.GetOutputType()
+	if desc.GetClientStreaming() {
+		in = "stream " + in
+	}
+	if desc.GetServerStreaming() {
+		out = "stream " + out
+	}
+
+	decl := []string{"rpc ", desc.GetName(), "(", in, ") returns (", out, ")"}
+	opts := p.options(m, desc.GetOptions())
+
+	p.leading(m.SourceCodeInfo())
+	if len(opts) == 0 {
+		p.statement(m.SourceCodeInfo(), decl...)
+		return
+	}
+
+	p.open(m.SourceCodeInfo(), decl...)
+	p.optionStatements(opts)
+	p.close()
+}
+
+// protoOption is a single option name and its formatted value.
+type protoOption struct {
+	name, value string
+}
+
+func (p *protoPrinter) optionStatements(opts []protoOption) {
+	for _, o := range opts {
+		p.line("option ", o.name, " = ", o.value, ";")
+	}
+}
+
+func (p *protoPrinter) compactOptions(opts []protoOption) string {
+	if len(opts) == 0 {
+		return ""
+	}
+
+	out := make([]string, len(opts))
+	for i, o := range opts {
+		out[i] = o.name + " = " + o.value
+	}
+	return " [" + strings.Join(out, ", ") + "]"
+}
+
+// options returns the options set on the options message opts for entity e,
+// ordered by field number followed by extensions ordered by name.
+func (p *protoPrinter) options(e Entity, opts protoreflect.ProtoMessage) (out []protoOption) {
+	if opts == nil {
+		return nil
+	}
+
+	m := opts.ProtoReflect()
+	if !m.IsValid() {
+		return nil
+	}
+
+	if hasUnknown(m) {
+		m = p.resolveOptions(e, m)
+	}
+
+	for _, fd := range sortedFields(m) {
+		v := m.Get(fd)
+
+		var name string
+		if fd.IsExtension() {
+			name = "(" + string(fd.FullName()) + ")"
+		} else {
+			name = string(fd.Name())
+		}
+
+		for _, o := range flattenOption(name, fd, v) {
+			if p.OptionFilter == nil || p.OptionFilter(e, o.name) {
+				out = append(out, o)
+			}
+		}
+	}
+
+	return out
+}
+
+// resolveOptions decodes the unknown fields of the options message m of entity
+// e using the extensions declared in the AST of e. If some fields still cannot
+// be decoded, they are reported and dropped.
+func (p *protoPrinter) resolveOptions(e Entity, m protoreflect.Message) protoreflect.Message {
+	reg, _ := entityRegistry(e)
+	if p.types == nil && reg != nil {
+		if files, err := reg.Files(); err == nil {
+			p.types = dynamicpb.NewTypes(files)
+		}
+	}
+
+	if p.types != nil {
+		if b, err := proto.Marshal(m.Interface()); err == nil {
+			resolved := m.New()
+			if err = (proto.UnmarshalOptions{Resolver: p.types}).Unmarshal(b, resolved.Interface()); err == nil {
+				m = resolved
+			}
+		}
+	}
+
+	if hasUnknown(m) {
+		name := e.FullyQualifiedName()
+		if f, ok := e.(File); ok {
+			name = f.Name().String()
+		}
+		p.unresolved = append(p.unresolved, name)
+		if reg != nil && reg.d != nil {
+			reg.d.Logf("%s: omitting options that cannot be decoded", name)
+		}
+	}
+
+	return m
+}
+
+// hasUnknown returns true if m or any message nested within it has unknown
+// fields.
+func hasUnknown(m protoreflect.Message) (found bool) {
+	if len(m.GetUnknown()) > 0 {
+		return true
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					found = hasUnknown(mv.Message())
+					return !found
+				})
+			}
+		case fd.Message() == nil:
+		case fd.IsList():
+			for l, i := v.List(), 0; i < l.Len() && !found; i++ {
+				found = hasUnknown(l.Get(i).Message())
+			}
+		default:
+			found = hasUnknown(v.Message())
+		}
+		return !found
+	})
+
+	return found
+}
+
+// flattenOption formats the option value v. Singular message fields that are
+// not extensions are expanded into a dotted option name per set field (eg,
+// `features.field_presence = IMPLICIT`). Repeated values produce an option
+// per element.
+func flattenOption(name string, fd protoreflect.FieldDescriptor, v protoreflect.Value) (out []protoOption) {
+	switch {
+	case fd.IsList():
+		l := v.List()
+		for i := 0; i < l.Len(); i++ {
+			out = append(out, protoOption{name: name, value: formatValue(fd, l.Get(i))})
+		}
+	case fd.Message() != nil && !fd.IsMap() && !fd.IsExtension():
+		m := v.Message()
+		for _, f := range sortedFields(m) {
+			n := string(f.Name())
+			if f.IsExtension() {
+				n = "(" + string(f.FullName()) + ")"
+			}
+			out = append(out, flattenOption(name+"."+n, f, m.Get(f))...)
+		}
+	default:
+		out = append(out, protoOption{name: name, value: formatValue(fd, v)})
+	}
+	return out
+}
+
+// sortedFields returns the populated fields of m, ordered by number, followed
+// by extensions ordered by name.
+func sortedFields(m protoreflect.Message) []protoreflect.FieldDescriptor {
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.IsExtension() != b.IsExtension() {
+			return !a.IsExtension()
+		}
+		if a.IsExtension() {
+			return a.FullName() < b.FullName()
+		}
+		return a.Number() < b.Number()
+	})
+
+	return fields
+}
+
+// formatValue formats a singular value of the field fd using the protobuf
+// text format syntax accepted by protoc.
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	case protoreflect.FloatKind:
+		return formatFloat(v.Float(), 32)
+	case protoreflect.DoubleKind:
+		return formatFloat(v.Float(), 64)
+	case protoreflect.StringKind:
+		return quoteString(v.String())
+	case protoreflect.BytesKind:
+		return quoteBytes(v.Bytes())
+	default:
+		return formatMessage(v.Message())
+	}
+}
+
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, bits)
+	}
+}
+
+// formatMessage formats m as a text format aggregate value.
+func formatMessage(m protoreflect.Message) string {
+	var fields []string
+	for _, fd := range sortedFields(m) {
+		name := string(fd.Name())
+		if fd.IsExtension() {
+			name = "[" + string(fd.FullName()) + "]"
+		}
+
+		v := m.Get(fd)
+		switch {
+		case fd.IsMap():
+			fields = append(fields, name+": ["+strings.Join(formatMap(fd, v.Map()), ", ")+"]")
+		case fd.IsList():
+			l := v.List()
+			vals := make([]string, l.Len())
+			for i := range vals {
+				vals[i] = formatValue(fd, l.Get(i))
+			}
+			fields = append(fields, name+": ["+strings.Join(vals, ", ")+"]")
+		default:
+			fields = append(fields, name+": "+formatValue(fd, v))
+		}
+	}
+
+	if len(fields) == 0 {
+		return "{}"
+	}
+
+	return "{ " + strings.Join(fields, " ") + " }"
+}
+
+func formatMap(fd protoreflect.FieldDescriptor, m protoreflect.Map) []string {
+	var entries []string
+	m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		entries = append(entries, "{ key: "+formatValue(fd.MapKey(), k.Value())+
+			" value: "+formatValue(fd.MapValue(), v)+" }")
+		return true
+	})
+	sort.Strings(entries)
+	return entries
+}
+
+// quoteString returns s as a double-quoted proto string literal. Printable
+// characters are retained, while all others are escaped.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			writeEscapedByte(&b, s[i])
+		case r >= utf8.RuneSelf && unicode.IsPrint(r):
+			b.WriteString(s[i : i+size])
+		default:
+			for j := 0; j < size; j++ {
+				writeEscapedByte(&b, s[i+j])
+			}
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quoteBytes returns b as a double-quoted proto string literal, escaping all
+// non-printable ASCII bytes.
+func quoteBytes(b []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range b {
+		writeEscapedByte(&sb, c)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func writeEscapedByte(b *strings.Builder, c byte) {
+	switch c {
+	case '\n':
+		b.WriteString(`\n`)
+	case '\r':
+		b.WriteString(`\r`)
+	case '\t':
+		b.WriteString(`\t`)
+	case '"':
+		b.WriteString(`\"`)
+	case '\'':
+		b.WriteString(`\'`)
+	case '\\':
+		b.WriteString(`\\`)
+	default:
+		if c < 0x20 || c >= 0x7f {
+			b.WriteByte('\\')
+			b.WriteByte('0' + c>>6)
+			b.WriteByte('0' + (c>>3)&7)
+			b.WriteByte('0' + c&7)
+			return
+		}
+		b.WriteByte(c)
+	}
+}
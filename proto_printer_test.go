@@ -0,0 +1,595 @@
+package pgs
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func printerAST(t *testing.T, files ...*descriptor.FileDescriptorProto) AST {
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{files[len(files)-1].GetName()},
+		ProtoFile:      files,
+	})
+	require.False(t, d.Failed())
+	return ast
+}
+
+func printerField(name string, num int32, typ descriptor.FieldDescriptorProto_Type, lbl descriptor.FieldDescriptorProto_Label) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(num),
+		Type:     typ.Enum(),
+		Label:    lbl.Enum(),
+		JsonName: proto.String(jsonName(name)),
+	}
+}
+
+func proto3PrinterFile() *descriptor.FileDescriptorProto {
+	opt := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	rep := descriptor.FieldDescriptorProto_LABEL_REPEATED
+
+	str := printerField("string_field", 1, descriptor.FieldDescriptorProto_TYPE_STRING, opt)
+	str.JsonName = proto.String("custom")
+	str.Options = &descriptor.FieldOptions{Deprecated: proto.Bool(true)}
+
+	enm := printerField("enum_field", 2, descriptor.FieldDescriptorProto_TYPE_ENUM, opt)
+	enm.TypeName = proto.String(".foo.Enum")
+
+	mp := printerField("map_field", 3, descriptor.FieldDescriptorProto_TYPE_MESSAGE, rep)
+	mp.TypeName = proto.String(".foo.Msg.MapFieldEntry")
+
+	optional := printerField("optional_field", 4, descriptor.FieldDescriptorProto_TYPE_INT64, opt)
+	optional.Proto3Optional = proto.Bool(true)
+	optional.OneofIndex = proto.Int32(1)
+
+	a := printerField("a", 5, descriptor.FieldDescriptorProto_TYPE_BYTES, opt)
+	a.OneofIndex = proto.Int32(0)
+	b := printerField("b", 6, descriptor.FieldDescriptorProto_TYPE_MESSAGE, opt)
+	b.TypeName = proto.String(".foo.Msg")
+	b.OneofIndex = proto.Int32(0)
+
+	key := printerField("key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, opt)
+	val := printerField("value", 2, descriptor.FieldDescriptorProto_TYPE_MESSAGE, opt)
+	val.TypeName = proto.String(".foo.Msg")
+
+	return &descriptor.FileDescriptorProto{
+		Name:             proto.String("foo/foo.proto"),
+		Package:          proto.String("foo"),
+		Syntax:           proto.String("proto3"),
+		Dependency:       []string{"bar.proto", "baz.proto"},
+		PublicDependency: []int32{1},
+		Options: &descriptor.FileOptions{
+			GoPackage:         proto.String("example.com/foo"),
+			JavaMultipleFiles: proto.Bool(true),
+		},
+		MessageType: []*descriptor.DescriptorProto{{
+			Name:  proto.String("Msg"),
+			Field: []*descriptor.FieldDescriptorProto{str, enm, mp, optional, a, b},
+			NestedType: []*descriptor.DescriptorProto{{
+				Name:    proto.String("MapFieldEntry"),
+				Field:   []*descriptor.FieldDescriptorProto{key, val},
+				Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+			OneofDecl: []*descriptor.OneofDescriptorProto{
+				{Name: proto.String("oo")},
+				{Name: proto.String("_optional_field")},
+			},
+			ReservedRange: []*descriptor.DescriptorProto_ReservedRange{
+				{Start: proto.Int32(10), End: proto.Int32(11)},
+				{Start: proto.Int32(20), End: proto.Int32(30)},
+				{Start: proto.Int32(1000), End: proto.Int32(maxFieldNumber + 1)},
+			},
+			ReservedName: []string{"foo", "bar"},
+		}},
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name: proto.String("Enum"),
+			Value: []*descriptor.EnumValueDescriptorProto{
+				{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("VALUE"), Number: proto.Int32(1), Options: &descriptor.EnumValueOptions{Deprecated: proto.Bool(true)}},
+			},
+			ReservedRange: []*descriptor.EnumDescriptorProto_EnumReservedRange{
+				{Start: proto.Int32(2), End: proto.Int32(2)},
+				{Start: proto.Int32(5), End: proto.Int32(maxEnumNumber)},
+			},
+		}},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Svc"),
+			Method: []*descriptor.MethodDescriptorProto{
+				{
+					Name:       proto.String("Unary"),
+					InputType:  proto.String(".foo.Msg"),
+					OutputType: proto.String(".foo.Msg"),
+				},
+				{
+					Name:            proto.String("Stream"),
+					InputType:       proto.String(".foo.Msg"),
+					OutputType:      proto.String(".foo.Msg"),
+					ClientStreaming: proto.Bool(true),
+					ServerStreaming: proto.Bool(true),
+					Options: &descriptor.MethodOptions{
+						IdempotencyLevel: descriptor.MethodOptions_NO_SIDE_EFFECTS.Enum(),
+					},
+				},
+			},
+		}},
+		SourceCodeInfo: &descriptor.SourceCodeInfo{Location: []*descriptor.SourceCodeInfo_Location{
+			{
+				Path:                    []int32{12},
+				Span:                    []int32{0, 0, 18},
+				LeadingDetachedComments: []string{" Copyright\n"},
+			},
+			{
+				Path:            []int32{4, 0},
+				Span:            []int32{4, 0, 20, 1},
+				LeadingComments: proto.String(" Msg is a message.\n Second line.\n"),
+			},
+			{
+				Path:             []int32{4, 0, 2, 0},
+				Span:             []int32{5, 2, 30},
+				TrailingComments: proto.String(" trailing\n"),
+			},
+		}},
+	}
+}
+
+func printerDeps() []*descriptor.FileDescriptorProto {
+	return []*descriptor.FileDescriptorProto{
+		{Name: proto.String("bar.proto"), Syntax: proto.String("proto3")},
+		{Name: proto.String("baz.proto"), Syntax: proto.String("proto3")},
+	}
+}
+
+func TestProtoPrinter_Proto3(t *testing.T) {
+	t.Parallel()
+
+	ast := printerAST(t, append(printerDeps(), proto3PrinterFile())...)
+	f := ast.Targets()["foo/foo.proto"]
+	require.NotNil(t, f)
+
+	assert.Equal(t, `// Copyright
+
+syntax = "proto3";
+
+package foo;
+
+import "bar.proto";
+import public "baz.proto";
+
+option java_multiple_files = true;
+option go_package = "example.com/foo";
+
+enum Enum {
+  reserved 2, 5 to max;
+  UNKNOWN = 0;
+  VALUE = 1 [deprecated = true];
+}
+
+// Msg is a message.
+// Second line.
+message Msg {
+  reserved 10, 20 to 29, 1000 to max;
+  reserved "foo", "bar";
+  string string_field = 1 [json_name = "custom", deprecated = true]; // trailing
+  .foo.Enum enum_field = 2;
+  map<string, .foo.Msg> map_field = 3;
+  optional int64 optional_field = 4;
+  oneof oo {
+    bytes a = 5;
+    .foo.Msg b = 6;
+  }
+}
+
+service Svc {
+  rpc Unary(.foo.Msg) returns (.foo.Msg);
+  rpc Stream(stream .foo.Msg) returns (stream .foo.Msg) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+}
+`, ProtoPrinter{}.Sprint(f))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, ProtoPrinter{Indent: "\t", OmitComments: true}.Print(buf, f.Messages()[0]))
+	assert.True(t, strings.HasPrefix(buf.String(), "message Msg {\n\treserved 10"))
+	assert.NotContains(t, buf.String(), "//")
+}
+
+func TestProtoPrinter_Proto2(t *testing.T) {
+	t.Parallel()
+
+	opt := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	req := descriptor.FieldDescriptorProto_LABEL_REQUIRED
+
+	def := printerField("def", 1, descriptor.FieldDescriptorProto_TYPE_STRING, opt)
+	def.DefaultValue = proto.String("a\"b")
+
+	bts := printerField("bts", 2, descriptor.FieldDescriptorProto_TYPE_BYTES, req)
+	bts.DefaultValue = proto.String(`\000\001`)
+
+	nested := printerField("nested", 3, descriptor.FieldDescriptorProto_TYPE_MESSAGE, opt)
+	nested.TypeName = proto.String(".foo.Msg.Nested")
+
+	ext := printerField("ext", 100, descriptor.FieldDescriptorProto_TYPE_INT32, opt)
+	ext.Extendee = proto.String(".foo.Msg")
+
+	ast := printerAST(t, &descriptor.FileDescriptorProto{
+		Name:    proto.String("foo.proto"),
+		Package: proto.String("foo"),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name:  proto.String("Msg"),
+			Field: []*descriptor.FieldDescriptorProto{def, bts, nested},
+			NestedType: []*descriptor.DescriptorProto{{
+				Name:  proto.String("Nested"),
+				Field: []*descriptor.FieldDescriptorProto{printerField("x", 1, descriptor.FieldDescriptorProto_TYPE_BOOL, opt)},
+			}},
+			ExtensionRange: []*descriptor.DescriptorProto_ExtensionRange{
+				{Start: proto.Int32(100), End: proto.Int32(200)},
+			},
+		}},
+		Extension: []*descriptor.FieldDescriptorProto{ext},
+	})
+
+	f := ast.Targets()["foo.proto"]
+	require.NotNil(t, f)
+
+	assert.Equal(t, `syntax = "proto2";
+
+package foo;
+
+message Msg {
+  optional string def = 1 [default = "a\"b"];
+  required bytes bts = 2 [default = "\000\001"];
+  optional .foo.Msg.Nested nested = 3;
+  extensions 100 to 199;
+  message Nested {
+    optional bool x = 1;
+  }
+}
+
+extend .foo.Msg {
+  optional int32 ext = 100;
+}
+`, ProtoPrinter{}.Sprint(f))
+
+	assert.Equal(t, "extend .foo.Msg {\n  optional int32 ext = 100;\n}\n", ProtoPrinter{}.Sprint(f.DefinedExtensions()[0]))
+	assert.Equal(t, "optional string def = 1 [default = \"a\\\"b\"];\n", ProtoPrinter{}.Sprint(f.Messages()[0].Fields()[0]))
+}
+
+func TestProtoPrinter_Groups(t *testing.T) {
+	t.Parallel()
+
+	src := `syntax = "proto2";
+package foo;
+message Msg {
+  optional group Result = 1 {
+    required string url = 2;
+    repeated group Inner = 3 { optional int32 x = 4; }
+  }
+  oneof o { group Choice = 5 { optional bool b = 6; } }
+  extensions 100 to 199;
+}
+extend Msg { optional group Ext = 100 { optional string s = 101; } }
+`
+
+	req := compileRequest(t, map[string]string{"foo.proto": src}, "foo.proto")
+	fd := stripSourceInfo(req.GetProtoFile()[0])
+
+	// group fields are not hydrated by the AST, so they are processed as
+	// message fields and restored afterwards, as the printer only relies on
+	// their descriptors
+	groups := groupFields(fd)
+	for _, fld := range groups {
+		fld.Type = descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	}
+
+	f := printerAST(t, fd).Targets()["foo.proto"]
+	for _, fld := range groups {
+		fld.Type = descriptor.FieldDescriptorProto_TYPE_GROUP.Enum()
+	}
+
+	printed := ProtoPrinter{}.Sprint(f)
+	assert.Equal(t, `syntax = "proto2";
+
+package foo;
+
+message Msg {
+  optional group Result = 1 {
+    required string url = 2;
+    repeated group Inner = 3 {
+      optional int32 x = 4;
+    }
+  }
+  oneof o {
+    group Choice = 5 {
+      optional bool b = 6;
+    }
+  }
+  extensions 100 to 199;
+}
+
+extend .foo.Msg {
+  optional group Ext = 100 {
+    optional string s = 101;
+  }
+}
+`, printed)
+
+	// the printed source compiles to the same descriptors
+	reprinted := compileRequest(t, map[string]string{"foo.proto": printed}, "foo.proto")
+	assert.True(t, proto.Equal(fd, stripSourceInfo(reprinted.GetProtoFile()[0])))
+}
+
+// groupFields returns the group fields and extensions declared in fd.
+func groupFields(fd *descriptor.FileDescriptorProto) (out []*descriptor.FieldDescriptorProto) {
+	collect := func(flds []*descriptor.FieldDescriptorProto) {
+		for _, fld := range flds {
+			if fld.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+				out = append(out, fld)
+			}
+		}
+	}
+
+	var msgs func([]*descriptor.DescriptorProto)
+	msgs = func(mds []*descriptor.DescriptorProto) {
+		for _, md := range mds {
+			collect(md.GetField())
+			collect(md.GetExtension())
+			msgs(md.GetNestedType())
+		}
+	}
+
+	collect(fd.GetExtension())
+	msgs(fd.GetMessageType())
+
+	return out
+}
+
+func stripSourceInfo(fd *descriptor.FileDescriptorProto) *descriptor.FileDescriptorProto {
+	fd = proto.Clone(fd).(*descriptor.FileDescriptorProto)
+	fd.SourceCodeInfo = nil
+	return fd
+}
+
+func TestProtoPrinter_Editions(t *testing.T) {
+	t.Parallel()
+
+	fld := printerField("foo", 1, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL)
+	fld.Options = &descriptor.FieldOptions{Features: &descriptor.FeatureSet{
+		FieldPresence: descriptor.FeatureSet_IMPLICIT.Enum(),
+	}}
+
+	ast := printerAST(t, &descriptor.FileDescriptorProto{
+		Name:    proto.String("foo.proto"),
+		Syntax:  proto.String("editions"),
+		Edition: descriptor.Edition_EDITION_2023.Enum(),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name:  proto.String("Msg"),
+			Field: []*descriptor.FieldDescriptorProto{fld},
+		}},
+	})
+
+	f := ast.Targets()["foo.proto"]
+	require.NotNil(t, f)
+
+	assert.Equal(t, `edition = "2023";
+
+message Msg {
+  string foo = 1 [features.field_presence = IMPLICIT];
+}
+`, ProtoPrinter{}.Sprint(f))
+}
+
+func TestProtoPrinter_Options(t *testing.T) {
+	t.Parallel()
+
+	ast := printerAST(t, append(printerDeps(), proto3PrinterFile())...)
+	f := ast.Targets()["foo/foo.proto"]
+	require.NotNil(t, f)
+
+	out := ProtoPrinter{
+		OmitComments: true,
+		OptionFilter: func(e Entity, name string) bool { return name != "go_package" && name != "deprecated" },
+		FileImports:  []string{"bar.proto", "gogo.proto"},
+		FileOptions:  []string{"(gogoproto.marshaler_all) = true"},
+	}.Sprint(f)
+
+	assert.Contains(t, out, "import \"gogo.proto\";\n\noption java_multiple_files = true;\noption (gogoproto.marshaler_all) = true;\n")
+	assert.Equal(t, 1, strings.Count(out, `import "bar.proto";`))
+	assert.NotContains(t, out, "go_package")
+	assert.NotContains(t, out, "deprecated")
+}
+
+func TestProtoPrinter_Values(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `"a\n\"b\"\\ é \001"`, quoteString("a\n\"b\"\\ é \x01"))
+	assert.Equal(t, `"\377\000z"`, quoteString("\xff\x00z"))
+	assert.Equal(t, `"\303\251"`, quoteBytes([]byte("é")))
+
+	assert.Equal(t, "inf", formatFloat(math.Inf(1), 64))
+	assert.Equal(t, "-inf", formatFloat(math.Inf(-1), 64))
+	assert.Equal(t, "nan", formatFloat(math.NaN(), 64))
+	assert.Equal(t, "1.5", formatFloat(1.5, 32))
+
+	opts := &descriptorpb.FieldOptions{
+		Targets:  []descriptorpb.FieldOptions_OptionTargetType{descriptorpb.FieldOptions_TARGET_TYPE_FILE},
+		Features: &descriptorpb.FeatureSet{EnumType: descriptorpb.FeatureSet_OPEN.Enum()},
+	}
+	m := opts.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName("features")
+	assert.Equal(t, "{ enum_type: OPEN }", formatValue(fd, m.Get(fd)))
+
+	var names []string
+	for _, f := range sortedFields(m) {
+		names = append(names, string(f.Name()))
+		for _, o := range flattenOption(string(f.Name()), f, m.Get(f)) {
+			names = append(names, o.name+"="+o.value)
+		}
+	}
+	assert.Equal(t, []string{
+		"targets", "targets=TARGET_TYPE_FILE",
+		"features", "features.enum_type=OPEN",
+	}, names)
+
+	assert.Equal(t, "{}", formatMessage((&descriptorpb.FeatureSet{}).ProtoReflect()))
+	assert.Equal(t, protoreflect.Name("features"), fd.Name())
+}
+
+func TestJSONName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "fooBarBaz", jsonName("foo_bar_baz"))
+	assert.Equal(t, "fooBar", jsonName("foo__bar"))
+	assert.Equal(t, "FooBar", jsonName("_foo_bar"))
+}
+
+func TestProtoPrinter_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	sources := map[string]string{
+		"opts/opts.proto": `syntax = "proto3";
+package opts;
+
+import "google/protobuf/descriptor.proto";
+
+message Rule {
+  string expr = 1;
+  repeated int32 codes = 2;
+}
+
+extend google.protobuf.FileOptions { string owner = 50000; }
+extend google.protobuf.MessageOptions { Rule rule = 50000; }
+extend google.protobuf.FieldOptions { repeated string tags = 50000; }
+extend google.protobuf.EnumValueOptions { bool hidden = 50000; }
+extend google.protobuf.MethodOptions { int64 cost = 50000; }
+`,
+		"foo/foo.proto": `syntax = "proto3";
+package foo;
+
+import "opts/opts.proto";
+
+option (opts.owner) = "team";
+option java_package = "com.foo";
+
+// Msg is a message.
+message Msg {
+  option (opts.rule) = { expr: "x > 0" codes: [1, 2] };
+
+  reserved 10 to 12;
+  reserved "old";
+
+  // name of the message
+  string name = 1 [(opts.tags) = "a", (opts.tags) = "b", json_name = "nom"];
+  optional int32 maybe = 2;
+  map<string, Nested> nested = 3;
+
+  oneof choice {
+    Kind kind = 4;
+    bytes raw = 5 [deprecated = true];
+  }
+
+  message Nested {}
+}
+
+enum Kind {
+  KIND_UNSPECIFIED = 0;
+  KIND_SECRET = 1 [(opts.hidden) = true];
+}
+
+service Svc {
+  rpc Do(Msg) returns (stream Msg.Nested) {
+    option (opts.cost) = 3;
+  }
+}
+`,
+	}
+
+	// decode the request as protoc would send it to a plugin without the
+	// custom options linked in
+	b, err := proto.Marshal(compileRequest(t, sources, "foo/foo.proto"))
+	require.NoError(t, err)
+	req := &plugin_go.CodeGeneratorRequest{}
+	require.NoError(t, proto.Unmarshal(b, req))
+
+	d := InitMockDebugger()
+	f := ProcessCodeGeneratorRequest(d, req).Targets()["foo/foo.proto"]
+	require.False(t, d.Failed())
+
+	var buf bytes.Buffer
+	require.NoError(t, ProtoPrinter{}.Print(&buf, f))
+	assert.Contains(t, buf.String(), `option (opts.owner) = "team";`)
+	assert.Contains(t, buf.String(), `(opts.tags) = "a", (opts.tags) = "b"`)
+
+	sources["foo/foo.proto"] = buf.String()
+	reprinted := compileRequest(t, sources, "foo/foo.proto")
+
+	// both files are decoded with the same extension types, so their options
+	// are comparable
+	files, err := protodesc.NewFiles(&descriptor.FileDescriptorSet{File: req.GetProtoFile()})
+	require.NoError(t, err)
+	types := dynamicpb.NewTypes(files)
+
+	assert.True(t, proto.Equal(
+		resolvedFile(t, types, req, "foo/foo.proto"),
+		resolvedFile(t, types, reprinted, "foo/foo.proto"),
+	), buf.String())
+}
+
+func TestProtoPrinter_UnresolvedOptions(t *testing.T) {
+	t.Parallel()
+
+	fd := proto3PrinterFile()
+	fd.Options = &descriptor.FileOptions{}
+	// field 50000, varint 1, is not declared by any file in the request
+	fd.Options.ProtoReflect().SetUnknown(protoreflect.RawFields{0x80, 0xb5, 0x18, 0x01})
+
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{fd.GetName()},
+		ProtoFile:      append(printerDeps(), fd),
+	})
+	require.False(t, d.Failed())
+	f := ast.Targets()[fd.GetName()]
+
+	var buf bytes.Buffer
+	assert.EqualError(t, ProtoPrinter{}.Print(&buf, f), "unable to decode options of foo/foo.proto")
+	assert.Zero(t, buf.Len())
+
+	assert.NotEmpty(t, ProtoPrinter{}.Sprint(f))
+	out, _ := io.ReadAll(d.Output())
+	assert.Contains(t, string(out), "foo/foo.proto: omitting options that cannot be decoded")
+}
+
+// resolvedFile returns the descriptor of the named file in req without source
+// info, with its custom options decoded using types.
+func resolvedFile(t *testing.T, types *dynamicpb.Types, req *plugin_go.CodeGeneratorRequest, name string) *descriptor.FileDescriptorProto {
+	for _, fd := range req.GetProtoFile() {
+		if fd.GetName() != name {
+			continue
+		}
+
+		b, err := proto.Marshal(stripSourceInfo(fd))
+		require.NoError(t, err)
+
+		out := &descriptor.FileDescriptorProto{}
+		require.NoError(t, protov2.UnmarshalOptions{Resolver: types}.Unmarshal(b, out))
+		return out
+	}
+
+	require.FailNow(t, "file not found", name)
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
 )
 
@@ -41,7 +42,15 @@ func main() {
 		log.Fatal("unable to write request to disk: ", err)
 	}
 
-	data, err = proto.Marshal(&plugin_go.CodeGeneratorResponse{})
+	// advertise support for proto3 optional fields and editions, otherwise
+	// protoc refuses to execute the plugin against those files.
+	data, err = proto.Marshal(&plugin_go.CodeGeneratorResponse{
+		SupportedFeatures: proto.Uint64(uint64(
+			plugin_go.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL |
+				plugin_go.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)),
+		MinimumEdition: proto.Int32(int32(descriptor.Edition_EDITION_PROTO2)),
+		MaximumEdition: proto.Int32(int32(descriptor.Edition_EDITION_2023)),
+	})
 	if err != nil {
 		log.Fatal("unable to marshal response payload: ", err)
 	}
@@ -0,0 +1,65 @@
+package pgs
+
+import (
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Range describes a contiguous span of field or enum value numbers. Unlike the
+// underlying descriptors, which mix exclusive and inclusive ends, End is always
+// inclusive.
+type Range struct {
+	Start, End int32
+}
+
+// Contains returns true if n falls within the Range.
+func (r Range) Contains(n int32) bool { return n >= r.Start && n <= r.End }
+
+// IsValidFieldNumber returns true if n may be used as a field number: it is
+// positive, no larger than the maximum field number, and does not fall within
+// the range reserved for the protobuf implementation.
+func IsValidFieldNumber(n int32) bool {
+	num := protowire.Number(n)
+	return num.IsValid() && (num < protowire.FirstReservedNumber || num > protowire.LastReservedNumber)
+}
+
+func rangesContain(rs []Range, n int32) bool {
+	for _, r := range rs {
+		if r.Contains(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func messageReservedRanges(rs []*descriptor.DescriptorProto_ReservedRange) []Range {
+	out := make([]Range, len(rs))
+	for i, r := range rs {
+		out[i] = Range{Start: r.GetStart(), End: r.GetEnd() - 1}
+	}
+	return out
+}
+
+func extensionRanges(rs []*descriptor.DescriptorProto_ExtensionRange) []Range {
+	out := make([]Range, len(rs))
+	for i, r := range rs {
+		out[i] = Range{Start: r.GetStart(), End: r.GetEnd() - 1}
+	}
+	return out
+}
+
+func enumReservedRanges(rs []*descriptor.EnumDescriptorProto_EnumReservedRange) []Range {
+	out := make([]Range, len(rs))
+	for i, r := range rs {
+		out[i] = Range{Start: r.GetStart(), End: r.GetEnd()}
+	}
+	return out
+}
+
+func reservedNames(ns []string) []Name {
+	out := make([]Name, len(ns))
+	for i, n := range ns {
+		out[i] = Name(n)
+	}
+	return out
+}
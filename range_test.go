@@ -0,0 +1,30 @@
+package pgs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRange_Contains(t *testing.T) {
+	t.Parallel()
+
+	r := Range{Start: 5, End: 10}
+	assert.False(t, r.Contains(4))
+	assert.True(t, r.Contains(5))
+	assert.True(t, r.Contains(10))
+	assert.False(t, r.Contains(11))
+
+	assert.True(t, Range{Start: 1, End: 1}.Contains(1))
+}
+
+func TestIsValidFieldNumber(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, IsValidFieldNumber(0))
+	assert.True(t, IsValidFieldNumber(1))
+	assert.False(t, IsValidFieldNumber(19000))
+	assert.False(t, IsValidFieldNumber(19999))
+	assert.True(t, IsValidFieldNumber(536870911))
+	assert.False(t, IsValidFieldNumber(536870912))
+}
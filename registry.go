@@ -0,0 +1,84 @@
+package pgs
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// registry builds the protoreflect descriptors of every file in an AST on
+// first use, shared by all of its entities.
+type registry struct {
+	d     Debugger
+	mu    sync.Mutex
+	fds   []*descriptor.FileDescriptorProto
+	files *protoregistry.Files
+	err   error
+}
+
+func newRegistry(d Debugger, fds []*descriptor.FileDescriptorProto) *registry {
+	return &registry{d: d, fds: fds}
+}
+
+// Files returns the resolved descriptors of the AST's files. A failure to
+// resolve them is logged once each time they are built.
+func (r *registry) Files() (*protoregistry.Files, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.files == nil && r.err == nil {
+		r.files, r.err = protodesc.NewFiles(&descriptor.FileDescriptorSet{File: r.fds})
+		if r.err != nil && r.d != nil {
+			r.d.Log("unable to resolve protoreflect descriptors:", r.err)
+		}
+	}
+
+	return r.files, r.err
+}
+
+// reset discards the resolved descriptors, which are rebuilt on next use. This
+// must be called whenever the AST's descriptors change.
+func (r *registry) reset() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files, r.err = nil, nil
+}
+
+// findDescriptor returns the descriptor with the fully-qualified name of e, or
+// nil if it cannot be resolved.
+func findDescriptor(e Entity) protoreflect.Descriptor {
+	reg, err := entityRegistry(e)
+	if err != nil {
+		return nil
+	}
+
+	files, err := reg.Files()
+	if err != nil {
+		return nil
+	}
+
+	d, _ := files.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(e.FullyQualifiedName(), ".")))
+	return d
+}
+
+// entityRegistry returns the registry of the AST containing e.
+func entityRegistry(e Entity) (*registry, error) {
+	if e == nil {
+		return nil, errors.New("entity is nil")
+	}
+
+	if f, ok := e.File().(*file); ok && f.reg != nil {
+		return f.reg, nil
+	}
+
+	return nil, errors.New("entity is not part of an AST")
+}
@@ -0,0 +1,190 @@
+package pgs
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var reflectSources = map[string]string{
+	"foo/foo.proto": `syntax = "proto3";
+package foo;
+
+message Foo {
+  message Nested {}
+
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+    KIND_BAR = 1;
+  }
+
+  oneof choice {
+    string a = 1;
+    int32 b = 2;
+  }
+
+  optional string maybe = 3;
+  map<string, Nested> nested = 4;
+  Kind kind = 5;
+}
+
+service Svc {
+  rpc Do(Foo) returns (Foo.Nested);
+}
+`,
+	"bar/bar.proto": `syntax = "proto2";
+package bar;
+
+import "foo/foo.proto";
+
+message Bar {
+  extensions 100 to 200;
+  optional foo.Foo foo = 1;
+}
+
+extend Bar {
+  optional string tag = 100;
+}
+`,
+}
+
+func TestEntity_ReflectDescriptor(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, compileRequest(t, reflectSources, "bar/bar.proto"))
+	require.False(t, d.Failed())
+
+	lookup := func(name string) Entity {
+		e, ok := ast.Lookup(name)
+		require.True(t, ok, name)
+		return e
+	}
+
+	tests := []struct {
+		name     string
+		desc     func() protoreflect.Descriptor
+		fullName protoreflect.FullName
+	}{
+		{"file", func() protoreflect.Descriptor { return lookup("foo/foo.proto").(File).ReflectDescriptor() }, "foo"},
+		{"message", func() protoreflect.Descriptor { return lookup(".foo.Foo").(Message).ReflectDescriptor() }, "foo.Foo"},
+		{"nested message", func() protoreflect.Descriptor { return lookup(".foo.Foo.Nested").(Message).ReflectDescriptor() }, "foo.Foo.Nested"},
+		{"map entry", func() protoreflect.Descriptor { return lookup(".foo.Foo.NestedEntry").(Message).ReflectDescriptor() }, "foo.Foo.NestedEntry"},
+		{"field", func() protoreflect.Descriptor { return lookup(".foo.Foo.a").(Field).ReflectDescriptor() }, "foo.Foo.a"},
+		{"oneof", func() protoreflect.Descriptor { return lookup(".foo.Foo.choice").(OneOf).ReflectDescriptor() }, "foo.Foo.choice"},
+		{"synthetic oneof", func() protoreflect.Descriptor { return lookup(".foo.Foo._maybe").(OneOf).ReflectDescriptor() }, "foo.Foo._maybe"},
+		{"enum", func() protoreflect.Descriptor { return lookup(".foo.Foo.Kind").(Enum).ReflectDescriptor() }, "foo.Foo.Kind"},
+		{"enum value", func() protoreflect.Descriptor {
+			return lookup(".foo.Foo.Kind.KIND_BAR").(EnumValue).ReflectDescriptor()
+		}, "foo.Foo.KIND_BAR"},
+		{"service", func() protoreflect.Descriptor { return lookup(".foo.Svc").(Service).ReflectDescriptor() }, "foo.Svc"},
+		{"method", func() protoreflect.Descriptor { return lookup(".foo.Svc.Do").(Method).ReflectDescriptor() }, "foo.Svc.Do"},
+		{"extension", func() protoreflect.Descriptor { return lookup(".bar.tag").(Extension).ReflectDescriptor() }, "bar.tag"},
+	}
+
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.desc()
+			require.NotNil(t, d)
+			assert.Equal(t, tc.fullName, d.FullName())
+		})
+	}
+
+	t.Run("dynamic", func(t *testing.T) {
+		md := lookup(".bar.Bar").(Message).ReflectDescriptor()
+		require.NotNil(t, md)
+
+		xd := lookup(".bar.tag").(Extension).ReflectDescriptor()
+		require.True(t, xd.IsExtension())
+
+		xt := dynamicpb.NewExtensionType(xd).TypeDescriptor()
+		m := dynamicpb.NewMessage(md)
+		m.Set(xt, protoreflect.ValueOfString("baz"))
+		assert.Equal(t, "baz", m.Get(xt).String())
+	})
+
+	t.Run("shared descriptors", func(t *testing.T) {
+		fld := lookup(".bar.Bar.foo").(Field).ReflectDescriptor()
+		msg := lookup(".foo.Foo").(Message).ReflectDescriptor()
+		assert.Equal(t, msg, fld.Message())
+	})
+}
+
+func TestEntity_ReflectDescriptor_Lazy(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequestLazy(d, compileRequest(t, reflectSources, "bar/bar.proto"))
+	require.False(t, d.Failed())
+
+	f := ast.Targets()["bar/bar.proto"]
+	require.NotNil(t, f)
+
+	fd := f.ReflectDescriptor()
+	require.NotNil(t, fd)
+	assert.Equal(t, f.Messages()[0].ReflectDescriptor(), fd.Messages().Get(0))
+
+	files, err := ast.ReflectFiles()
+	require.NoError(t, err)
+	_, err = files.FindFileByPath("foo/foo.proto")
+	assert.NoError(t, err)
+}
+
+func TestEntity_ReflectDescriptor_Mutator(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, compileRequest(t, reflectSources, "foo/foo.proto"))
+	require.False(t, d.Failed())
+
+	f := ast.Targets()["foo/foo.proto"]
+	require.NotNil(t, f.ReflectDescriptor())
+
+	mu, err := NewMutator(ast)
+	require.NoError(t, err)
+
+	m, err := mu.AddMessage(f, &descriptor.DescriptorProto{Name: proto.String("Added")})
+	require.NoError(t, err)
+
+	md := m.ReflectDescriptor()
+	require.NotNil(t, md)
+	assert.Equal(t, protoreflect.FullName("foo.Added"), md.FullName())
+	assert.Equal(t, md, f.ReflectDescriptor().Messages().ByName("Added"))
+}
+
+func TestEntity_ReflectDescriptor_Unresolvable(t *testing.T) {
+	t.Parallel()
+
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"foo.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{{
+			Name:        proto.String("foo.proto"),
+			Package:     proto.String("foo"),
+			MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Foo")}, {Name: proto.String("Foo")}},
+		}},
+	})
+	require.False(t, d.Failed())
+
+	f := ast.Targets()["foo.proto"]
+	assert.Nil(t, f.ReflectDescriptor())
+	assert.Nil(t, f.Messages()[0].ReflectDescriptor())
+
+	_, err := ast.ReflectFiles()
+	assert.Error(t, err)
+
+	out, _ := io.ReadAll(d.Output())
+	assert.Equal(t, 1, strings.Count(string(out), "unable to resolve protoreflect descriptors"))
+
+	detached := &enumVal{desc: &descriptor.EnumValueDescriptorProto{}, enum: &enum{parent: &file{}}}
+	assert.Nil(t, detached.ReflectDescriptor())
+}
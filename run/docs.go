@@ -0,0 +1,5 @@
+// Package pgsrun executes protoc plugins without protoc, either as a binary or as
+// an in-process PG* Generator, applying the resulting CodeGeneratorResponse to
+// an output directory as protoc would. It backs the `protoc-gen-star run`
+// command.
+package pgsrun
@@ -0,0 +1,195 @@
+package pgsrun
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+// Input describes the CodeGeneratorRequest of a run. Exactly one of
+// DescriptorSet or Request must be provided.
+type Input struct {
+	// DescriptorSet is the path to a FileDescriptorSet, as generated by
+	// `protoc --include_imports -o`.
+	DescriptorSet string
+
+	// Request is the path to a CodeGeneratorRequest, as captured by
+	// protoc-gen-debug.
+	Request string
+
+	// Targets are the files to generate. If empty, every file in the
+	// DescriptorSet, or the files of the captured Request, are targeted.
+	Targets []string
+
+	// Parameter, if not nil, overrides the parameters of a captured Request.
+	Parameter *string
+}
+
+// BuildRequest reads the CodeGeneratorRequest described by in.
+func BuildRequest(in Input) (*plugin_go.CodeGeneratorRequest, error) {
+	var (
+		req *plugin_go.CodeGeneratorRequest
+		err error
+	)
+
+	switch {
+	case (in.DescriptorSet == "") == (in.Request == ""):
+		return nil, errors.New("exactly one of a descriptor set or request is required")
+	case in.DescriptorSet != "":
+		req, err = requestFromDescriptorSet(in.DescriptorSet, in.Targets)
+	default:
+		req, err = requestFromFile(in.Request, in.Targets)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Parameter != nil {
+		req.Parameter = proto.String(*in.Parameter)
+	}
+
+	return req, nil
+}
+
+func requestFromDescriptorSet(path string, targets []string) (*plugin_go.CodeGeneratorRequest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read descriptor set: %v", err)
+	}
+
+	fdset := &descriptor.FileDescriptorSet{}
+	if err = proto.Unmarshal(data, fdset); err != nil {
+		return nil, fmt.Errorf("unable to parse descriptor set: %v", err)
+	}
+
+	files := make(map[string]struct{}, len(fdset.GetFile()))
+	for _, f := range fdset.GetFile() {
+		files[f.GetName()] = struct{}{}
+	}
+
+	for _, t := range targets {
+		if _, ok := files[t]; !ok {
+			return nil, fmt.Errorf("target file not found in descriptor set: %s", t)
+		}
+	}
+
+	req := &plugin_go.CodeGeneratorRequest{ProtoFile: fdset.GetFile()}
+	if len(targets) == 0 {
+		for _, f := range fdset.GetFile() {
+			req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+		}
+	} else {
+		req.FileToGenerate = targets
+	}
+
+	return req, nil
+}
+
+func requestFromFile(path string, targets []string) (*plugin_go.CodeGeneratorRequest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request: %v", err)
+	}
+
+	req := &plugin_go.CodeGeneratorRequest{}
+	if err = proto.Unmarshal(data, req); err != nil {
+		return nil, fmt.Errorf("unable to parse request: %v", err)
+	}
+
+	if len(targets) > 0 {
+		req.FileToGenerate = targets
+	}
+
+	return req, nil
+}
+
+// Plugin executes the plugin binary at path against req, writing req to its
+// stdin and reading the response from its stdout. The plugin's stderr is
+// passed through.
+func Plugin(path string, req *plugin_go.CodeGeneratorRequest) (*plugin_go.CodeGeneratorResponse, error) {
+	in, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal request: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+
+	resp := &plugin_go.CodeGeneratorResponse{}
+	if err = proto.Unmarshal(out.Bytes(), resp); err != nil {
+		return nil, fmt.Errorf("unable to parse response: %v", err)
+	}
+
+	return resp, nil
+}
+
+// Generator executes g in-process against req and applies the response to
+// dir. The Generator must not have been rendered yet; see
+// pgs.Generator.RenderRequest.
+func Generator(g *pgs.Generator, req *plugin_go.CodeGeneratorRequest, dir string) error {
+	resp, err := g.RenderRequest(req)
+	if err != nil {
+		return fmt.Errorf("unable to render request: %v", err)
+	}
+
+	return Apply(dir, resp)
+}
+
+// Apply writes the files of resp to dir, resolving insertion points against
+// files generated earlier in the response or existing in dir. An error is
+// returned, and nothing is written, if resp reports an error or any file name
+// is not a relative path within dir.
+func Apply(dir string, resp *plugin_go.CodeGeneratorResponse) error {
+	existing := make(map[string]string)
+	for _, f := range resp.GetFile() {
+		if f.InsertionPoint == nil || !filepath.IsLocal(filepath.FromSlash(f.GetName())) {
+			continue
+		}
+
+		if b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.GetName()))); err == nil {
+			existing[f.GetName()] = string(b)
+		}
+	}
+
+	files, err := pgs.ApplyResponse(resp, existing)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		path := filepath.Join(dir, filepath.FromSlash(n))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("unable to create directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(files[n]), 0644); err != nil {
+			return fmt.Errorf("unable to write file: %v", err)
+		}
+	}
+
+	return nil
+}
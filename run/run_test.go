@@ -0,0 +1,204 @@
+package pgsrun
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+// pluginEnv, when set, causes the test binary to act as a protoc plugin,
+// emitting a file per target named with the variable's value as a suffix.
+const pluginEnv = "PGSRUN_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if suffix, ok := os.LookupEnv(pluginEnv); ok {
+		os.Exit(testPlugin(suffix))
+	}
+	os.Exit(m.Run())
+}
+
+func testPlugin(suffix string) int {
+	in, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return 1
+	}
+
+	req := &plugin_go.CodeGeneratorRequest{}
+	if err = proto.Unmarshal(in, req); err != nil {
+		return 1
+	}
+
+	resp := &plugin_go.CodeGeneratorResponse{}
+	for _, f := range req.GetFileToGenerate() {
+		resp.File = append(resp.File, &plugin_go.CodeGeneratorResponse_File{
+			Name:    proto.String(f + suffix),
+			Content: proto.String(req.GetParameter()),
+		})
+	}
+
+	out, err := proto.Marshal(resp)
+	if err != nil {
+		return 1
+	}
+
+	_, err = os.Stdout.Write(out)
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func writeProto(t *testing.T, dir, name string, m proto.Message) string {
+	b, err := proto.Marshal(m)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, b, 0644))
+	return path
+}
+
+func testFiles() []*descriptor.FileDescriptorProto {
+	return []*descriptor.FileDescriptorProto{
+		{Name: proto.String("a.proto"), Package: proto.String("a"), Syntax: proto.String("proto3")},
+		{Name: proto.String("b.proto"), Package: proto.String("b"), Syntax: proto.String("proto3")},
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fdset := writeProto(t, dir, "fdset.bin", &descriptor.FileDescriptorSet{File: testFiles()})
+	captured := writeProto(t, dir, "request.bin", &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"a.proto"},
+		Parameter:      proto.String("foo=bar"),
+		ProtoFile:      testFiles(),
+	})
+
+	req, err := BuildRequest(Input{DescriptorSet: fdset})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.proto", "b.proto"}, req.GetFileToGenerate())
+	assert.Len(t, req.GetProtoFile(), 2)
+
+	req, err = BuildRequest(Input{DescriptorSet: fdset, Targets: []string{"b.proto"}, Parameter: proto.String("x=y")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.proto"}, req.GetFileToGenerate())
+	assert.Equal(t, "x=y", req.GetParameter())
+
+	_, err = BuildRequest(Input{DescriptorSet: fdset, Targets: []string{"c.proto"}})
+	assert.EqualError(t, err, "target file not found in descriptor set: c.proto")
+
+	req, err = BuildRequest(Input{Request: captured})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.proto"}, req.GetFileToGenerate())
+	assert.Equal(t, "foo=bar", req.GetParameter())
+
+	req, err = BuildRequest(Input{Request: captured, Targets: []string{"b.proto"}, Parameter: proto.String("")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.proto"}, req.GetFileToGenerate())
+	assert.Equal(t, "", req.GetParameter())
+
+	_, err = BuildRequest(Input{})
+	assert.Error(t, err)
+
+	_, err = BuildRequest(Input{DescriptorSet: fdset, Request: captured})
+	assert.Error(t, err)
+
+	_, err = BuildRequest(Input{Request: filepath.Join(dir, "missing.bin")})
+	assert.Error(t, err)
+}
+
+func TestPlugin(t *testing.T) {
+	t.Setenv(pluginEnv, ".txt")
+
+	resp, err := Plugin(os.Args[0], &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"a.proto"},
+		Parameter:      proto.String("foo=bar"),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.GetFile(), 1)
+	assert.Equal(t, "a.proto.txt", resp.GetFile()[0].GetName())
+	assert.Equal(t, "foo=bar", resp.GetFile()[0].GetContent())
+
+	_, err = Plugin(filepath.Join(t.TempDir(), "missing"), &plugin_go.CodeGeneratorRequest{})
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pkg"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pkg", "disk.go"), []byte("// @@protoc_insertion_point(x)\n"), 0644))
+
+	require.NoError(t, Apply(dir, &plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+		{Name: proto.String("pkg/gen.go"), Content: proto.String("gen\n")},
+		{Name: proto.String("pkg/disk.go"), InsertionPoint: proto.String("x"), Content: proto.String("inserted\n")},
+	}}))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "pkg", "gen.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "gen\n", string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "pkg", "disk.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "inserted\n// @@protoc_insertion_point(x)\n", string(b))
+}
+
+func TestApply_InvalidNames(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+
+	for _, name := range []string{"../escape.txt", "a/../../escape.txt", filepath.Join(root, "escape.txt")} {
+		err := Apply(dir, &plugin_go.CodeGeneratorResponse{File: []*plugin_go.CodeGeneratorResponse_File{
+			{Name: proto.String("ok.txt"), Content: proto.String("ok")},
+			{Name: proto.String(name), Content: proto.String("escaped")},
+		}})
+		assert.Error(t, err, name)
+	}
+
+	_, err := os.Stat(filepath.Join(root, "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err), "nothing is written")
+}
+
+type testModule struct {
+	*pgs.ModuleBase
+}
+
+func (m testModule) Name() string { return "test" }
+
+func (m testModule) Execute(targets map[string]pgs.File, pkgs map[string]pgs.Package) []pgs.Artifact {
+	for name := range targets {
+		m.AddGeneratorFile(name+".txt", m.Parameters().Str("foo"))
+	}
+	return m.Artifacts()
+}
+
+func TestGenerator(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	g := pgs.Init().RegisterModule(testModule{&pgs.ModuleBase{}})
+
+	require.NoError(t, Generator(g, &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: []string{"a.proto"},
+		Parameter:      proto.String("foo=bar"),
+		ProtoFile:      testFiles(),
+	}, dir))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a.proto.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(b))
+}
@@ -3,6 +3,7 @@ package pgs
 import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // Service describes a proto service definition (typically, gRPC)
@@ -12,6 +13,11 @@ type Service interface {
 	// Descriptor returns the underlying proto descriptor for this service
 	Descriptor() *descriptor.ServiceDescriptorProto
 
+	// ReflectDescriptor returns the protoreflect descriptor for this service,
+	// resolved from the descriptors of the AST via protodesc. Nil is returned
+	// if the descriptors of the AST cannot be resolved.
+	ReflectDescriptor() protoreflect.ServiceDescriptor
+
 	// Methods returns each rpc method exposed by this service
 	Methods() []Method
 
@@ -37,6 +43,10 @@ func (s *service) BuildTarget() bool                              { return s.fil
 func (s *service) SourceCodeInfo() SourceCodeInfo                 { return s.info }
 func (s *service) Descriptor() *descriptor.ServiceDescriptorProto { return s.desc }
 
+func (s *service) Features() *descriptor.FeatureSet {
+	return resolveFeatures(s.file.Features(), s.desc.GetOptions().GetFeatures())
+}
+
 func (s *service) Extension(desc *proto.ExtensionDesc, ext interface{}) (bool, error) {
 	return extension(s.desc.GetOptions(), desc, &ext)
 }
@@ -68,6 +78,11 @@ func (s *service) addMethod(m Method) {
 	s.methods = append(s.methods, m)
 }
 
+func (s *service) ReflectDescriptor() protoreflect.ServiceDescriptor {
+	d, _ := findDescriptor(s).(protoreflect.ServiceDescriptor)
+	return d
+}
+
 func (s *service) accept(v Visitor) (err error) {
 	if v == nil {
 		return
@@ -1,6 +1,8 @@
 package pgs
 
 import (
+	"fmt"
+
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 )
 
@@ -18,9 +20,9 @@ const (
 	serviceTypeMethodPath     int32 = 2  // ServiceDescriptorProto.Method
 )
 
-// SourceCodeInfo represents data about an entity from the source. Currently
-// this only contains information about comments protoc associates with
-// entities.
+// SourceCodeInfo represents data about an entity from the source. This
+// contains the comments protoc associates with entities as well as their
+// location within the file.
 //
 // All comments have their // or /* */ stripped by protoc. See the
 // SourceCodeInfo documentation for more details about how comments are
@@ -42,6 +44,55 @@ type SourceCodeInfo interface {
 	// a leading comment for another entity, it won't be considered a trailing
 	// comment.
 	TrailingComments() string
+
+	// Span returns the location of the entity within its source file. The
+	// returned Span is invalid if protoc did not provide one.
+	Span() Span
+}
+
+// Span describes the start and end of an entity within its source file. Unlike
+// the raw span on SourceCodeInfo_Location, lines and columns are 1-based to
+// match the conventions of editors and compilers. The end position is
+// exclusive.
+type Span struct {
+	StartLine, StartColumn int
+	EndLine, EndColumn     int
+}
+
+// IsValid returns true if the Span describes a location in the source file.
+func (s Span) IsValid() bool { return s.StartLine > 0 }
+
+// String returns the start of the Span formatted as `line:col`.
+func (s Span) String() string { return fmt.Sprintf("%d:%d", s.StartLine, s.StartColumn) }
+
+// A Position identifies an entity within a source proto file. Its String
+// method produces the `file:line:col` format understood by most editors and
+// CI tooling.
+type Position struct {
+	// Filename is the path of the proto file, as provided to protoc.
+	Filename string
+
+	// Span is the location of the entity within the file. This value may be
+	// invalid if protoc did not provide source code info for the entity.
+	Span Span
+}
+
+// PositionOf returns the Position of the entity e within its source file.
+func PositionOf(e Entity) Position {
+	pos := Position{Filename: e.File().Descriptor().GetName()}
+	if info := e.SourceCodeInfo(); info != nil {
+		pos.Span = info.Span()
+	}
+	return pos
+}
+
+// String returns the Position formatted as `file:line:col`. If the Span is
+// invalid, only the file name is returned.
+func (p Position) String() string {
+	if !p.Span.IsValid() {
+		return p.Filename
+	}
+	return p.Filename + ":" + p.Span.String()
 }
 
 type sci struct {
@@ -53,4 +104,24 @@ func (info sci) LeadingComments() string                       { return info.des
 func (info sci) LeadingDetachedComments() []string             { return info.desc.GetLeadingDetachedComments() }
 func (info sci) TrailingComments() string                      { return info.desc.GetTrailingComments() }
 
+func (info sci) Span() Span {
+	// SourceCodeInfo_Location spans are 0-based and contain either three
+	// (start line, start column, end column) or four elements (start line, start
+	// column, end line, end column).
+	switch s := info.desc.GetSpan(); len(s) {
+	case 3:
+		return Span{
+			StartLine: int(s[0]) + 1, StartColumn: int(s[1]) + 1,
+			EndLine: int(s[0]) + 1, EndColumn: int(s[2]) + 1,
+		}
+	case 4:
+		return Span{
+			StartLine: int(s[0]) + 1, StartColumn: int(s[1]) + 1,
+			EndLine: int(s[2]) + 1, EndColumn: int(s[3]) + 1,
+		}
+	default:
+		return Span{}
+	}
+}
+
 var _ SourceCodeInfo = sci{}
@@ -24,3 +24,30 @@ func TestSourceCodeInfo(t *testing.T) {
 	assert.Equal(t, "trailing", info.TrailingComments())
 	assert.Equal(t, []string{"detached"}, info.LeadingDetachedComments())
 }
+
+func TestSourceCodeInfo_Span(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, sci{&descriptor.SourceCodeInfo_Location{}}.Span().IsValid())
+
+	s := sci{&descriptor.SourceCodeInfo_Location{Span: []int32{11, 2, 20}}}.Span()
+	assert.True(t, s.IsValid())
+	assert.Equal(t, Span{StartLine: 12, StartColumn: 3, EndLine: 12, EndColumn: 21}, s)
+	assert.Equal(t, "12:3", s.String())
+
+	s = sci{&descriptor.SourceCodeInfo_Location{Span: []int32{11, 2, 14, 1}}}.Span()
+	assert.Equal(t, Span{StartLine: 12, StartColumn: 3, EndLine: 15, EndColumn: 2}, s)
+}
+
+func TestPositionOf(t *testing.T) {
+	t.Parallel()
+
+	m := dummyMsg()
+	assert.Equal(t, "file.proto", PositionOf(m).String())
+
+	m.addSourceCodeInfo(sci{&descriptor.SourceCodeInfo_Location{Span: []int32{11, 2, 20}}})
+	pos := PositionOf(m)
+	assert.Equal(t, "file.proto", pos.Filename)
+	assert.Equal(t, 12, pos.Span.StartLine)
+	assert.Equal(t, "file.proto:12:3", pos.String())
+}
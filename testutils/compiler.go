@@ -0,0 +1,144 @@
+package testutils
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	pgs "github.com/vchitai/protoc-gen-star"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CompileProtos parses and compiles the provided files (or globs, as defined
+// by filepath.Glob) on l.FS in-process, returning a resolved pgs.AST targeting
+// them. Unlike LoadProtos, protoc is not required. Imports are resolved
+// relative to l.ImportPaths, falling back to the well-known types included
+// with protoc. The test/benchmark is fatally stopped if there is any error.
+func (l Loader) CompileProtos(t T, files ...string) pgs.AST {
+	targets := l.resolveTargets(t, files...)
+	if targets == nil {
+		return nil
+	}
+
+	for i, target := range targets {
+		targets[i] = l.importName(target)
+	}
+
+	fs := l.resolveFS()
+	fdset := l.compile(t, &protocompile.SourceResolver{
+		ImportPaths: l.ImportPaths,
+		Accessor:    func(path string) (io.ReadCloser, error) { return fs.Open(path) },
+	}, targets)
+
+	return l.loadCompiled(t, fdset, targets)
+}
+
+// CompileSources parses and compiles in-memory proto sources, keyed by their
+// import path (eg, "foo/bar.proto"), returning a resolved pgs.AST. Imports are
+// resolved against sources, falling back to the well-known types included
+// with protoc. If no targets are provided, every file in sources is targeted.
+// The test/benchmark is fatally stopped if there is any error.
+func (l Loader) CompileSources(t T, sources map[string]string, targets ...string) pgs.AST {
+	if len(targets) == 0 {
+		targets = sourceNames(sources)
+	}
+
+	fdset := l.compile(t, &protocompile.SourceResolver{
+		Accessor: protocompile.SourceAccessorFromMap(sources),
+	}, targets)
+
+	return l.loadCompiled(t, fdset, targets)
+}
+
+// compile parses and links targets resolved via r, returning a
+// FileDescriptorSet containing them and all of their dependencies in
+// topological order, with source code info. The test/benchmark is fatally
+// stopped if there is any error.
+func (l Loader) compile(t T, r protocompile.Resolver, targets []string) *descriptor.FileDescriptorSet {
+	if len(targets) == 0 {
+		t.Fatal("no proto files specified")
+		return nil
+	}
+
+	c := protocompile.Compiler{
+		Resolver:       protocompile.WithStandardImports(r),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+
+	files, err := c.Compile(context.Background(), targets...)
+	if err != nil {
+		t.Fatalf("unable to compile protos: %v", err)
+		return nil
+	}
+
+	fdset := &descriptor.FileDescriptorSet{}
+	seen := make(map[string]struct{})
+
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if _, ok := seen[fd.Path()]; ok {
+			return
+		}
+		seen[fd.Path()] = struct{}{}
+
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+
+		fdset.File = append(fdset.File, protodesc.ToFileDescriptorProto(fd))
+	}
+
+	for _, f := range files {
+		add(f)
+	}
+
+	return fdset
+}
+
+// loadCompiled resolves an AST from fdset, targeting the named files.
+func (l Loader) loadCompiled(t T, fdset *descriptor.FileDescriptorSet, targets []string) pgs.AST {
+	if fdset == nil {
+		return nil
+	}
+
+	req := &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: targets,
+		ProtoFile:      fdset.GetFile(),
+	}
+
+	return l.process(t, "compiled protos", func(d pgs.Debugger) pgs.AST {
+		if l.BiDirectional {
+			return pgs.ProcessCodeGeneratorRequestBidirectional(d, req)
+		}
+		return pgs.ProcessCodeGeneratorRequest(d, req)
+	})
+}
+
+// importName converts the file path of a proto on l.FS to its name relative
+// to the first import path containing it, as protoc would.
+func (l Loader) importName(path string) string {
+	for _, imp := range l.ImportPaths {
+		rel, err := filepath.Rel(imp, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+
+	return filepath.ToSlash(path)
+}
+
+func sourceNames(sources map[string]string) []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
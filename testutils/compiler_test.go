@@ -0,0 +1,129 @@
+package testutils
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+const compilerFooProto = `syntax = "proto3";
+package foo;
+
+import "bar/bar.proto";
+import "google/protobuf/timestamp.proto";
+
+// Foo is a message.
+message Foo {
+  bar.Bar bar = 1;
+  google.protobuf.Timestamp created = 2;
+}
+`
+
+const compilerBarProto = `syntax = "proto3";
+package bar;
+
+message Bar {}
+`
+
+func TestLoader_CompileSources(t *testing.T) {
+	t.Parallel()
+
+	sources := map[string]string{
+		"foo/foo.proto": compilerFooProto,
+		"bar/bar.proto": compilerBarProto,
+	}
+
+	t.Run("all targets", func(t *testing.T) {
+		t.Parallel()
+
+		mt := &mockT{}
+		ast := Loader{}.CompileSources(mt, sources)
+		require.False(t, mt.failed, mt.log)
+
+		assert.Len(t, ast.Targets(), 2)
+		assert.Contains(t, ast.Targets(), "foo/foo.proto")
+		assert.Contains(t, ast.Targets(), "bar/bar.proto")
+
+		ent, ok := ast.Lookup(".google.protobuf.Timestamp")
+		require.True(t, ok)
+		assert.False(t, ent.BuildTarget())
+
+		ent, ok = ast.Lookup(".foo.Foo")
+		require.True(t, ok)
+		assert.Equal(t, " Foo is a message.\n", ent.SourceCodeInfo().LeadingComments())
+
+		ent, ok = ast.Lookup(".foo.Foo.created")
+		require.True(t, ok)
+		assert.Equal(t, ".google.protobuf.Timestamp",
+			ent.(pgs.Field).Type().Embed().FullyQualifiedName())
+	})
+
+	t.Run("explicit targets", func(t *testing.T) {
+		t.Parallel()
+
+		mt := &mockT{}
+		ast := Loader{BiDirectional: true}.CompileSources(mt, sources, "foo/foo.proto")
+		require.False(t, mt.failed, mt.log)
+
+		assert.Len(t, ast.Targets(), 1)
+		assert.Contains(t, ast.Targets(), "foo/foo.proto")
+
+		ent, ok := ast.Lookup(".bar.Bar")
+		require.True(t, ok)
+		assert.Len(t, ent.(pgs.Message).Dependents(), 1)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		mt := &mockT{}
+		Loader{}.CompileSources(mt, map[string]string{"foo.proto": "message {"})
+		assert.True(t, mt.failed)
+
+		mt = &mockT{}
+		Loader{}.CompileSources(mt, map[string]string{"foo/foo.proto": compilerFooProto})
+		assert.True(t, mt.failed)
+		assert.Contains(t, mt.log, "bar/bar.proto")
+
+		mt = &mockT{}
+		Loader{}.CompileSources(mt, nil)
+		assert.True(t, mt.failed)
+	})
+}
+
+func TestLoader_CompileProtos(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "protos/foo/foo.proto", []byte(compilerFooProto), 0644))
+	require.NoError(t, afero.WriteFile(fs, "protos/bar/bar.proto", []byte(compilerBarProto), 0644))
+
+	mt := &mockT{}
+	ast := Loader{FS: fs, ImportPaths: []string{"protos"}}.CompileProtos(mt, "protos/foo/*.proto")
+	require.False(t, mt.failed, mt.log)
+
+	assert.Len(t, ast.Targets(), 1)
+	assert.Contains(t, ast.Targets(), "foo/foo.proto")
+	_, ok := ast.Lookup(".bar.Bar")
+	assert.True(t, ok)
+
+	mt = &mockT{}
+	Loader{FS: fs}.CompileProtos(mt, "protos/foo/foo.proto")
+	assert.True(t, mt.failed)
+
+	mt = &mockT{}
+	Loader{FS: fs}.CompileProtos(mt, "missing/*.proto")
+	assert.True(t, mt.failed)
+}
+
+func TestLoader_ImportName(t *testing.T) {
+	t.Parallel()
+
+	l := Loader{ImportPaths: []string{"a", "b/c"}}
+	assert.Equal(t, "foo.proto", l.importName("a/foo.proto"))
+	assert.Equal(t, "d/foo.proto", l.importName("b/c/d/foo.proto"))
+	assert.Equal(t, "b/foo.proto", l.importName("b/foo.proto"))
+}
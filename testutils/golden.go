@@ -0,0 +1,372 @@
+package testutils
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/golang/protobuf/proto"
+	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+var update = flag.Bool("pgs.update", false, "regenerate the golden files compared by testutils.Golden")
+
+const (
+	// GoldenGeneratedDir is the subdirectory of a Golden's Dir containing the
+	// files emitted to protoc via the CodeGeneratorResponse.
+	GoldenGeneratedDir = "generated"
+
+	// GoldenCustomDir is the subdirectory of a Golden's Dir containing the
+	// files written directly to the file system by CustomFile artifacts.
+	GoldenCustomDir = "custom"
+
+	// GoldenErrorFile is the file within a Golden's Dir containing the error
+	// reported on the CodeGeneratorResponse, if any.
+	GoldenErrorFile = "error.txt"
+)
+
+// goldenOutputs are the entries of a Golden's Dir managed by Golden.
+var goldenOutputs = []string{GoldenGeneratedDir, GoldenCustomDir, GoldenErrorFile}
+
+// Golden is a testing utility that executes a Generator in memory and
+// compares its output against a directory of golden files. Generated files are
+// stored in the GoldenGeneratedDir subdirectory of Dir, custom files in the
+// GoldenCustomDir subdirectory, and any error reported to protoc in
+// GoldenErrorFile. Any other files in Dir are ignored.
+//
+// Running the tests with the -pgs.update flag regenerates the golden files
+// instead of comparing against them. The flag is namespaced so that it does
+// not collide with an -update flag defined by the test package itself.
+type Golden struct {
+	// Loader resolves the input protos. Its FS is used to read FDSet and Protos,
+	// but the golden files are always read from the OS file system.
+	Loader
+
+	// Dir is the directory containing the golden files.
+	Dir string
+
+	// Protos are the proto files (or globs, as defined by filepath.Glob)
+	// compiled with protoc as the Generator's input. Exactly one of Protos,
+	// Sources or FDSet must be provided.
+	Protos []string
+
+	// Sources are in-memory proto sources, keyed by import path, compiled
+	// in-process as the Generator's input. Exactly one of Protos, Sources or
+	// FDSet must be provided.
+	Sources map[string]string
+
+	// FDSet is the path to a serialized FileDescriptorSet used as the
+	// Generator's input. Exactly one of Protos, Sources or FDSet must be
+	// provided.
+	FDSet string
+
+	// Targets are the files the Generator is run against. If empty, every file
+	// in Protos or Sources is targeted, excluding their imports, or every file
+	// in FDSet otherwise.
+	Targets []string
+
+	// Parameters is the parameter string passed to the Generator, as it would
+	// be received from protoc (eg, "paths=source_relative,foo=bar").
+	Parameters string
+
+	// Modules are registered with the Generator.
+	Modules []pgs.Module
+
+	// PostProcessors are registered with the Generator.
+	PostProcessors []pgs.PostProcessor
+
+	// Options are any additional InitOptions provided to the Generator.
+	Options []pgs.InitOption
+
+	// Existing provides the content of files generated by other plugins, keyed
+	// by name, which may be the target of an insertion point. They are not
+	// compared themselves.
+	Existing map[string]string
+
+	// Update regenerates the golden files instead of comparing against them,
+	// as with the -pgs.update flag.
+	Update bool
+}
+
+// Run executes the Generator, comparing every generated file, custom file and
+// error against the golden files in g.Dir. The test/benchmark is fatally
+// stopped if there is any error or mismatch.
+func (g Golden) Run(t T) {
+	actual := g.render(t)
+	if actual == nil {
+		return
+	}
+
+	if g.Update || *update {
+		g.write(t, actual)
+		return
+	}
+
+	expected := g.read(t)
+	if expected == nil {
+		return
+	}
+
+	if diffs := goldenDiff(expected, actual); len(diffs) > 0 {
+		t.Fatalf("output does not match golden files in %q (run with -pgs.update to regenerate):\n%s",
+			g.Dir, strings.Join(diffs, "\n"))
+	}
+}
+
+// render executes the Generator in memory, returning the content of each
+// output keyed by its path relative to g.Dir.
+func (g Golden) render(t T) map[string]string {
+	raw, targets := g.input(t)
+	if raw == nil {
+		return nil
+	}
+
+	out := &bytes.Buffer{}
+	fs := afero.NewMemMapFs()
+
+	opts := []pgs.InitOption{
+		pgs.FromDescriptorSet(bytes.NewReader(raw), targets...),
+		pgs.ProtocParameters(g.Parameters),
+		pgs.ProtocOutput(out),
+		// root relative custom files so every file can be walked from "/"
+		pgs.FileSystem(afero.NewBasePathFs(fs, "/")),
+		pgs.CollectErrors(),
+	}
+	if g.BiDirectional {
+		opts = append(opts, pgs.BiDirectional())
+	}
+
+	gen := pgs.Init(append(opts, g.Options...)...)
+	gen.RegisterModule(g.Modules...)
+	gen.RegisterPostProcessor(g.PostProcessors...)
+	gen.Render()
+
+	resp := &plugin_go.CodeGeneratorResponse{}
+	if err := proto.Unmarshal(out.Bytes(), resp); err != nil {
+		t.Fatalf("unable to unmarshal response: %v", err)
+		return nil
+	}
+
+	actual := make(map[string]string)
+	if resp.Error != nil {
+		actual[GoldenErrorFile] = resp.GetError()
+		resp.Error = nil
+	}
+
+	files, err := pgs.ApplyResponse(resp, g.Existing)
+	if err != nil {
+		t.Fatalf("unable to apply response: %v", err)
+		return nil
+	}
+
+	for name, content := range files {
+		actual[filepath.Join(GoldenGeneratedDir, name)] = content
+	}
+
+	err = afero.Walk(fs, "/", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		b, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return err
+		}
+
+		actual[filepath.Join(GoldenCustomDir, strings.TrimPrefix(path, "/"))] = string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to read custom files: %v", err)
+		return nil
+	}
+
+	return actual
+}
+
+// input resolves the serialized FileDescriptorSet and targets provided to the
+// Generator.
+func (g Golden) input(t T) (raw []byte, targets []string) {
+	n := 0
+	for _, ok := range []bool{len(g.Protos) > 0, len(g.Sources) > 0, g.FDSet != ""} {
+		if ok {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatal("exactly one of Protos, Sources or FDSet must be provided")
+		return nil, nil
+	}
+
+	targets = g.Targets
+
+	switch {
+	case len(g.Protos) > 0:
+		if len(targets) == 0 {
+			// protoc includes the imports in the set, which would otherwise all
+			// be targeted
+			if targets = g.resolveTargets(t, g.Protos...); targets == nil {
+				return nil, nil
+			}
+			for i, target := range targets {
+				targets[i] = g.importName(target)
+			}
+		}
+
+		if raw = g.runProtoc(t, g.Protos...); raw == nil {
+			return nil, nil
+		}
+	case len(g.Sources) > 0:
+		if len(targets) == 0 {
+			targets = sourceNames(g.Sources)
+		}
+
+		fdset := g.compile(t, &protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(g.Sources),
+		}, targets)
+		if fdset == nil {
+			return nil, nil
+		}
+
+		var err error
+		if raw, err = proto.Marshal(fdset); err != nil {
+			t.Fatalf("unable to marshal fdset: %v", err)
+			return nil, nil
+		}
+	default:
+		var err error
+		if raw, err = afero.ReadFile(g.resolveFS(), g.FDSet); err != nil {
+			t.Fatalf("unable to read fdset from path %q: %v", g.FDSet, err)
+			return nil, nil
+		}
+	}
+
+	return raw, targets
+}
+
+// read returns the content of each golden file in g.Dir, keyed by its path
+// relative to g.Dir. Files other than the outputs managed by Golden are
+// ignored.
+func (g Golden) read(t T) map[string]string {
+	if _, err := os.Stat(g.Dir); err != nil {
+		t.Fatalf("unable to read golden files (run with -pgs.update to generate them): %v", err)
+		return nil
+	}
+
+	expected := make(map[string]string)
+
+	for _, name := range goldenOutputs {
+		root := filepath.Join(g.Dir, name)
+		if err := filepath.Walk(root, g.readFunc(root, expected)); err != nil {
+			t.Fatalf("unable to read golden files: %v", err)
+			return nil
+		}
+	}
+
+	return expected
+}
+
+// readFunc returns a filepath.WalkFunc adding each file under root to
+// expected. A missing root is skipped.
+func (g Golden) readFunc(root string, expected map[string]string) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
+		if path == root && os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(g.Dir, path)
+		if err != nil {
+			return err
+		}
+
+		expected[rel] = string(b)
+		return nil
+	}
+}
+
+// write replaces the golden files in g.Dir with actual. Only the outputs
+// managed by Golden are removed, leaving any other files in g.Dir untouched.
+func (g Golden) write(t T, actual map[string]string) {
+	for _, name := range goldenOutputs {
+		if err := os.RemoveAll(filepath.Join(g.Dir, name)); err != nil {
+			t.Fatalf("unable to remove golden files: %v", err)
+			return
+		}
+	}
+
+	if err := os.MkdirAll(g.Dir, 0755); err != nil {
+		t.Fatalf("unable to create golden directory: %v", err)
+		return
+	}
+
+	for name, content := range actual {
+		path := filepath.Join(g.Dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unable to create golden directory: %v", err)
+			return
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write golden file: %v", err)
+			return
+		}
+	}
+
+	t.Logf("updated golden files in %q", g.Dir)
+}
+
+// goldenDiff describes each difference between the expected and actual
+// outputs, ordered by path.
+func goldenDiff(expected, actual map[string]string) []string {
+	names := make([]string, 0, len(expected)+len(actual))
+	for n := range expected {
+		names = append(names, n)
+	}
+	for n := range actual {
+		if _, ok := expected[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []string
+	for _, n := range names {
+		exp, hasExp := expected[n]
+		act, hasAct := actual[n]
+
+		switch {
+		case !hasExp:
+			diffs = append(diffs, fmt.Sprintf("unexpected file: %s", n))
+		case !hasAct:
+			diffs = append(diffs, fmt.Sprintf("missing file: %s", n))
+		case exp != act:
+			diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(exp),
+				B:        difflib.SplitLines(act),
+				FromFile: filepath.Join("golden", n),
+				ToFile:   filepath.Join("actual", n),
+				Context:  3,
+			})
+			diffs = append(diffs, diff)
+		}
+	}
+
+	return diffs
+}
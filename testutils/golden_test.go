@@ -0,0 +1,213 @@
+package testutils
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgs "github.com/vchitai/protoc-gen-star"
+)
+
+type goldenModule struct {
+	*pgs.ModuleBase
+	fail bool
+}
+
+func (m goldenModule) Name() string { return "golden" }
+
+func (m goldenModule) Execute(targets map[string]pgs.File, pkgs map[string]pgs.Package) []pgs.Artifact {
+	for _, f := range targets {
+		m.AddGeneratorFile(f.InputPath().SetExt(".txt").String(),
+			"// @@protoc_insertion_point(names)\n")
+		for _, msg := range f.AllMessages() {
+			m.AddGeneratorInjection(f.InputPath().SetExt(".txt").String(), "names", msg.Name().String())
+		}
+		m.AddCustomFile("custom/"+f.InputPath().BaseName()+".txt", m.Parameters().Str("foo"), 0644)
+	}
+
+	if m.fail {
+		m.AddError("bad things")
+	}
+
+	return m.Artifacts()
+}
+
+func goldenLoader(t *testing.T) Loader {
+	fs := afero.NewMemMapFs()
+	b, err := proto.Marshal(&descriptor.FileDescriptorSet{File: []*descriptor.FileDescriptorProto{{
+		Name:        proto.String("foo/foo.proto"),
+		Package:     proto.String("foo"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Bar")}, {Name: proto.String("Baz")}},
+	}}})
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(fs, "fdset.bin", b, 0644))
+	return Loader{FS: fs}
+}
+
+func TestGolden_UpdateFlag(t *testing.T) {
+	t.Parallel()
+
+	// the flag is namespaced, leaving -update free for the test package
+	assert.NotNil(t, flag.Lookup("pgs.update"))
+	assert.Nil(t, flag.Lookup("update"))
+}
+
+func TestGolden_Run(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "pgs-golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	g := Golden{
+		Loader:     goldenLoader(t),
+		Dir:        dir,
+		FDSet:      "fdset.bin",
+		Parameters: "foo=fizz",
+		Modules:    []pgs.Module{goldenModule{ModuleBase: &pgs.ModuleBase{}}},
+	}
+
+	// unrelated fixtures in the directory are neither removed nor compared
+	fixture := filepath.Join(dir, "fixture.proto")
+	require.NoError(t, ioutil.WriteFile(fixture, []byte("syntax = \"proto3\";"), 0644))
+
+	mt := &mockT{}
+	g.Run(mt)
+	assert.True(t, mt.failed, "golden files are missing")
+
+	mt = &mockT{}
+	g.Update = true
+	g.Run(mt)
+	require.False(t, mt.failed, mt.log)
+
+	_, err = os.Stat(fixture)
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, GoldenGeneratedDir, "foo", "foo.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Bar\nBaz\n// @@protoc_insertion_point(names)\n", string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, GoldenCustomDir, "custom", "foo.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "fizz", string(b))
+
+	_, err = os.Stat(filepath.Join(dir, GoldenErrorFile))
+	assert.True(t, os.IsNotExist(err))
+
+	mt = &mockT{}
+	g.Update = false
+	g.Run(mt)
+	assert.False(t, mt.failed, mt.log)
+
+	mt = &mockT{}
+	g.Parameters = "foo=buzz"
+	g.Run(mt)
+	assert.True(t, mt.failed)
+	assert.Contains(t, mt.log, "+buzz")
+
+	mt = &mockT{}
+	g.Parameters = "foo=fizz"
+	g.Modules = []pgs.Module{goldenModule{ModuleBase: &pgs.ModuleBase{}, fail: true}}
+	g.Run(mt)
+	assert.True(t, mt.failed)
+	assert.Contains(t, mt.log, "unexpected file: "+GoldenErrorFile)
+}
+
+func TestGolden_Run_Inputs(t *testing.T) {
+	t.Parallel()
+
+	mt := &mockT{}
+	Golden{}.Run(mt)
+	assert.True(t, mt.failed)
+
+	mt = &mockT{}
+	Golden{Protos: []string{"*.proto"}, FDSet: "fdset.bin"}.Run(mt)
+	assert.True(t, mt.failed)
+
+	mt = &mockT{}
+	Golden{Sources: map[string]string{"foo.proto": "message {"}}.Run(mt)
+	assert.True(t, mt.failed)
+
+	mt = &mockT{}
+	Golden{Loader: goldenLoader(t), FDSet: "missing.bin"}.Run(mt)
+	assert.True(t, mt.failed)
+}
+
+func TestGolden_Run_Sources(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "pgs-golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	g := Golden{
+		Dir:     dir,
+		Sources: map[string]string{"foo/foo.proto": compilerFooProto, "bar/bar.proto": compilerBarProto},
+		Targets: []string{"foo/foo.proto"},
+		Modules: []pgs.Module{goldenModule{ModuleBase: &pgs.ModuleBase{}}},
+		Update:  true,
+	}
+
+	mt := &mockT{}
+	g.Run(mt)
+	require.False(t, mt.failed, mt.log)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, GoldenGeneratedDir, "foo", "foo.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Foo\n// @@protoc_insertion_point(names)\n", string(b))
+
+	_, err = os.Stat(filepath.Join(dir, GoldenGeneratedDir, "bar", "bar.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGolden_Run_Protos(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("protoc"); err != nil {
+		t.Skip("protoc not found in PATH")
+		return
+	}
+
+	protos, err := ioutil.TempDir("", "pgs-golden-protos")
+	require.NoError(t, err)
+	defer os.RemoveAll(protos)
+
+	for name, src := range map[string]string{"foo/foo.proto": compilerFooProto, "bar/bar.proto": compilerBarProto} {
+		require.NoError(t, os.MkdirAll(filepath.Join(protos, filepath.Dir(name)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(protos, name), []byte(src), 0644))
+	}
+
+	dir, err := ioutil.TempDir("", "pgs-golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	g := Golden{
+		Loader:  Loader{ImportPaths: []string{protos}},
+		Dir:     dir,
+		Protos:  []string{filepath.Join(protos, "foo", "*.proto")},
+		Modules: []pgs.Module{goldenModule{ModuleBase: &pgs.ModuleBase{}}},
+		Update:  true,
+	}
+
+	mt := &mockT{}
+	g.Run(mt)
+	require.False(t, mt.failed, mt.log)
+
+	_, err = os.Stat(filepath.Join(dir, GoldenGeneratedDir, "foo", "foo.txt"))
+	assert.NoError(t, err)
+
+	// only the resolved protos are targeted, not their imports
+	for _, name := range []string{"bar/bar.txt", "google/protobuf/timestamp.txt"} {
+		_, err = os.Stat(filepath.Join(dir, GoldenGeneratedDir, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+}
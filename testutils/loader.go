@@ -1,6 +1,7 @@
 package testutils
 
 import (
+	"bytes"
 	"io"
 	"io/ioutil"
 	"os/exec"
@@ -55,6 +56,18 @@ func (l Loader) LoadProtos(t T, files ...string) (ast pgs.AST) {
 		return nil
 	}
 
+	raw := l.runProtoc(t, files...)
+	if raw == nil {
+		return nil
+	}
+
+	return l.LoadFDSetReader(t, bytes.NewReader(raw))
+}
+
+// runProtoc executes protoc against the provided files (or globs),
+// returning the serialized FileDescriptorSet. The test/benchmark is fatally
+// stopped if there is any error.
+func (l Loader) runProtoc(t T, files ...string) (raw []byte) {
 	protoc := l.resolveProtoc(t)
 	targets := l.resolveTargets(t, files...)
 
@@ -67,10 +80,13 @@ func (l Loader) LoadProtos(t T, files ...string) (ast pgs.AST) {
 			return
 		}
 
-		ast = l.LoadFDSet(t, tmpFile)
+		var err error
+		if raw, err = afero.ReadFile(l.resolveFS(), tmpFile); err != nil {
+			t.Fatalf("unable to read fdset from path %q: %v", tmpFile, err)
+		}
 	})
 
-	return ast
+	return raw
 }
 
 // LoadFDSet resolves an AST from a serialized FileDescriptorSet file path on
@@ -108,26 +124,33 @@ func (l Loader) LoadFDSetReader(t T, r io.Reader) (ast pgs.AST) {
 		return nil
 	}
 
+	return l.process(t, "fdset", func(d pgs.Debugger) pgs.AST {
+		if l.BiDirectional {
+			return pgs.ProcessFileDescriptorSetBidirectional(d, fdset)
+		}
+		return pgs.ProcessFileDescriptorSet(d, fdset)
+	})
+}
+
+// process resolves an AST via fn, fatally stopping the test/benchmark if fn
+// reports any failure. The desc describes the input in failure messages.
+func (l Loader) process(t T, desc string, fn func(d pgs.Debugger) pgs.AST) (ast pgs.AST) {
 	d := pgs.InitMockDebugger()
 	defer func() {
 		// Recovery here is required if either Process panics due to how the MockDebugger
 		// short circuits the processor (which can currently cause an NPE).
 		if err := recover(); err != nil {
 			buf, _ := ioutil.ReadAll(d.Output())
-			t.Fatalf("failed to process fdset:\n%s", string(buf))
+			t.Fatalf("failed to process %s:\n%s", desc, string(buf))
 			ast = nil
 		}
 	}()
 
-	if l.BiDirectional {
-		ast = pgs.ProcessFileDescriptorSetBidirectional(d, fdset)
-	} else {
-		ast = pgs.ProcessFileDescriptorSet(d, fdset)
-	}
+	ast = fn(d)
 
 	if d.Failed() || d.Exited() {
 		buf, _ := ioutil.ReadAll(d.Output())
-		t.Fatalf("failed to process fdset:\n%s", string(buf))
+		t.Fatalf("failed to process %s:\n%s", desc, string(buf))
 		return nil
 	}
 
@@ -0,0 +1,374 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldDefault parses the default_value of f into its Go value.
+func fieldDefault(f Field) (interface{}, error) {
+	fd := f.Descriptor()
+	if fd.GetLabel() == Repeated.Proto() {
+		return nil, nil
+	}
+
+	s, set := fd.GetDefaultValue(), fd.DefaultValue != nil
+
+	v, err := parseDefault(f, ProtoType(fd.GetType()), s, set)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid default value %q: %v", f.FullyQualifiedName(), s, err)
+	}
+
+	return v, nil
+}
+
+func parseDefault(f Field, pt ProtoType, s string, set bool) (interface{}, error) {
+	switch pt {
+	case EnumT:
+		vals := f.Type().Enum().Values()
+		if !set {
+			if len(vals) == 0 {
+				return nil, nil
+			}
+			return vals[0], nil
+		}
+
+		for _, v := range vals {
+			if v.Name().String() == s {
+				return v, nil
+			}
+		}
+		return nil, errors.New("unknown enum value")
+	case BoolT:
+		if !set {
+			return false, nil
+		}
+		switch s {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, errors.New("invalid bool")
+	case StringT:
+		return s, nil
+	case BytesT:
+		return unescapeBytes(s)
+	case DoubleT:
+		if !set {
+			return float64(0), nil
+		}
+		return parseFloatDefault(s, 64)
+	case FloatT:
+		if !set {
+			return float32(0), nil
+		}
+		v, err := parseFloatDefault(s, 32)
+		return float32(v), err
+	case Int32T, SInt32, SFixed32:
+		if !set {
+			return int32(0), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		return int32(v), err
+	case Int64T, SInt64, SFixed64:
+		if !set {
+			return int64(0), nil
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case UInt32T, Fixed32T:
+		if !set {
+			return uint32(0), nil
+		}
+		v, err := strconv.ParseUint(s, 10, 32)
+		return uint32(v), err
+	case UInt64T, Fixed64T:
+		if !set {
+			return uint64(0), nil
+		}
+		return strconv.ParseUint(s, 10, 64)
+	default:
+		// messages and groups have no default
+		return nil, nil
+	}
+}
+
+func parseFloatDefault(s string, bits int) (float64, error) {
+	switch s {
+	case "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, bits)
+}
+
+// unescapeBytes reverses the C-style escaping protoc applies to the
+// default_value of bytes fields.
+func unescapeBytes(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out = append(out, s[i])
+			continue
+		}
+
+		i++
+		if i == len(s) {
+			return nil, errors.New("trailing backslash")
+		}
+
+		switch c := s[i]; c {
+		case 'a':
+			out = append(out, '\a')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'v':
+			out = append(out, '\v')
+		case '\\', '\'', '"', '?':
+			out = append(out, c)
+		case 'x', 'X':
+			j := i + 1
+			for j < len(s) && j < i+3 && isHexDigit(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, errors.New("invalid hex escape")
+			}
+			v, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			out = append(out, byte(v))
+			i = j - 1
+		default:
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("invalid escape \\%c", c)
+			}
+			v, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return nil, errors.New("invalid octal escape")
+			}
+			out = append(out, byte(v))
+			i = j - 1
+		}
+	}
+
+	return out, nil
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// EncodeTextValue encodes v in the protobuf text format, on a single line,
+// suitable for embedding as a literal in generated code. The value v may be a
+// proto message (including the dynamic messages created by Message.New), a
+// protoreflect.Value (eg, from OptionValue), an EnumValue, or any of the
+// values returned by Field.Default. Unlike prototext, the output is stable.
+// Lists and maps are not supported.
+func EncodeTextValue(v interface{}) (string, error) { return encodeValue(v, false) }
+
+// EncodeJSONValue has the same functionality as EncodeTextValue, but encodes
+// v as compact JSON following the protobuf JSON mapping (eg, 64-bit integers
+// and bytes are encoded as strings).
+func EncodeJSONValue(v interface{}) (string, error) { return encodeValue(v, true) }
+
+func encodeValue(v interface{}, asJSON bool) (string, error) {
+	if pv, ok := v.(protoreflect.Value); ok {
+		if !pv.IsValid() {
+			return "", errors.New("invalid protoreflect.Value")
+		}
+		v = pv.Interface()
+	}
+
+	switch v := v.(type) {
+	case protoreflect.Message:
+		return encodeMessage(v.Interface(), asJSON)
+	case proto.Message:
+		return encodeMessage(v, asJSON)
+	case protov1.Message:
+		return encodeMessage(protov1.MessageV2(v), asJSON)
+	case EnumValue:
+		if asJSON {
+			return strconv.Quote(v.Name().String()), nil
+		}
+		return v.Name().String(), nil
+	case protoreflect.EnumNumber:
+		return strconv.FormatInt(int64(v), 10), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case int64:
+		if asJSON {
+			return strconv.Quote(strconv.FormatInt(v, 10)), nil
+		}
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		if asJSON {
+			return strconv.Quote(strconv.FormatUint(v, 10)), nil
+		}
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return encodeFloat(float64(v), 32, asJSON), nil
+	case float64:
+		return encodeFloat(v, 64, asJSON), nil
+	case string:
+		if asJSON {
+			return encodeJSONString(v)
+		}
+		return quoteText([]byte(v), true), nil
+	case []byte:
+		if asJSON {
+			return strconv.Quote(base64.StdEncoding.EncodeToString(v)), nil
+		}
+		return quoteText(v, false), nil
+	case protoreflect.List, protoreflect.Map:
+		return "", fmt.Errorf("unsupported value type: %T", v)
+	case nil:
+		return "", errors.New("nil value")
+	default:
+		return "", fmt.Errorf("unsupported value type: %T", v)
+	}
+}
+
+func encodeMessage(m proto.Message, asJSON bool) (string, error) {
+	if asJSON {
+		b, err := protojson.Marshal(m)
+		if err != nil {
+			return "", err
+		}
+
+		// strips the whitespace protojson randomly inserts
+		buf := &bytes.Buffer{}
+		if err = json.Compact(buf, b); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	b, err := prototext.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return collapseTextSpaces(string(b)), nil
+}
+
+// collapseTextSpaces removes the extra spaces prototext randomly inserts
+// between fields, ignoring any within string literals.
+func collapseTextSpaces(s string) string {
+	var (
+		sb      strings.Builder
+		quote   byte
+		escaped bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ' && i > 0 && s[i-1] == ' ':
+			continue
+		}
+		sb.WriteByte(c)
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+func encodeFloat(f float64, bits int, asJSON bool) string {
+	switch {
+	case math.IsInf(f, 1):
+		if asJSON {
+			return `"Infinity"`
+		}
+		return "inf"
+	case math.IsInf(f, -1):
+		if asJSON {
+			return `"-Infinity"`
+		}
+		return "-inf"
+	case math.IsNaN(f):
+		if asJSON {
+			return `"NaN"`
+		}
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+func encodeJSONString(s string) (string, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// quoteText quotes b as a text format string literal. Non-printable bytes are
+// escaped in octal, as are all non-ASCII bytes unless utf8 is set.
+func quoteText(b []byte, utf8 bool) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+
+	for _, c := range b {
+		switch {
+		case c == '"':
+			sb.WriteString(`\"`)
+		case c == '\\':
+			sb.WriteString(`\\`)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c >= 0x20 && c < 0x7f, utf8 && c >= 0x80:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, `\%03o`, c)
+		}
+	}
+
+	sb.WriteByte('"')
+	return sb.String()
+}
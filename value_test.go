@@ -0,0 +1,229 @@
+package pgs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var valueSources = map[string]string{
+	"defaults.proto": `syntax = "proto2";
+package defaults;
+
+enum Kind {
+  KIND_FOO = 1;
+  KIND_BAR = 2;
+}
+
+message Defaults {
+  optional bool b = 1 [default = true];
+  optional int32 i32 = 2 [default = -32];
+  optional sint64 i64 = 3 [default = -64];
+  optional uint32 u32 = 4 [default = 32];
+  optional fixed64 u64 = 5 [default = 18446744073709551615];
+  optional float f32 = 6 [default = 1.5];
+  optional double f64 = 7 [default = -inf];
+  optional double nan = 8 [default = nan];
+  optional string str = 9 [default = "hello \"world\""];
+  optional bytes raw = 10 [default = "a\000b\377\n"];
+  optional Kind kind = 11 [default = KIND_BAR];
+
+  optional int64 zero = 12;
+  optional Kind first = 13;
+  optional string empty = 14;
+  repeated int32 list = 15;
+  optional Defaults msg = 16;
+}
+`,
+}
+
+func valueAST(t *testing.T) AST {
+	d := InitMockDebugger()
+	ast := ProcessCodeGeneratorRequest(d, compileRequest(t, valueSources, "defaults.proto"))
+	require.False(t, d.Failed())
+	return ast
+}
+
+func TestField_Default(t *testing.T) {
+	t.Parallel()
+
+	ast := valueAST(t)
+
+	defaultOf := func(name string) interface{} {
+		e, ok := ast.Lookup(".defaults.Defaults." + name)
+		require.True(t, ok, name)
+		v, err := e.(Field).Default()
+		require.NoError(t, err, name)
+		return v
+	}
+
+	assert.Equal(t, true, defaultOf("b"))
+	assert.Equal(t, int32(-32), defaultOf("i32"))
+	assert.Equal(t, int64(-64), defaultOf("i64"))
+	assert.Equal(t, uint32(32), defaultOf("u32"))
+	assert.Equal(t, uint64(math.MaxUint64), defaultOf("u64"))
+	assert.Equal(t, float32(1.5), defaultOf("f32"))
+	assert.Equal(t, math.Inf(-1), defaultOf("f64"))
+	assert.True(t, math.IsNaN(defaultOf("nan").(float64)))
+	assert.Equal(t, `hello "world"`, defaultOf("str"))
+	assert.Equal(t, []byte("a\x00b\xff\n"), defaultOf("raw"))
+
+	kind := defaultOf("kind")
+	require.Implements(t, (*EnumValue)(nil), kind)
+	assert.Equal(t, ".defaults.Kind.KIND_BAR", kind.(EnumValue).FullyQualifiedName())
+
+	assert.Equal(t, int64(0), defaultOf("zero"))
+	assert.Equal(t, ".defaults.Kind.KIND_FOO", defaultOf("first").(EnumValue).FullyQualifiedName())
+	assert.Equal(t, "", defaultOf("empty"))
+	assert.Nil(t, defaultOf("list"))
+	assert.Nil(t, defaultOf("msg"))
+
+	t.Run("malformed", func(t *testing.T) {
+		f := &field{
+			fqn:  ".foo.Bar.baz",
+			desc: &descriptor.FieldDescriptorProto{Type: Int32T.ProtoPtr(), DefaultValue: proto.String("abc")},
+		}
+
+		_, err := f.Default()
+		assert.Error(t, err)
+	})
+
+	t.Run("extension", func(t *testing.T) {
+		e := &ext{fqn: ".foo.bar"}
+		e.desc = &descriptor.FieldDescriptorProto{Type: StringT.ProtoPtr(), DefaultValue: proto.String("baz")}
+
+		v, err := e.Default()
+		require.NoError(t, err)
+		assert.Equal(t, "baz", v)
+	})
+}
+
+func TestUnescapeBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       string
+		expected []byte
+	}{
+		{`abc`, []byte("abc")},
+		{`\a\b\f\n\r\t\v`, []byte("\a\b\f\n\r\t\v")},
+		{`\\\'\"\?`, []byte(`\'"?`)},
+		{`\0\12\101\1012`, []byte("\x00\x0aAA2")},
+		{`\x4\x41\x414`, []byte("\x04AA4")},
+	}
+
+	for _, test := range tests {
+		b, err := unescapeBytes(test.in)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.expected, b, test.in)
+	}
+
+	for _, in := range []string{`\`, `\x`, `\q`, `\400`} {
+		_, err := unescapeBytes(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestMessage_New(t *testing.T) {
+	t.Parallel()
+
+	ast := valueAST(t)
+	e, ok := ast.Lookup(".defaults.Defaults")
+	require.True(t, ok)
+
+	m := e.(Message).New()
+	require.NotNil(t, m)
+	assert.Equal(t, protoreflect.FullName("defaults.Defaults"), m.Descriptor().FullName())
+
+	// defaults are applied from the descriptor
+	fd := m.Descriptor().Fields().ByName("i32")
+	assert.Equal(t, int64(-32), m.Get(fd).Int())
+
+	m.Set(fd, protoreflect.ValueOfInt32(7))
+	assert.Equal(t, int64(7), m.Get(fd).Int())
+
+	detached := &msg{parent: &file{}}
+	assert.Nil(t, detached.New())
+}
+
+func TestEncodeValue(t *testing.T) {
+	t.Parallel()
+
+	ast := valueAST(t)
+	e, ok := ast.Lookup(".defaults.Defaults")
+	require.True(t, ok)
+
+	m := e.(Message).New()
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByName("str"), protoreflect.ValueOfString("a  b"))
+	m.Set(fields.ByName("i64"), protoreflect.ValueOfInt64(5))
+	m.Set(fields.ByName("kind"), protoreflect.ValueOfEnum(1))
+	m.Set(fields.ByName("raw"), protoreflect.ValueOfBytes([]byte{0, 'x'}))
+
+	kind, ok := ast.Lookup(".defaults.Kind.KIND_BAR")
+	require.True(t, ok)
+
+	tests := []struct {
+		name       string
+		in         interface{}
+		text, json string
+	}{
+		{"message", m, `i64:5 str:"a  b" raw:"\x00x" kind:KIND_FOO`, `{"i64":"5","str":"a  b","raw":"AHg=","kind":"KIND_FOO"}`},
+		{"reflect message", protoreflect.ValueOfMessage(m), `i64:5 str:"a  b" raw:"\x00x" kind:KIND_FOO`, `{"i64":"5","str":"a  b","raw":"AHg=","kind":"KIND_FOO"}`},
+		{"generated message", &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/foo"}}, `get:"/foo"`, `{"get":"/foo"}`},
+		{"enum value", kind, `KIND_BAR`, `"KIND_BAR"`},
+		{"enum number", protoreflect.ValueOfEnum(2), `2`, `2`},
+		{"bool", true, `true`, `true`},
+		{"int32", int32(-1), `-1`, `-1`},
+		{"int64", int64(-1), `-1`, `"-1"`},
+		{"uint32", uint32(1), `1`, `1`},
+		{"uint64", uint64(1), `1`, `"1"`},
+		{"float32", float32(1.5), `1.5`, `1.5`},
+		{"float64", math.Inf(1), `inf`, `"Infinity"`},
+		{"nan", math.NaN(), `nan`, `"NaN"`},
+		{"string", "<\"é\">\n", `"<\"é\">\n"`, `"<\"é\">\n"`},
+		{"bytes", []byte("a\xff"), `"a\377"`, `"Yf8="`},
+		{"reflect scalar", protoreflect.ValueOfString("x"), `"x"`, `"x"`},
+	}
+
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			text, err := EncodeTextValue(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.text, text)
+
+			json, err := EncodeJSONValue(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.json, json)
+		})
+	}
+
+	t.Run("default round trip", func(t *testing.T) {
+		raw, ok := ast.Lookup(".defaults.Defaults.raw")
+		require.True(t, ok)
+
+		v, err := raw.(Field).Default()
+		require.NoError(t, err)
+
+		text, err := EncodeTextValue(v)
+		require.NoError(t, err)
+		assert.Equal(t, `"a\000b\377\n"`, text)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		for _, v := range []interface{}{nil, struct{}{}, protoreflect.Value{}, m.Get(fields.ByName("list"))} {
+			_, err := EncodeTextValue(v)
+			assert.Error(t, err)
+
+			_, err = EncodeJSONValue(v)
+			assert.Error(t, err)
+		}
+	})
+}
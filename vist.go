@@ -2,7 +2,6 @@ package pgs
 
 import (
 	"fmt"
-	"strings"
 
 	_ "github.com/gogo/protobuf/gogoproto"
 	_ "google.golang.org/genproto/googleapis/api/annotations"
@@ -10,6 +9,10 @@ import (
 	"google.golang.org/protobuf/types/descriptorpb"
 )
 
+// Function describes a method added to every service of a File by
+// ExtensibleFile.AddMethod.
+//
+// Deprecated: use Mutator.AddMethod.
 type Function struct {
 	Method string
 	Path   string
@@ -17,6 +20,11 @@ type Function struct {
 	Extra  string
 }
 
+// ExtensibleFile adds methods and messages to a File.
+//
+// Deprecated: ExtensibleFile does not assign parents or fully-qualified names
+// to the entities it adds, nor register them with the AST. Use Mutator, which
+// validates each change and keeps the AST consistent.
 type ExtensibleFile struct {
 	File
 }
@@ -65,6 +73,10 @@ func (ef ExtensibleFile) AddMessage(name string) {
 	})
 }
 
+// DescriberMixin is implemented by entity wrappers that render themselves as
+// `.proto` source.
+//
+// Deprecated: use ProtoPrinter.
 type DescriberMixin interface {
 	DescribeSelf() string
 }
@@ -79,45 +91,30 @@ var _ DescriberMixin = &XOneOf{}
 var _ DescriberMixin = &XPackage{}
 var _ DescriberMixin = &XService{}
 
+// gogoFileOptions are printed on every file rendered by XFile.
+var gogoFileOptions = []string{
+	"(gogoproto.unmarshaler_all) = true",
+	"(gogoproto.sizer_all) = true",
+	"(gogoproto.equal_all) = true",
+	"(gogoproto.marshaler_all) = true",
+}
+
+// gogoFileImports provide the extensions used by gogoFileOptions.
+var gogoFileImports = []string{"github.com/gogo/protobuf/gogoproto/gogo.proto"}
+
+// XFile renders a File as `.proto` source, enabling the gogoproto marshaling
+// options.
+//
+// Deprecated: use ProtoPrinter with FileOptions.
 type XFile struct {
 	File
 }
 
 func (x XFile) DescribeSelf() string {
-	imp := ""
-	for _, i := range x.Imports() {
-		imp += fmt.Sprintf("import \"%s\";\n", i.Name().String())
-	}
-	s := ""
-
-	for _, service := range x.Services() {
-		s += XService{service}.DescribeSelf()
-	}
-
-	for _, m := range x.Messages() {
-		s += XMessage{m}.DescribeSelf()
-	}
-
-	for _, e := range x.Enums() {
-		s += XEnum{e}.DescribeSelf()
-	}
-
-	return fmt.Sprintf(
-		`
-syntax = "%s";
-package %s;
-
-option (gogoproto.unmarshaler_all) = true;
-option (gogoproto.sizer_all) = true;
-option (gogoproto.equal_all) = true;
-option (gogoproto.marshaler_all) = true;
-
-%s
-%s
-`, x.Syntax().String(), x.Package().ProtoName().String(), imp, s,
-	)
+	return ProtoPrinter{FileOptions: gogoFileOptions, FileImports: gogoFileImports}.Sprint(x.File)
 }
 
+// Deprecated: use the ProtoName of the Package.
 type XPackage struct {
 	Package
 }
@@ -126,117 +123,51 @@ func (x XPackage) DescribeSelf() string {
 	return fmt.Sprintf("package %s", x.ProtoName().String())
 }
 
+// Deprecated: use ProtoPrinter.
 type XService struct {
 	Service
 }
 
-func (x XService) DescribeSelf() string {
-	methods := ""
-	for _, m := range x.Methods() {
-		methods += XMethod{m}.DescribeSelf()
-	}
-	return fmt.Sprintf(`
-service %s {
-	%s
-}`,
-		x.Name(),
-		methods,
-	)
-}
+func (x XService) DescribeSelf() string { return ProtoPrinter{}.Sprint(x.Service) }
 
+// Deprecated: use ProtoPrinter.
 type XMethod struct {
 	Method
 }
 
-func (x XMethod) DescribeSelf() string {
-	return fmt.Sprintf(
-		`rpc %s(%s) returns (%s) {
-		option %s;
-	}
-	`,
-		x.Name(),
-		x.Input().Name(),
-		x.Output().Name(),
-		fmtOption(x.Descriptor().GetOptions().String()),
-	)
-}
-
-func fmtOption(s string) string {
-	return strings.ReplaceAll(strings.ReplaceAll(s, "[", "("), "]:", ")=")
-}
+func (x XMethod) DescribeSelf() string { return ProtoPrinter{}.Sprint(x.Method) }
 
+// Deprecated: use ProtoPrinter.
 type XEnum struct {
 	Enum
 }
 
-func (x XEnum) DescribeSelf() string {
-	s := ""
-	for _, ev := range x.Values() {
-		s += XEnumValue{ev}.DescribeSelf()
-	}
-	return fmt.Sprintf(`
-enum %s {
-	%s
-}`,
-		x.Name(),
-		s)
-}
+func (x XEnum) DescribeSelf() string { return ProtoPrinter{}.Sprint(x.Enum) }
 
+// Deprecated: use ProtoPrinter.
 type XEnumValue struct {
 	EnumValue
 }
 
-func (x XEnumValue) DescribeSelf() string {
-	return fmt.Sprintf("%s = %v;", x.Name(), x.Value())
-}
+func (x XEnumValue) DescribeSelf() string { return ProtoPrinter{}.Sprint(x.EnumValue) }
 
+// Deprecated: use ProtoPrinter.
 type XMessage struct {
 	Message
 }
 
-func (x XMessage) DescribeSelf() string {
-	s := ""
-	for _, e := range x.Enums() {
-		s += XEnum{e}.DescribeSelf()
-	}
-	for _, m := range x.Messages() {
-		s += XMessage{m}.DescribeSelf()
-	}
-	for _, f := range x.Fields() {
-		s += XField{f}.DescribeSelf()
-	}
-	for _, oo := range x.OneOfs() {
-		s += XOneOf{oo}.DescribeSelf()
-	}
-	return fmt.Sprintf(
-		`message %s {
-	%s
-}`,
-		x.Name(),
-		s)
-}
+func (x XMessage) DescribeSelf() string { return ProtoPrinter{}.Sprint(x.Message) }
 
+// Deprecated: use ProtoPrinter.
 type XField struct {
 	Field
 }
 
-func (x XField) DescribeSelf() string {
-	return fmt.Sprintf("%v %s = %v;", x.Type().ProtoType(), x.Name(), x.Type().ProtoLabel())
-}
+func (x XField) DescribeSelf() string { return ProtoPrinter{}.Sprint(x.Field) }
 
+// Deprecated: use ProtoPrinter.
 type XOneOf struct {
 	OneOf
 }
 
-func (x XOneOf) DescribeSelf() string {
-	s := ""
-	for _, f := range x.Fields() {
-		s += XField{f}.DescribeSelf()
-	}
-	return fmt.Sprintf(`
-oneof %s {
-	%s
-}`,
-		x.Name(),
-		s)
-}
+func (x XOneOf) DescribeSelf() string { return ProtoPrinter{}.Sprint(x.OneOf) }
@@ -1,14 +1,24 @@
 package pgs
 
 import (
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
-	"google.golang.org/protobuf/types/descriptorpb"
+	"github.com/spf13/afero"
 )
 
+// supportedFeatures describes the CodeGeneratorResponse features advertised to
+// protoc by all PG* plugins.
+const supportedFeatures = uint64(plugin_go.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL |
+	plugin_go.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+
 type workflow interface {
 	Init(*Generator) AST
 	Run(AST) []Artifact
@@ -26,8 +36,38 @@ type standardWorkflow struct {
 func (wf *standardWorkflow) Init(g *Generator) AST {
 	wf.Generator = g
 
+	var req *plugin_go.CodeGeneratorRequest
+	if g.descriptorSet != nil {
+		req = wf.readDescriptorSet()
+	} else {
+		req = wf.readRequest()
+	}
+
+	if g.parameter != nil {
+		req.Parameter = proto.String(*g.parameter)
+	}
+
+	wf.Debug("parsing command-line params")
+	wf.params = ParseParameters(req.GetParameter())
+	for _, pm := range wf.paramMutators {
+		pm(wf.params)
+	}
+
+	if wf.BiDi {
+		return ProcessCodeGeneratorRequestBidirectional(g, req)
+	}
+
+	if g.lazyAST {
+		return ProcessCodeGeneratorRequestLazy(g, req)
+	}
+
+	return ProcessCodeGeneratorRequest(g, req)
+}
+
+// readRequest reads the CodeGeneratorRequest provided by protoc.
+func (wf *standardWorkflow) readRequest() *plugin_go.CodeGeneratorRequest {
 	wf.Debug("reading input")
-	data, err := ioutil.ReadAll(g.in)
+	data, err := ioutil.ReadAll(wf.in)
 	wf.CheckErr(err, "reading input")
 
 	wf.Debug("parsing input proto")
@@ -36,45 +76,349 @@ func (wf *standardWorkflow) Init(g *Generator) AST {
 	wf.CheckErr(err, "parsing input proto")
 	wf.Assert(len(req.FileToGenerate) > 0, "no files to generate")
 
-	wf.Debug("parsing input protoset")
-	descriptorSet := new(descriptorpb.FileDescriptorSet)
-	err = proto.Unmarshal(data, descriptorSet)
-	wf.CheckErr(err, "parsing input protoset")
+	return req
+}
 
-	req.ProtoFile = descriptorSet.File
+// readDescriptorSet builds a CodeGeneratorRequest from the FileDescriptorSet
+// provided via the FromDescriptorSet InitOption.
+func (wf *standardWorkflow) readDescriptorSet() *plugin_go.CodeGeneratorRequest {
+	wf.Debug("reading input descriptor set")
+	data, err := ioutil.ReadAll(wf.descriptorSet.in)
+	wf.CheckErr(err, "reading input descriptor set")
 
-	wf.Debug("parsing command-line params")
-	wf.params = ParseParameters(req.GetParameter())
-	for _, pm := range wf.paramMutators {
-		pm(wf.params)
+	wf.Debug("parsing input descriptor set")
+	fdset := new(descriptor.FileDescriptorSet)
+	err = proto.Unmarshal(data, fdset)
+	wf.CheckErr(err, "parsing input descriptor set")
+
+	req := &plugin_go.CodeGeneratorRequest{
+		FileToGenerate: wf.descriptorSet.targets,
+		ProtoFile:      fdset.GetFile(),
 	}
 
-	if wf.BiDi {
-		return ProcessCodeGeneratorRequestBidirectional(g, req)
+	if len(req.FileToGenerate) == 0 {
+		for _, f := range fdset.GetFile() {
+			req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+		}
 	}
+	wf.Assert(len(req.FileToGenerate) > 0, "no files to generate")
 
-	return ProcessCodeGeneratorRequest(g, req)
+	files := make(map[string]struct{}, len(fdset.GetFile()))
+	for _, f := range fdset.GetFile() {
+		files[f.GetName()] = struct{}{}
+	}
+
+	for _, t := range req.FileToGenerate {
+		_, ok := files[t]
+		wf.Assert(ok, "target file not found in descriptor set: ", t)
+	}
+
+	return req
 }
 
 func (wf *standardWorkflow) Run(ast AST) (arts []Artifact) {
-	ctx := Context(wf.Debugger, wf.params, wf.params.OutputPath())
+	wf.Debug("sorting modules")
+	runs := wf.sortModules()
+
+	var cache *artifactCache
+	if wf.cacheDir != "" {
+		fs := wf.fs
+		if fs == nil {
+			fs = afero.NewOsFs()
+		}
+		cache = &artifactCache{Debugger: wf.Push("cache"), fs: fs, dir: wf.cacheDir, params: wf.params}
+	}
 
 	wf.Debug("initializing modules")
-	for _, m := range wf.mods {
-		m.InitContext(ctx.Push(m.Name()))
+	for _, r := range runs {
+		r.cache = cache
+		wf.initModule(r)
 	}
 
 	wf.Debug("executing modules")
-	for _, m := range wf.mods {
-		arts = append(arts, m.Execute(ast.Targets(), ast.Packages())...)
+	if wf.parallelism > 1 {
+		wf.executeParallel(ast, runs)
+	} else {
+		for _, r := range runs {
+			r.execute(ast)
+		}
+	}
+
+	// modules may have executed out of order, but their artifacts are returned
+	// in registration order
+	registered := make([]*moduleRun, len(runs))
+	for _, r := range runs {
+		registered[r.index] = r
+	}
+
+	for _, r := range registered {
+		arts = append(arts, r.arts...)
+	}
+
+	if cache != nil {
+		cache.prune()
+	}
+
+	if wf.diags != nil {
+		for _, r := range runs {
+			wf.diags.add(r.diags.flush()...)
+		}
+
+		for _, diag := range wf.diags.flush() {
+			arts = append(arts, GeneratorError{Message: diag.String()})
+		}
 	}
 
 	return
 }
 
+// sortModules orders the registered modules such that each DependentModule
+// follows its dependencies. Otherwise, modules retain their registration
+// order.
+func (wf *standardWorkflow) sortModules() []*moduleRun {
+	runs := make([]*moduleRun, len(wf.mods))
+	byName := make(map[string][]*moduleRun, len(wf.mods))
+	for i, m := range wf.mods {
+		runs[i] = &moduleRun{mod: m, index: i}
+		byName[m.Name()] = append(byName[m.Name()], runs[i])
+	}
+
+	dependents := make(map[*moduleRun][]*moduleRun, len(runs))
+	for _, r := range runs {
+		dm, ok := r.mod.(DependentModule)
+		if !ok {
+			continue
+		}
+
+		for _, name := range dm.DependsOn() {
+			switch deps := byName[name]; len(deps) {
+			case 0:
+				wf.Failf("module %q depends on unknown module %q", r.mod.Name(), name)
+				return nil
+			case 1:
+				r.deps = append(r.deps, deps[0])
+				dependents[deps[0]] = append(dependents[deps[0]], r)
+			default:
+				wf.Failf("module %q depends on ambiguous module %q", r.mod.Name(), name)
+				return nil
+			}
+		}
+	}
+
+	remaining := make(map[*moduleRun]int, len(runs))
+	for _, r := range runs {
+		remaining[r] = len(r.deps)
+	}
+
+	sorted := make([]*moduleRun, 0, len(runs))
+	for len(sorted) < len(runs) {
+		var next *moduleRun
+		for _, r := range runs {
+			if n, ok := remaining[r]; ok && n == 0 {
+				next = r
+				break
+			}
+		}
+
+		if next == nil {
+			var cycle []string
+			for _, r := range runs {
+				if _, ok := remaining[r]; ok {
+					cycle = append(cycle, r.mod.Name())
+				}
+			}
+			wf.Failf("module dependency cycle detected between: %s", strings.Join(cycle, ", "))
+			return nil
+		}
+
+		delete(remaining, next)
+		for _, r := range dependents[next] {
+			remaining[r]--
+		}
+		sorted = append(sorted, next)
+	}
+
+	return sorted
+}
+
+func (wf *standardWorkflow) initModule(r *moduleRun) {
+	m := r.mod
+
+	d := wf.Debugger
+	if wf.diags != nil {
+		r.diags = &diagnostics{}
+		d = initCollectingDebugger(d, r.diags)
+	}
+
+	ctx := Context(d, wf.params, wf.params.OutputPath())
+	r.guard(func() { m.InitContext(ctx.Push(m.Name())) })
+}
+
+// executeParallel executes each consecutive group of ConcurrentModules
+// concurrently, bounded by the Generator's parallelism. All other modules are
+// executed on their own, preserving their order relative to the groups. A
+// module depending on another in the current group waits for the group to
+// complete.
+func (wf *standardWorkflow) executeParallel(ast AST, runs []*moduleRun) {
+	sem := make(chan struct{}, wf.parallelism)
+	wg := &sync.WaitGroup{}
+	pending := make(map[*moduleRun]struct{})
+
+	for _, r := range runs {
+		if !r.concurrent() {
+			wg.Wait()
+			pending = make(map[*moduleRun]struct{})
+			r.execute(ast)
+			continue
+		}
+
+		for _, dep := range r.deps {
+			if _, ok := pending[dep]; ok {
+				wg.Wait()
+				pending = make(map[*moduleRun]struct{})
+				break
+			}
+		}
+
+		pending[r] = struct{}{}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *moduleRun) {
+			defer func() { <-sem; wg.Done() }()
+			r.execute(ast)
+		}(r)
+	}
+
+	wg.Wait()
+}
+
+// moduleRun tracks the execution of a single module. If errors are being
+// collected, failures reported by the module are recorded in diags and any
+// artifacts it produced are discarded.
+type moduleRun struct {
+	mod   Module
+	index int // registration order of mod
+	deps  []*moduleRun
+	diags *diagnostics
+	cache *artifactCache
+	arts  []Artifact
+}
+
+func (r *moduleRun) concurrent() bool {
+	m, ok := r.mod.(ConcurrentModule)
+	return ok && m.Concurrent()
+}
+
+func (r *moduleRun) perFile() bool {
+	m, ok := r.mod.(PerFileModule)
+	return ok && m.PerFile()
+}
+
+func (r *moduleRun) failed() bool { return r.diags != nil && r.diags.len() > 0 }
+
+func (r *moduleRun) execute(ast AST) {
+	if r.failed() {
+		return
+	}
+
+	if dm, ok := r.mod.(DependentModule); ok {
+		outputs := make(map[string]ModuleOutput, len(r.deps))
+		for _, dep := range r.deps {
+			if dep.failed() {
+				r.diags.add(Diagnostic{Message: fmt.Sprintf("[%s] dependency %q failed", r.mod.Name(), dep.mod.Name())})
+				return
+			}
+			outputs[dep.mod.Name()] = dep.output()
+		}
+		r.guard(func() { dm.InitDependencies(outputs) })
+	}
+
+	if r.cache != nil && r.perFile() {
+		r.guard(func() { r.executeCached(ast) })
+	} else {
+		r.guard(func() { r.arts = r.mod.Execute(ast.Targets(), ast.Packages()) })
+	}
+
+	if r.failed() {
+		r.arts = nil
+	}
+}
+
+// executeCached executes the PerFileModule against each target File in turn,
+// reusing the cached Artifacts for any File that has not changed.
+func (r *moduleRun) executeCached(ast AST) {
+	targets := ast.Targets()
+	names := make([]string, 0, len(targets))
+	for n := range targets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		f := targets[n]
+
+		key, err := r.cache.key(r.mod, f)
+		if err != nil {
+			r.cache.Debug("unable to compute cache key for ", n, ": ", err)
+		} else if arts, ok := r.cache.load(key); ok {
+			r.cache.Debugf("reusing cached artifacts of %s for %s", r.mod.Name(), n)
+			r.arts = append(r.arts, arts...)
+			continue
+		}
+
+		arts := r.mod.Execute(map[string]File{n: f}, ast.Packages())
+		if r.failed() {
+			return
+		}
+
+		if key != "" {
+			r.cache.store(key, arts)
+		}
+		r.arts = append(r.arts, arts...)
+	}
+}
+
+func (r *moduleRun) output() ModuleOutput {
+	out := ModuleOutput{Artifacts: r.arts}
+	if p, ok := r.mod.(Publisher); ok {
+		out.Data = p.Published()
+	}
+	return out
+}
+
+// guard executes fn. If errors are being collected, a panic in fn is recorded
+// as a Diagnostic for the module.
+func (r *moduleRun) guard(fn func()) {
+	if r.diags != nil {
+		defer func() {
+			if p := recover(); p != nil {
+				r.diags.add(Diagnostic{Message: fmt.Sprintf("[%s] panic: %v", r.mod.Name(), p)})
+			}
+		}()
+	}
+
+	fn()
+}
+
 func (wf *standardWorkflow) Persist(arts []Artifact) {
 	resp := wf.persister.Persist(arts...)
 
+	if wf.diags != nil {
+		for _, diag := range wf.diags.flush() {
+			appendResponseError(resp, diag.String())
+		}
+	}
+
+	if wf.outputDir != "" {
+		wf.writeOutputDir(resp)
+		wf.Debug("rendering successful")
+		return
+	}
+
+	resp.SupportedFeatures = proto.Uint64(supportedFeatures)
+	resp.MinimumEdition = proto.Int32(int32(MinEdition))
+	resp.MaximumEdition = proto.Int32(int32(MaxEdition))
+
 	data, err := proto.Marshal(resp)
 	wf.CheckErr(err, "marshaling output proto")
 
@@ -85,6 +429,58 @@ func (wf *standardWorkflow) Persist(arts []Artifact) {
 	wf.Debug("rendering successful")
 }
 
+// writeOutputDir writes the files in resp to the directory provided via the
+// OutputDir InitOption, as protoc would.
+func (wf *standardWorkflow) writeOutputDir(resp *plugin_go.CodeGeneratorResponse) {
+	fs := wf.fs
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+
+	existing := make(map[string]string)
+	for _, f := range resp.GetFile() {
+		if f.InsertionPoint == nil || validateResponseFileName(f.GetName()) != nil {
+			continue
+		}
+
+		name := filepath.Join(wf.outputDir, f.GetName())
+		if b, err := afero.ReadFile(fs, name); err == nil {
+			existing[f.GetName()] = string(b)
+		}
+	}
+
+	files, err := ApplyResponse(resp, existing)
+	if err != nil {
+		wf.Fail("unable to write output directory: ", err)
+		return
+	}
+
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		name := filepath.Join(wf.outputDir, n)
+		dir := filepath.Dir(name)
+
+		wf.Debug("writing file:", name)
+		wf.CheckErr(fs.MkdirAll(dir, 0755), "unable to create directory: ", dir)
+		wf.CheckErr(afero.WriteFile(fs, name, []byte(files[n]), 0644), "unable to write file: ", name)
+	}
+}
+
+// appendResponseError adds msg to the error reported on resp, separating it
+// from any previously reported errors.
+func appendResponseError(resp *plugin_go.CodeGeneratorResponse, msg string) {
+	if resp.Error == nil {
+		resp.Error = proto.String(msg)
+		return
+	}
+	resp.Error = proto.String(strings.Join([]string{resp.GetError(), msg}, "; "))
+}
+
 // onceWorkflow wraps an existing workflow, executing its methods exactly
 // once. Subsequent calls will ignore their inputs and use the previously
 // provided values.
@@ -2,12 +2,18 @@ package pgs
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugin_go "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStandardWorkflow_Init(t *testing.T) {
@@ -33,6 +39,48 @@ func TestStandardWorkflow_Init(t *testing.T) {
 	})
 }
 
+func TestStandardWorkflow_Init_DescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	fdset := &descriptor.FileDescriptorSet{File: append(printerDeps(), proto3PrinterFile())}
+	b, err := proto.Marshal(fdset)
+	require.NoError(t, err)
+
+	g := Init(FromDescriptorSet(bytes.NewReader(b), "foo/foo.proto"), ProtocParameters("foo=bar"))
+	ast := g.workflow.Init(g)
+
+	assert.Equal(t, "bar", g.params.Str("foo"))
+	assert.Len(t, ast.Targets(), 1)
+	assert.Contains(t, ast.Targets(), "foo/foo.proto")
+	assert.Equal(t, "foo=bar", ast.ToCodeGeneratorRequest().GetParameter())
+
+	t.Run("all files", func(t *testing.T) {
+		g := Init(FromDescriptorSet(bytes.NewReader(b)))
+		ast := g.workflow.Init(g)
+
+		assert.Len(t, ast.Targets(), 3)
+		assert.Empty(t, g.params.Str("foo"))
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		g := Init(FromDescriptorSet(bytes.NewReader(b), "foo/foo.proto"), LazyAST())
+		ast := g.workflow.Init(g)
+
+		require.IsType(t, &graph{}, ast)
+		assert.NotNil(t, ast.(*graph).lazy)
+		assert.NotNil(t, ast.Targets()["foo/foo.proto"])
+	})
+
+	t.Run("missing target", func(t *testing.T) {
+		g := Init(FromDescriptorSet(bytes.NewReader(b), "missing.proto"))
+		d := InitMockDebugger()
+		g.Debugger = d
+
+		g.workflow.Init(g)
+		assert.True(t, d.Failed())
+	})
+}
+
 func TestStandardWorkflow_Run(t *testing.T) {
 	t.Parallel()
 
@@ -49,6 +97,175 @@ func TestStandardWorkflow_Run(t *testing.T) {
 	assert.True(t, m.executed)
 }
 
+func TestStandardWorkflow_Run_CollectErrors(t *testing.T) {
+	t.Parallel()
+
+	g := Init(CollectErrors())
+	g.workflow = &standardWorkflow{Generator: g}
+	g.params = Parameters{}
+
+	ok := newMockModule()
+	ok.name = "ok"
+
+	g.RegisterModule(
+		&failingModule{ModuleBase: &ModuleBase{}, name: "fails"},
+		&failingModule{ModuleBase: &ModuleBase{}, name: "panics", panics: true},
+		ok,
+	)
+
+	arts := g.workflow.Run(&graph{})
+
+	assert.True(t, ok.executed)
+	assert.Equal(t, []Artifact{
+		GeneratorError{Message: "[fails]first: oops"},
+		GeneratorError{Message: "[fails]second"},
+		GeneratorError{Message: "[panics] panic: oops"},
+	}, arts)
+	assert.Empty(t, g.Diagnostics())
+}
+
+func TestStandardWorkflow_Run_Parallel(t *testing.T) {
+	t.Parallel()
+
+	g := Init(ParallelModules(2))
+	g.workflow = &standardWorkflow{Generator: g}
+	g.params = Parameters{}
+
+	var running, peak int32
+	mods := []Module{
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "a", concurrent: true, running: &running, peak: &peak},
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "b", concurrent: true, running: &running, peak: &peak},
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "c", concurrent: true, running: &running, peak: &peak},
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "d", running: &running, peak: &peak},
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "e", concurrent: true, running: &running, peak: &peak},
+	}
+	g.RegisterModule(mods...)
+
+	arts := g.workflow.Run(&graph{})
+
+	assert.Equal(t, []Artifact{
+		CustomFile{Name: "a"},
+		CustomFile{Name: "b"},
+		CustomFile{Name: "c"},
+		CustomFile{Name: "d"},
+		CustomFile{Name: "e"},
+	}, arts)
+	assert.True(t, atomic.LoadInt32(&peak) <= 2)
+	assert.True(t, mods[3].(*concurrentModule).alone)
+}
+
+func TestStandardWorkflow_Run_ParallelCollectErrors(t *testing.T) {
+	t.Parallel()
+
+	g := Init(ParallelModules(4), CollectErrors())
+	g.workflow = &standardWorkflow{Generator: g}
+	g.params = Parameters{}
+
+	var running, peak int32
+	g.RegisterModule(
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "a", concurrent: true, running: &running, peak: &peak},
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "b", concurrent: true, fails: true, running: &running, peak: &peak},
+		&concurrentModule{ModuleBase: &ModuleBase{}, name: "c", concurrent: true, running: &running, peak: &peak},
+	)
+
+	arts := g.workflow.Run(&graph{})
+
+	assert.Equal(t, []Artifact{
+		CustomFile{Name: "a"},
+		CustomFile{Name: "c"},
+		GeneratorError{Message: "[b]oops"},
+	}, arts)
+}
+
+func TestStandardWorkflow_Run_Dependencies(t *testing.T) {
+	t.Parallel()
+
+	for _, parallelism := range []int{0, 4} {
+		g := Init()
+		g.workflow = &standardWorkflow{Generator: g}
+		g.params = Parameters{}
+		g.parallelism = parallelism
+
+		registry := &dependentModule{ModuleBase: &ModuleBase{}, name: "registry", deps: []string{"svc-a", "svc-b"}}
+		g.RegisterModule(
+			registry,
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "svc-a"},
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "svc-b", deps: []string{"svc-a"}},
+		)
+
+		arts := g.workflow.Run(&graph{})
+
+		// artifacts follow the registration order, not the execution order
+		assert.Equal(t, []Artifact{
+			CustomFile{Name: "registry"},
+			CustomFile{Name: "svc-a"},
+			CustomFile{Name: "svc-b"},
+		}, arts)
+
+		assert.Equal(t, map[string]ModuleOutput{
+			"svc-a": {Artifacts: []Artifact{CustomFile{Name: "svc-a"}}, Data: "svc-a"},
+			"svc-b": {Artifacts: []Artifact{CustomFile{Name: "svc-b"}}, Data: "svc-b"},
+		}, registry.received)
+	}
+}
+
+func TestStandardWorkflow_Run_DependencyErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]Module{
+		"unknown": {
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "a", deps: []string{"b"}},
+		},
+		"ambiguous": {
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "a", deps: []string{"b"}},
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "b"},
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "b"},
+		},
+		"cycle": {
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "a", deps: []string{"b"}},
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "b", deps: []string{"a"}},
+		},
+		"self": {
+			&dependentModule{ModuleBase: &ModuleBase{}, name: "a", deps: []string{"a"}},
+		},
+	}
+
+	for desc, mods := range tests {
+		mods := mods
+		t.Run(desc, func(t *testing.T) {
+			d := InitMockDebugger()
+			g := &Generator{Debugger: d}
+			g.workflow = &standardWorkflow{Generator: g}
+			g.RegisterModule(mods...)
+
+			assert.Empty(t, g.workflow.Run(&graph{}))
+			assert.True(t, d.Failed())
+		})
+	}
+}
+
+func TestStandardWorkflow_Run_DependencyFailed(t *testing.T) {
+	t.Parallel()
+
+	g := Init(CollectErrors())
+	g.workflow = &standardWorkflow{Generator: g}
+	g.params = Parameters{}
+
+	registry := &dependentModule{ModuleBase: &ModuleBase{}, name: "registry", deps: []string{"svc"}}
+	g.RegisterModule(
+		registry,
+		&failingModule{ModuleBase: &ModuleBase{}, name: "svc", panics: true},
+	)
+
+	arts := g.workflow.Run(&graph{})
+
+	assert.Nil(t, registry.received)
+	assert.Equal(t, []Artifact{
+		GeneratorError{Message: "[svc] panic: oops"},
+		GeneratorError{Message: `[registry] dependency "svc" failed`},
+	}, arts)
+}
+
 func TestStandardWorkflow_Persist(t *testing.T) {
 	t.Parallel()
 
@@ -57,6 +274,104 @@ func TestStandardWorkflow_Persist(t *testing.T) {
 	g.persister = dummyPersister(g.Debugger)
 
 	assert.NotPanics(t, func() { g.workflow.Persist(nil) })
+
+	t.Run("collected errors", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		g := Init(ProtocOutput(out), CollectErrors())
+		g.workflow = &standardWorkflow{Generator: g}
+
+		g.workflow.Persist([]Artifact{
+			GeneratorError{Message: "foo"},
+			GeneratorFile{Name: "/bar"},
+		})
+
+		resp := new(plugin_go.CodeGeneratorResponse)
+		assert.NoError(t, proto.Unmarshal(out.Bytes(), resp))
+		assert.Equal(t,
+			"foo; unable to convert /bar to proto: generator file names must be relative paths",
+			resp.GetError())
+		assert.Empty(t, g.Diagnostics())
+	})
+
+	t.Run("output dir", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		fs := afero.NewMemMapFs()
+		g := Init(ProtocOutput(out), FileSystem(fs), OutputDir("out"))
+		g.workflow = &standardWorkflow{Generator: g}
+
+		g.workflow.Persist([]Artifact{
+			GeneratorFile{Name: "foo/bar.txt", Contents: "foo"},
+			GeneratorAppend{FileName: "foo/bar.txt", Contents: "bar"},
+			GeneratorFile{Name: "baz.txt", Contents: "baz"},
+		})
+
+		assert.Empty(t, out.Bytes())
+
+		b, err := afero.ReadFile(fs, "out/foo/bar.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "foobar", string(b))
+
+		b, err = afero.ReadFile(fs, "out/baz.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "baz", string(b))
+	})
+
+	t.Run("output dir insertion points", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "out/foo.go", []byte("package foo\n\t// @@protoc_insertion_point(bar)\n"), 0644))
+
+		g := Init(FileSystem(fs), OutputDir("out"))
+		g.workflow = &standardWorkflow{Generator: g}
+
+		g.workflow.Persist([]Artifact{
+			GeneratorInjection{FileName: "foo.go", InsertionPoint: "bar", Contents: "var x int\n"},
+			GeneratorFile{Name: "baz.go", Contents: "package baz\n// @@protoc_insertion_point(qux)\n"},
+			GeneratorInjection{FileName: "baz.go", InsertionPoint: "qux", Contents: "var y int\n"},
+		})
+
+		b, err := afero.ReadFile(fs, "out/foo.go")
+		assert.NoError(t, err)
+		assert.Equal(t, "package foo\n\tvar x int\n\t// @@protoc_insertion_point(bar)\n", string(b))
+
+		b, err = afero.ReadFile(fs, "out/baz.go")
+		assert.NoError(t, err)
+		assert.Equal(t, "package baz\nvar y int\n// @@protoc_insertion_point(qux)\n", string(b))
+	})
+
+	t.Run("output dir errors", func(t *testing.T) {
+		g := Init(FileSystem(afero.NewMemMapFs()), OutputDir("out"))
+		g.workflow = &standardWorkflow{Generator: g}
+		d := InitMockDebugger()
+		g.Debugger = d
+
+		g.workflow.Persist([]Artifact{GeneratorError{Message: "foo"}})
+		assert.True(t, d.Failed())
+
+		d = InitMockDebugger()
+		g.Debugger = d
+		g.workflow.Persist([]Artifact{GeneratorInjection{FileName: "foo.go", InsertionPoint: "bar"}})
+		assert.True(t, d.Failed())
+	})
+
+	t.Run("supported features", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		g := Init(ProtocOutput(out))
+		g.workflow = &standardWorkflow{Generator: g}
+		g.persister = dummyPersister(g.Debugger)
+
+		g.workflow.Persist(nil)
+
+		resp := new(plugin_go.CodeGeneratorResponse)
+		assert.NoError(t, proto.Unmarshal(out.Bytes(), resp))
+		assert.Equal(t,
+			uint64(plugin_go.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL),
+			resp.GetSupportedFeatures()&uint64(plugin_go.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
+		assert.Equal(t,
+			uint64(plugin_go.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS),
+			resp.GetSupportedFeatures()&uint64(plugin_go.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS))
+		assert.Equal(t, int32(MinEdition), resp.GetMinimumEdition())
+		assert.Equal(t, int32(MaxEdition), resp.GetMaximumEdition())
+	})
 }
 
 func TestOnceWorkflow(t *testing.T) {
@@ -98,3 +413,83 @@ type dummyWorkflow struct {
 func (wf *dummyWorkflow) Init(g *Generator) AST   { wf.initted = true; return wf.AST }
 func (wf *dummyWorkflow) Run(ast AST) []Artifact  { wf.run = true; return wf.Artifacts }
 func (wf *dummyWorkflow) Persist(arts []Artifact) { wf.persisted = true }
+
+type failingModule struct {
+	*ModuleBase
+	name   string
+	panics bool
+}
+
+func (m *failingModule) Name() string { return m.name }
+
+func (m *failingModule) Execute(targets map[string]File, packages map[string]Package) []Artifact {
+	if m.panics {
+		panic("oops")
+	}
+
+	m.CheckErr(errors.New("oops"), "first")
+	m.Fail("second")
+	return []Artifact{GeneratorFile{Name: "discarded"}}
+}
+
+type concurrentModule struct {
+	*ModuleBase
+	name          string
+	concurrent    bool
+	fails         bool
+	alone         bool
+	running, peak *int32
+}
+
+func (m *concurrentModule) Name() string     { return m.name }
+func (m *concurrentModule) Concurrent() bool { return m.concurrent }
+
+func (m *concurrentModule) Execute(targets map[string]File, packages map[string]Package) []Artifact {
+	n := atomic.AddInt32(m.running, 1)
+	defer atomic.AddInt32(m.running, -1)
+
+	for {
+		p := atomic.LoadInt32(m.peak)
+		if n <= p || atomic.CompareAndSwapInt32(m.peak, p, n) {
+			break
+		}
+	}
+
+	m.alone = n == 1
+	time.Sleep(10 * time.Millisecond)
+
+	if m.fails {
+		m.Fail("oops")
+	}
+
+	return []Artifact{CustomFile{Name: m.name}}
+}
+
+type dependentModule struct {
+	*ModuleBase
+	name     string
+	deps     []string
+	received map[string]ModuleOutput
+}
+
+func (m *dependentModule) Name() string        { return m.name }
+func (m *dependentModule) DependsOn() []string { return m.deps }
+func (m *dependentModule) Concurrent() bool    { return true }
+
+func (m *dependentModule) Execute(targets map[string]File, packages map[string]Package) []Artifact {
+	for _, name := range m.deps {
+		out, ok := m.Dependency(name)
+		if !ok {
+			m.Failf("missing dependency %q", name)
+		}
+
+		if m.received == nil {
+			m.received = make(map[string]ModuleOutput)
+		}
+		m.received[name] = out
+	}
+
+	m.Publish(m.name)
+	m.AddArtifact(CustomFile{Name: m.name})
+	return m.Artifacts()
+}